@@ -1,12 +1,19 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"net/url"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/qawatake/obsdconv/convert"
+	"github.com/qawatake/obsdconv/process"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -22,45 +29,111 @@ const (
 	FLAG_CONVERT_LINKS      = "link"
 	FLAG_REMOVE_COMMENT     = "cmmt"
 	FLAG_PUBLISHABLE        = "pub"
+	FLAG_PUB_RULE           = "pubRule"
+	FLAG_PUB_MARKER         = "pubMarker"
 	FLAG_REMOVE_H1          = "rmh1"
 	FLAG_REMAP_META_KEYS    = "remapkey"
+	FLAG_YAML_RULES         = "yamlRules"
 	FLAG_FILTER             = "filter"
-	// FLAG_BASE_URL           = "baseUrl"
-	FLAG_REMAP_PATH_PREFIX = "remapPathPrefix"
-	FLAG_FORMAT_LINK       = "formatLink"
-	FLAG_FORMAT_ANCHOR     = "formatAnchor"
-	FLAG_STRICT_REF        = "strictref"
-	FLAG_OBSIDIAN_USAGE    = "obs"
-	FLAG_STANDARD_USAGE    = "std"
-	FLAG_VERSION           = "version"
-	FLAG_DEBUG             = "debug"
+	FLAG_BASE_URL           = "baseUrl"
+	FLAG_PERMALINK          = "permalink"
+	FLAG_REMAP_PATH_PREFIX  = "remapPathPrefix"
+	FLAG_REMAP_OUTPUT       = "remapOutput"
+	FLAG_SLUG               = "slug"
+	FLAG_FORMAT_LINK        = "formatLink"
+	FLAG_FORMAT_ANCHOR      = "formatAnchor"
+	FLAG_LINK_STYLE         = "linkStyle"
+	FLAG_LINK_TEMPLATE      = "linkTemplate"
+	FLAG_IMAGE_SIZE         = "imageSize"
+	FLAG_EMBED_FALLBACK     = "embedFallback"
+	FLAG_STRICT_REF         = "strictref"
+	FLAG_OBSIDIAN_USAGE     = "obs"
+	FLAG_STANDARD_USAGE     = "std"
+	FLAG_VERSION            = "version"
+	FLAG_DEBUG              = "debug"
+	FLAG_CONFIG             = "config"
+	FLAG_INCREMENTAL        = "incr"
+	FLAG_WATCH              = "watch"
+	FLAG_DRY_RUN            = "dryrun"
+	FLAG_REPORT             = "report"
+	FLAG_REPORT_FORMAT      = "reportFormat"
+	FLAG_BACKLINKS          = "backlinks"
+	FLAG_TRANSCLUDE         = "transclude"
+	FLAG_TRANSCLUDE_DEPTH   = "transcludeDepth"
+	FLAG_BLOCK_ANCHOR       = "blockAnchor"
+	FLAG_CALLOUT            = "callout"
+	FLAG_HIGHLIGHT          = "highlight"
+	FLAG_HIGHLIGHT_TMPL     = "highlightTemplate"
+	FLAG_ATTACHMENTS        = "attachments"
+	FLAG_ATTACHMENT_DIR     = "attachmentDir"
+	FLAG_ATTACHMENT_NAME    = "attachmentName"
 )
 
+const (
+	REPORT_LINKS   = "links"
+	REPORT_ORPHANS = "orphans"
+)
+
+var REPORTS = []string{REPORT_LINKS, REPORT_ORPHANS}
+
+const (
+	ATTACHMENTS_ALL        = "all"        // .md 以外のファイルをすべてコピーする
+	ATTACHMENTS_REFERENCED = "referenced" // ノートから参照されているファイルだけコピーする
+)
+
+var ATTACHMENTS_MODES = []string{ATTACHMENTS_ALL, ATTACHMENTS_REFERENCED}
+
 type configuration struct {
-	src         string
-	dst         string
-	tgt         string
-	rmtag       bool
-	cptag       bool
-	synctag     bool
-	title       bool
-	alias       bool
-	synctlal    bool
-	link        bool
-	cmmt        bool
-	publishable bool
-	rmH1        bool
-	strictref   bool
-	remapkey    string
-	filter      string
-	// baseUrl         string
+	src             string
+	dst             string
+	tgt             string
+	rmtag           bool
+	cptag           bool
+	synctag         bool
+	title           bool
+	alias           bool
+	synctlal        bool
+	link            bool
+	cmmt            bool
+	publishable     bool
+	pubRule         string
+	pubMarker       string
+	rmH1            bool
+	strictref       bool
+	remapkey        string
+	yamlRules       string
+	filter          string
+	baseUrl         string
+	permalink       string
 	remapPathPrefix string
+	remapOutput     bool
+	slug            bool
 	formatLink      bool
 	formatAnchor    string
+	linkStyle       string
+	linkTmpl        string
+	imageSize       string
+	embedFallback   string
 	obs             bool
 	std             bool
 	ver             bool
 	debug           bool
+	config          string
+	incr            bool
+	watch           bool
+	dryrun          bool
+	report          string
+	reportFormat    string
+	backlinks       string
+	transclude      bool
+	transcludeDepth int
+	blockAnchor     string
+	callout         string
+	highlight       bool
+	highlightTmpl   string
+	attachments     string
+	attachmentDir   string
+	attachmentName  string
 }
 
 type mainErrKind int
@@ -80,7 +153,47 @@ const (
 	MAIN_ERR_KIND_INVALID_ANCHOR_FORMATTING_STYLE
 	MAIN_ERR_KIND_REMAP_PATH_PREFIX_NEEDS_LINK
 	MAIN_ERR_KIND_INVALID_REMAP_PATH_PREFIX_FORMAT
-	// MAIN_ERR_KIND_BASE_URL_NEEDS_LINK
+	MAIN_ERR_KIND_INVALID_CONFIG_FILE
+	MAIN_ERR_KIND_INCREMENTAL_NEEDS_DESTINATION_DIRECTORY
+	MAIN_ERR_KIND_DRY_RUN_WITH_WATCH
+	MAIN_ERR_KIND_INVALID_REPORT
+	MAIN_ERR_KIND_INVALID_REPORT_FORMAT
+	MAIN_ERR_KIND_INVALID_BACKLINKS_STYLE
+	MAIN_ERR_KIND_BACKLINKS_WITH_INCREMENTAL
+	MAIN_ERR_KIND_INVALID_GRAPH_FORMAT
+	MAIN_ERR_KIND_TRANSCLUDE_NEEDS_LINK
+	MAIN_ERR_KIND_INVALID_TRANSCLUDE_DEPTH
+	MAIN_ERR_KIND_TRANSCLUDE_WITH_INCREMENTAL
+	MAIN_ERR_KIND_INVALID_BLOCK_ANCHOR
+	MAIN_ERR_KIND_INVALID_CALLOUT_STYLE
+	MAIN_ERR_KIND_INVALID_HIGHLIGHT_TEMPLATE
+	MAIN_ERR_KIND_INVALID_LINK_STYLE
+	MAIN_ERR_KIND_FORMAT_LINK_WITH_LINK_STYLE
+	MAIN_ERR_KIND_LINK_TEMPLATE_NEEDS_LINK
+	MAIN_ERR_KIND_INVALID_LINK_TEMPLATE
+	MAIN_ERR_KIND_INVALID_IMAGE_SIZE_STYLE
+	MAIN_ERR_KIND_INVALID_EMBED_FALLBACK
+	MAIN_ERR_KIND_INVALID_ATTACHMENTS_MODE
+	MAIN_ERR_KIND_REFERENCED_ATTACHMENTS_WITH_WATCH
+	MAIN_ERR_KIND_INVALID_ATTACHMENT_NAME
+	MAIN_ERR_KIND_INVALID_ATTACHMENT_DIR
+	MAIN_ERR_KIND_RELOCATING_ATTACHMENTS_NEEDS_LINK
+	MAIN_ERR_KIND_HASHED_ATTACHMENT_NAME_WITH_WATCH
+	MAIN_ERR_KIND_REMAP_OUTPUT_NEEDS_REMAP_PATH_PREFIX
+	MAIN_ERR_KIND_INVALID_REMAP_OUTPUT_PREFIX
+	MAIN_ERR_KIND_REMAP_OUTPUT_NEEDS_DESTINATION_DIRECTORY
+	MAIN_ERR_KIND_REMAP_OUTPUT_WITH_WATCH
+	MAIN_ERR_KIND_SLUG_NEEDS_LINK
+	MAIN_ERR_KIND_SLUG_NEEDS_DESTINATION_DIRECTORY
+	MAIN_ERR_KIND_SLUG_WITH_WATCH
+	MAIN_ERR_KIND_BASE_URL_NEEDS_LINK
+	MAIN_ERR_KIND_INVALID_BASE_URL
+	MAIN_ERR_KIND_PERMALINK_NEEDS_LINK
+	MAIN_ERR_KIND_INVALID_PERMALINK
+	MAIN_ERR_KIND_PERMALINK_WITH_LINK_STYLE
+	MAIN_ERR_KIND_PERMALINK_WITH_WATCH
+	MAIN_ERR_KIND_INVALID_PUB_MARKER
+	MAIN_ERR_KIND_INVALID_YAML_RULES
 )
 
 type mainErr interface {
@@ -122,12 +235,92 @@ func newMainErr(kind mainErrKind) mainErr {
 		err.message = fmt.Sprintf("%s has an invalid format", FLAG_FILTER)
 	case MAIN_ERR_KIND_INVALID_ANCHOR_FORMATTING_STYLE:
 		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_FORMAT_ANCHOR, strings.Join(convert.ANCHOR_FORMATTING_STYLES, ", "))
-	// case MAIN_ERR_KIND_BASE_URL_NEEDS_LINK:
-	// 	err.message = fmt.Sprintf("%s set but not %s", FLAG_BASE_URL, FLAG_CONVERT_LINKS)
+	case MAIN_ERR_KIND_BASE_URL_NEEDS_LINK:
+		err.message = fmt.Sprintf("%s set but not %s", FLAG_BASE_URL, FLAG_CONVERT_LINKS)
+	case MAIN_ERR_KIND_INVALID_BASE_URL:
+		err.message = fmt.Sprintf("%s must be an absolute URL such as https://example.com/", FLAG_BASE_URL)
+	case MAIN_ERR_KIND_PERMALINK_NEEDS_LINK:
+		err.message = fmt.Sprintf("%s set but not %s", FLAG_PERMALINK, FLAG_CONVERT_LINKS)
+	case MAIN_ERR_KIND_INVALID_PERMALINK:
+		err.message = fmt.Sprintf("%s has an invalid format", FLAG_PERMALINK)
+	case MAIN_ERR_KIND_PERMALINK_WITH_LINK_STYLE:
+		err.message = fmt.Sprintf("%s and %s cannot be set with %s other than %s", FLAG_BASE_URL, FLAG_PERMALINK, FLAG_LINK_STYLE, convert.LINK_STYLE_PATH)
+	case MAIN_ERR_KIND_PERMALINK_WITH_WATCH:
+		err.message = fmt.Sprintf("%s and %s cannot be set with %s", FLAG_BASE_URL, FLAG_PERMALINK, FLAG_WATCH)
+	case MAIN_ERR_KIND_INVALID_YAML_RULES:
+		err.message = fmt.Sprintf("%s has an invalid format", FLAG_YAML_RULES)
+	case MAIN_ERR_KIND_INVALID_PUB_MARKER:
+		err.message = fmt.Sprintf("%s is invalid. must be %s, %s or key=value", FLAG_PUB_MARKER, PUB_MARKER_DRAFT, PUB_MARKER_NONE)
 	case MAIN_ERR_KIND_FORMAT_LINK_NEEDS_LINK:
 		err.message = fmt.Sprintf("%s set but not %s", FLAG_FORMAT_LINK, FLAG_CONVERT_LINKS)
 	case MAIN_ERR_KIND_REMAP_PATH_PREFIX_NEEDS_LINK:
 		err.message = fmt.Sprintf("%s set but not %s", FLAG_REMAP_PATH_PREFIX, FLAG_CONVERT_LINKS)
+	case MAIN_ERR_KIND_INVALID_CONFIG_FILE:
+		err.message = fmt.Sprintf("file specified by %s is invalid", FLAG_CONFIG)
+	case MAIN_ERR_KIND_INCREMENTAL_NEEDS_DESTINATION_DIRECTORY:
+		err.message = fmt.Sprintf("%s set but %s is not a directory", FLAG_INCREMENTAL, FLAG_DESTINATION)
+	case MAIN_ERR_KIND_DRY_RUN_WITH_WATCH:
+		err.message = fmt.Sprintf("%s and %s cannot be set at the same time", FLAG_DRY_RUN, FLAG_WATCH)
+	case MAIN_ERR_KIND_INVALID_REPORT:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_REPORT, strings.Join(REPORTS, ", "))
+	case MAIN_ERR_KIND_INVALID_REPORT_FORMAT:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_REPORT_FORMAT, strings.Join(process.REPORT_FORMATS, ", "))
+	case MAIN_ERR_KIND_INVALID_BACKLINKS_STYLE:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_BACKLINKS, strings.Join(BACKLINKS_STYLES, ", "))
+	case MAIN_ERR_KIND_BACKLINKS_WITH_INCREMENTAL:
+		err.message = fmt.Sprintf("%s cannot be set with %s or %s", FLAG_BACKLINKS, FLAG_INCREMENTAL, FLAG_WATCH)
+	case MAIN_ERR_KIND_INVALID_GRAPH_FORMAT:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_GRAPH_FORMAT, strings.Join(process.GRAPH_FORMATS, ", "))
+	case MAIN_ERR_KIND_TRANSCLUDE_NEEDS_LINK:
+		err.message = fmt.Sprintf("%s set but not %s", FLAG_TRANSCLUDE, FLAG_CONVERT_LINKS)
+	case MAIN_ERR_KIND_INVALID_TRANSCLUDE_DEPTH:
+		err.message = fmt.Sprintf("%s must be positive", FLAG_TRANSCLUDE_DEPTH)
+	case MAIN_ERR_KIND_TRANSCLUDE_WITH_INCREMENTAL:
+		err.message = fmt.Sprintf("%s cannot be set with %s or %s", FLAG_TRANSCLUDE, FLAG_INCREMENTAL, FLAG_WATCH)
+	case MAIN_ERR_KIND_INVALID_BLOCK_ANCHOR:
+		err.message = fmt.Sprintf("%s must contain {id}", FLAG_BLOCK_ANCHOR)
+	case MAIN_ERR_KIND_INVALID_CALLOUT_STYLE:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_CALLOUT, strings.Join(convert.CALLOUT_STYLES, ", "))
+	case MAIN_ERR_KIND_INVALID_HIGHLIGHT_TEMPLATE:
+		err.message = fmt.Sprintf("%s must contain {text}", FLAG_HIGHLIGHT_TMPL)
+	case MAIN_ERR_KIND_INVALID_LINK_STYLE:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_LINK_STYLE, strings.Join(convert.LINK_STYLES, ", "))
+	case MAIN_ERR_KIND_FORMAT_LINK_WITH_LINK_STYLE:
+		err.message = fmt.Sprintf("%s cannot be set with %s other than %s", FLAG_FORMAT_LINK, FLAG_LINK_STYLE, convert.LINK_STYLE_PATH)
+	case MAIN_ERR_KIND_LINK_TEMPLATE_NEEDS_LINK:
+		err.message = fmt.Sprintf("%s set but not %s", FLAG_LINK_TEMPLATE, FLAG_CONVERT_LINKS)
+	case MAIN_ERR_KIND_INVALID_LINK_TEMPLATE:
+		err.message = fmt.Sprintf("%s is not a valid template", FLAG_LINK_TEMPLATE)
+	case MAIN_ERR_KIND_INVALID_IMAGE_SIZE_STYLE:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_IMAGE_SIZE, strings.Join(convert.IMAGE_SIZE_STYLES, ", "))
+	case MAIN_ERR_KIND_INVALID_EMBED_FALLBACK:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_EMBED_FALLBACK, strings.Join(convert.EMBED_FALLBACKS, ", "))
+	case MAIN_ERR_KIND_INVALID_ATTACHMENTS_MODE:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_ATTACHMENTS, strings.Join(ATTACHMENTS_MODES, ", "))
+	case MAIN_ERR_KIND_REFERENCED_ATTACHMENTS_WITH_WATCH:
+		err.message = fmt.Sprintf("%s=%s cannot be set with %s", FLAG_ATTACHMENTS, ATTACHMENTS_REFERENCED, FLAG_WATCH)
+	case MAIN_ERR_KIND_INVALID_ATTACHMENT_NAME:
+		err.message = fmt.Sprintf("%s is invalid. must choose from %s", FLAG_ATTACHMENT_NAME, strings.Join(process.ATTACHMENT_NAMES, ", "))
+	case MAIN_ERR_KIND_INVALID_ATTACHMENT_DIR:
+		err.message = fmt.Sprintf("%s must be a relative path inside %s", FLAG_ATTACHMENT_DIR, FLAG_DESTINATION)
+	case MAIN_ERR_KIND_RELOCATING_ATTACHMENTS_NEEDS_LINK:
+		err.message = fmt.Sprintf("%s or %s=%s set but not %s", FLAG_ATTACHMENT_DIR, FLAG_ATTACHMENT_NAME, process.ATTACHMENT_NAME_HASH, FLAG_CONVERT_LINKS)
+	case MAIN_ERR_KIND_HASHED_ATTACHMENT_NAME_WITH_WATCH:
+		err.message = fmt.Sprintf("%s=%s cannot be set with %s", FLAG_ATTACHMENT_NAME, process.ATTACHMENT_NAME_HASH, FLAG_WATCH)
+	case MAIN_ERR_KIND_REMAP_OUTPUT_NEEDS_REMAP_PATH_PREFIX:
+		err.message = fmt.Sprintf("%s set but not %s", FLAG_REMAP_OUTPUT, FLAG_REMAP_PATH_PREFIX)
+	case MAIN_ERR_KIND_INVALID_REMAP_OUTPUT_PREFIX:
+		err.message = fmt.Sprintf("new prefixes in %s must be relative paths inside %s when %s is set", FLAG_REMAP_PATH_PREFIX, FLAG_DESTINATION, FLAG_REMAP_OUTPUT)
+	case MAIN_ERR_KIND_REMAP_OUTPUT_NEEDS_DESTINATION_DIRECTORY:
+		err.message = fmt.Sprintf("%s set but %s is not a directory", FLAG_REMAP_OUTPUT, FLAG_DESTINATION)
+	case MAIN_ERR_KIND_REMAP_OUTPUT_WITH_WATCH:
+		err.message = fmt.Sprintf("%s cannot be set with %s", FLAG_REMAP_OUTPUT, FLAG_WATCH)
+	case MAIN_ERR_KIND_SLUG_NEEDS_LINK:
+		err.message = fmt.Sprintf("%s set but not %s", FLAG_SLUG, FLAG_CONVERT_LINKS)
+	case MAIN_ERR_KIND_SLUG_NEEDS_DESTINATION_DIRECTORY:
+		err.message = fmt.Sprintf("%s set but %s is not a directory", FLAG_SLUG, FLAG_DESTINATION)
+	case MAIN_ERR_KIND_SLUG_WITH_WATCH:
+		err.message = fmt.Sprintf("%s cannot be set with %s", FLAG_SLUG, FLAG_WATCH)
 	default:
 		err.kind = MAIN_ERR_UNEXPECTED
 		err.message = "unexpected error"
@@ -156,19 +349,45 @@ func initFlags(flagset *flag.FlagSet, config *configuration) {
 	flagset.BoolVar(&config.synctlal, FLAG_SYNC_TITLE_ALIASES, false, "remove an alias appearing also in title field and then copy h1 content to title and aliases fields")
 	flagset.BoolVar(&config.link, FLAG_CONVERT_LINKS, false, "convert obsidian internal and external links to external links in the usual format")
 	flagset.BoolVar(&config.cmmt, FLAG_REMOVE_COMMENT, false, "remove obsidian comment")
-	flagset.BoolVar(&config.publishable, FLAG_PUBLISHABLE, false, "process only files with publish: true or draft: false. For files with publish: true, add draft: false.")
+	flagset.BoolVar(&config.publishable, FLAG_PUBLISHABLE, false, fmt.Sprintf("process only files satisfying %s. By default, files with publish: true or draft: false. Add draft: false to them unless draft exists (see %s).", FLAG_PUB_RULE, FLAG_PUB_MARKER))
+	flagset.StringVar(&config.pubRule, FLAG_PUB_RULE, DEFAULT_PUB_RULE, fmt.Sprintf("condition for files to be published, written in the same way as %s. Example: -pubRule=\"status == 'published' && visibility == 'public' && publishDate <= now\". available only when %s is on", FLAG_FILTER, FLAG_PUBLISHABLE))
+	flagset.StringVar(&config.pubMarker, FLAG_PUB_MARKER, PUB_MARKER_DRAFT, fmt.Sprintf("what to write into front matter of published files. %s: add draft: false unless draft exists. %s: nothing. key=value: set key to value (e.g. status=published). available only when %s is on", PUB_MARKER_DRAFT, PUB_MARKER_NONE, FLAG_PUBLISHABLE))
 	flagset.BoolVar(&config.rmH1, FLAG_REMOVE_H1, false, "remove H1")
 	flagset.BoolVar(&config.strictref, FLAG_STRICT_REF, false, fmt.Sprintf("return error when ref target is not found. available only when %s is on", FLAG_CONVERT_LINKS))
 	flagset.StringVar(&config.remapkey, FLAG_REMAP_META_KEYS, "", "remap keys in front matter. format: \"old1:new1,old2:new2\". If a new key is not specified (i.e., empty string), then the field will be removed.")
-	flagset.StringVar(&config.filter, FLAG_FILTER, "", "process only files with specified conditions. Example: -filter=\"(key1||!key2)&&key3\". Each field must be boolean and each key must match /[a-zA-Z-_]+/.")
-	// flagset.StringVar(&config.baseUrl, FLAG_BASE_URL, "", "prefix resolved internal links and format it. Example (-baseUrl=https://example.com/): sample -> https://example.com/sample")
+	flagset.StringVar(&config.yamlRules, FLAG_YAML_RULES, "", fmt.Sprintf("yaml file of rules that set defaults, convert types (%s), transform values (%s) and compute values from templates in front matter. applied after %s", strings.Join(YAML_RULE_TYPES, ", "), strings.Join(YAML_RULE_TRANSFORMS, ", "), FLAG_REMAP_META_KEYS))
+	flagset.StringVar(&config.filter, FLAG_FILTER, "", "process only files whose front matter satisfies the condition. Example: -filter=\"(draft == false || has(publishDate)) && 'blog' in tags && date >= 2024-01-01\". Supports &&, ||, !, ==, !=, <, <=, >, >=, in, =~ (regular expression), !~, has(key), tag:name and path:pattern (** matches any directories, a trailing / matches everything under the directory). Each key must match /[0-9a-zA-Z-_]+/.")
+	flagset.StringVar(&config.baseUrl, FLAG_BASE_URL, "", fmt.Sprintf("prefix resolved links to make absolute URLs. Example (-baseUrl=https://example.com/): sample -> https://example.com/sample. %s and %s in front matter are also used as in Hugo. available only when %s is on", process.PERMALINK_URL_KEY, process.SLUG_KEY, FLAG_CONVERT_LINKS))
+	flagset.StringVar(&config.permalink, FLAG_PERMALINK, "", fmt.Sprintf("Hugo-style permalink patterns for links to notes. Example: -permalink=\"posts/>/posts/:year/:slug/|/:filename/\". A pattern without a prefix applies to all the other notes. Available tokens: %s. available only when %s is on", strings.Join(process.PERMALINK_TOKENS, ", "), FLAG_CONVERT_LINKS))
 	flagset.StringVar(&config.remapPathPrefix, FLAG_REMAP_PATH_PREFIX, "", "remap prefixes in paths. Example (-remapPrefix=static/>images/|notes/>posts/): static/sample.png -> images/sample.png, notes/sample.md -> posts/sample.md")
+	flagset.BoolVar(&config.remapOutput, FLAG_REMAP_OUTPUT, false, fmt.Sprintf("place output files at the paths remapped by %s so that links point to them", FLAG_REMAP_PATH_PREFIX))
+	flagset.BoolVar(&config.slug, FLAG_SLUG, false, fmt.Sprintf("use slugs (lowercase, accents removed, hyphens between words) as file and directory names of notes and in links to them. %s in front matter overrides the file name. Attachments keep their directories. available only when %s is on", process.SLUG_KEY, FLAG_CONVERT_LINKS))
 	flagset.BoolVar(&config.formatLink, FLAG_FORMAT_LINK, false, "trim suffix .md and complete link. Example: #section -> path/to/sample#section")
 	flagset.StringVar(&config.formatAnchor, FLAG_FORMAT_ANCHOR, convert.FORMAT_ANCHOR_HUGO, fmt.Sprintf("anchor formatting style. Available styles: %s", strings.Join(convert.ANCHOR_FORMATTING_STYLES, ", ")))
+	flagset.StringVar(&config.linkStyle, FLAG_LINK_STYLE, convert.LINK_STYLE_PATH, fmt.Sprintf("output style of links to notes. Available styles: %s ([text](path/to/note.md#anchor)), %s and %s ([text]({{< relref \"path/to/note.md#anchor\" >}}) for Hugo)", convert.LINK_STYLE_PATH, convert.LINK_STYLE_REF, convert.LINK_STYLE_RELREF))
+	flagset.StringVar(&config.linkTmpl, FLAG_LINK_TEMPLATE, "", fmt.Sprintf("text/template that outputs converted links instead of markdown links. Fields: .Kind (%s), .Text, .Path, .Anchor, .Fragments, .Title, .Ref. available only when %s is on", strings.Join([]string{convert.LINK_DATA_KIND_INTERNAL, convert.LINK_DATA_KIND_EMBED, convert.LINK_DATA_KIND_EXTERNAL, convert.LINK_DATA_KIND_URI}, ", "), FLAG_CONVERT_LINKS))
+	flagset.StringVar(&config.imageSize, FLAG_IMAGE_SIZE, convert.IMAGE_SIZE_STYLE_HTML, fmt.Sprintf("output style of images embedded with width or height (![[image.png|300]], ![[image.png|300x200]]). Available styles: %s (<img> element), %s (figure shortcode). ignored when %s is set", convert.IMAGE_SIZE_STYLE_HTML, convert.IMAGE_SIZE_STYLE_HUGO, FLAG_LINK_TEMPLATE))
+	flagset.StringVar(&config.embedFallback, FLAG_EMBED_FALLBACK, convert.EMBED_FALLBACK_IMAGE, fmt.Sprintf("output style of embedded files other than images, audio, video and PDF. Available styles: %s (![text](path)), %s ([text](path)). ignored when %s is set", convert.EMBED_FALLBACK_IMAGE, convert.EMBED_FALLBACK_LINK, FLAG_LINK_TEMPLATE))
 	flagset.BoolVar(&config.obs, FLAG_OBSIDIAN_USAGE, false, "alias of -cptag -title -alias")
 	flagset.BoolVar(&config.std, FLAG_STANDARD_USAGE, false, "alias of -cptag -rmtag -title -alias -link -cmmt -strictref")
 	flagset.BoolVar(&config.ver, FLAG_VERSION, false, "display the version currently installed")
 	flagset.BoolVar(&config.debug, FLAG_DEBUG, false, "display error message for developers")
+	flagset.BoolVar(&config.incr, FLAG_INCREMENTAL, false, fmt.Sprintf("skip files not changed since the last run. A manifest file %s is written into the destination directory.", process.MANIFEST_FILE_NAME))
+	flagset.BoolVar(&config.watch, FLAG_WATCH, false, "keep running after conversion and convert files again when they are changed")
+	flagset.BoolVar(&config.dryrun, FLAG_DRY_RUN, false, "write nothing but print a unified diff between existing files and files that would be written")
+	flagset.StringVar(&config.backlinks, FLAG_BACKLINKS, "", fmt.Sprintf("add links from other notes. Available styles: %s (backlinks field of front matter), %s (\"%s\" section at the end of the body)", BACKLINKS_YAML, BACKLINKS_SECTION, strings.TrimLeft(BACKLINKS_SECTION_HEADER, "# ")))
+	flagset.BoolVar(&config.transclude, FLAG_TRANSCLUDE, false, fmt.Sprintf("replace embedded notes (![[note]], ![[note#heading]], ![[note#^block]]) with their converted contents. available only when %s is on", FLAG_CONVERT_LINKS))
+	flagset.IntVar(&config.transcludeDepth, FLAG_TRANSCLUDE_DEPTH, DEFAULT_TRANSCLUDE_DEPTH, fmt.Sprintf("how deep embedded notes in embedded notes are replaced with %s", FLAG_TRANSCLUDE))
+	flagset.StringVar(&config.blockAnchor, FLAG_BLOCK_ANCHOR, DEFAULT_BLOCK_ANCHOR, fmt.Sprintf("html that replaces block ids (^id) at the end of paragraphs and list items. {id} is replaced with the id. available only when %s is on", FLAG_CONVERT_LINKS))
+	flagset.StringVar(&config.callout, FLAG_CALLOUT, "", fmt.Sprintf("convert callouts (> [!note] title) into the specified form. Available styles: %s (%s shortcode), %s (<details> element), %s (GitHub alerts)", convert.CALLOUT_STYLE_HUGO, convert.CALLOUT_SHORTCODE_NAME, convert.CALLOUT_STYLE_DETAILS, convert.CALLOUT_STYLE_GITHUB))
+	flagset.BoolVar(&config.highlight, FLAG_HIGHLIGHT, false, fmt.Sprintf("convert highlights (==text==) into html specified by %s", FLAG_HIGHLIGHT_TMPL))
+	flagset.StringVar(&config.highlightTmpl, FLAG_HIGHLIGHT_TMPL, DEFAULT_HIGHLIGHT_TEMPLATE, fmt.Sprintf("html that replaces highlights. {text} is replaced with the highlighted text. available only when %s is on", FLAG_HIGHLIGHT))
+	flagset.StringVar(&config.attachments, FLAG_ATTACHMENTS, ATTACHMENTS_ALL, fmt.Sprintf("which files other than notes are copied. Available modes: %s (every file), %s (only files linked or embedded from notes to be converted)", ATTACHMENTS_ALL, ATTACHMENTS_REFERENCED))
+	flagset.StringVar(&config.attachmentDir, FLAG_ATTACHMENT_DIR, "", fmt.Sprintf("directory relative to %s into which all files other than notes are moved. Links to them are rewritten. available only when %s is on", FLAG_DESTINATION, FLAG_CONVERT_LINKS))
+	flagset.StringVar(&config.attachmentName, FLAG_ATTACHMENT_NAME, process.ATTACHMENT_NAME_ORIGINAL, fmt.Sprintf("file names of files other than notes. Available styles: %s (unchanged), %s (hash of the content with the original extension; files with the same content are copied once). Links to them are rewritten. %s is available only when %s is on", process.ATTACHMENT_NAME_ORIGINAL, process.ATTACHMENT_NAME_HASH, process.ATTACHMENT_NAME_HASH, FLAG_CONVERT_LINKS))
+	flagset.StringVar(&config.report, FLAG_REPORT, "", fmt.Sprintf("write nothing but print a report about the vault. Available reports: %s (unresolved or ambiguous links), %s (files other than notes not linked or embedded from any note to be converted)", REPORT_LINKS, REPORT_ORPHANS))
+	flagset.StringVar(&config.reportFormat, FLAG_REPORT_FORMAT, process.REPORT_FORMAT_TEXT, fmt.Sprintf("format of the report. Available formats: %s", strings.Join(process.REPORT_FORMATS, ", ")))
+	flagset.StringVar(&config.config, FLAG_CONFIG, "", fmt.Sprintf("path to a yaml file setting flags. Each key is a flag name. Flags in the command line, including -obs and -std, override values in the file. If not set, %s in the current directory is used if exists.", DEFAULT_CONFIG_FILE_NAME))
 }
 
 // const FORMAT_ANCHOR_HUGO = "hugo"
@@ -176,12 +395,75 @@ func initFlags(flagset *flag.FlagSet, config *configuration) {
 
 // var ANCHOR_FORMATTING_STYLES = []string{FORMAT_ANCHOR_HUGO, FORMAT_ANCHOR_MARKDOWN_IT}
 
+// 設定ファイルの値を config に反映し, 反映したフラグの名前を返す
+// コマンドラインで指定されたフラグは上書きしない
+// 反映された値は flagset の指定済みのフラグには含まれないので, setConfig に fileflags として渡す
+//
+// 実行前に↓が必要
+// 1. initFlags(flagset, flags)
+// 2. flag の値の設定
+func loadConfigFile(flagset *flag.FlagSet, config *configuration) (fileflags map[string]struct{}, err error) {
+	path := config.config
+	if path == "" {
+		if _, err := os.Stat(DEFAULT_CONFIG_FILE_NAME); err != nil {
+			return nil, nil
+		}
+		path = DEFAULT_CONFIG_FILE_NAME
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newMainErrf(MAIN_ERR_KIND_INVALID_CONFIG_FILE, "failed to read %s: %v", path, err)
+	}
+	entries := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, entries); err != nil {
+		return nil, newMainErrf(MAIN_ERR_KIND_INVALID_CONFIG_FILE, "failed to parse %s: %v", path, err)
+	}
+
+	setflags := make(map[string]struct{})
+	flagset.Visit(func(f *flag.Flag) {
+		setflags[f.Name] = struct{}{}
+	})
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fileflags = make(map[string]struct{})
+	for _, key := range keys {
+		f := flagset.Lookup(key)
+		if key == FLAG_CONFIG || key == FLAG_VERSION || f == nil {
+			return nil, newMainErrf(MAIN_ERR_KIND_INVALID_CONFIG_FILE, "unknown key in %s: %q", path, key)
+		}
+		if _, ok := setflags[key]; ok {
+			continue
+		}
+		value := entries[key]
+		switch value.(type) {
+		case bool, int, float64, string:
+		default:
+			return nil, newMainErrf(MAIN_ERR_KIND_INVALID_CONFIG_FILE, "value of %q in %s must be a boolean, number or string: %v", key, path, value)
+		}
+		// flagset.Set と違い, 指定済みのフラグとして記録されない
+		if err := f.Value.Set(fmt.Sprint(value)); err != nil {
+			return nil, newMainErrf(MAIN_ERR_KIND_INVALID_CONFIG_FILE, "invalid value of %q in %s: %v", key, path, err)
+		}
+		fileflags[key] = struct{}{}
+	}
+	return fileflags, nil
+}
+
 // 実行前に↓が必要
 // 1. initFlags(flagset, flags)
 // 2. flag の値の設定
 // 	- flag.CommandLine => flag.Parse()
 //	- それ以外 => flagset.Set("フラグ名", "フラグの値を表す文字列")
-func setConfig(flagset *flag.FlagSet, config *configuration) {
+//
+// fileflags は loadConfigFile で設定ファイルから反映したフラグ
+// 優先順位は, コマンドラインの個別のフラグ > コマンドラインの -obs, -std > 設定ファイルの個別のフラグ > 設定ファイルの -obs, -std
+func setConfig(flagset *flag.FlagSet, config *configuration, fileflags map[string]struct{}) {
 	orgFlag := *config
 	setflags := make(map[string]struct{})
 	flagset.Visit(func(f *flag.Flag) {
@@ -201,31 +483,44 @@ func setConfig(flagset *flag.FlagSet, config *configuration) {
 	}
 	config.tgt = config.src
 
-	if _, ok := setflags[FLAG_COPY_TAGS]; ok {
+	_, obsByCmd := setflags[FLAG_OBSIDIAN_USAGE]
+	obsByCmd = obsByCmd && config.obs
+	_, stdByCmd := setflags[FLAG_STANDARD_USAGE]
+	stdByCmd = stdByCmd && config.std
+	// presetByCmd はコマンドラインの -obs, -std が name を変えるかどうか
+	keeps := func(name string, presetByCmd bool) bool {
+		if _, ok := setflags[name]; ok {
+			return true
+		}
+		_, ok := fileflags[name]
+		return ok && !presetByCmd
+	}
+
+	if keeps(FLAG_COPY_TAGS, obsByCmd || stdByCmd) {
 		config.cptag = orgFlag.cptag
 	}
-	if _, ok := setflags[FLAG_COPY_TITLE]; ok {
+	if keeps(FLAG_COPY_TITLE, obsByCmd || stdByCmd) {
 		config.title = orgFlag.title
 	}
-	if _, ok := setflags[FLAG_COPY_ALIASES]; ok {
+	if keeps(FLAG_COPY_ALIASES, obsByCmd || stdByCmd) {
 		config.alias = orgFlag.alias
 	}
-	if _, ok := setflags[FLAG_REMOVE_TAGS]; ok {
+	if keeps(FLAG_REMOVE_TAGS, stdByCmd) {
 		config.rmtag = orgFlag.rmtag
 	}
-	if _, ok := setflags[FLAG_CONVERT_LINKS]; ok {
+	if keeps(FLAG_CONVERT_LINKS, stdByCmd) {
 		config.link = orgFlag.link
 	}
-	if _, ok := setflags[FLAG_REMOVE_COMMENT]; ok {
+	if keeps(FLAG_REMOVE_COMMENT, stdByCmd) {
 		config.cmmt = orgFlag.cmmt
 	}
-	if _, ok := setflags[FLAG_PUBLISHABLE]; ok {
+	if keeps(FLAG_PUBLISHABLE, false) {
 		config.publishable = orgFlag.publishable
 	}
-	if _, ok := setflags[FLAG_STRICT_REF]; ok {
+	if keeps(FLAG_STRICT_REF, stdByCmd) {
 		config.strictref = orgFlag.strictref
 	}
-	if _, ok := setflags[FLAG_TARGET]; ok {
+	if keeps(FLAG_TARGET, false) {
 		config.tgt = orgFlag.tgt
 	}
 }
@@ -234,6 +529,10 @@ func verifyConfig(config *configuration) error {
 	if config.src == "" {
 		return newMainErr(MAIN_ERR_KIND_SOURCE_NOT_SET)
 	}
+	// レポートでは何も書き込まないので dst は不要
+	if config.report != "" {
+		return verifyReportConfig(config)
+	}
 	if config.dst == "" {
 		return newMainErr(MAIN_ERR_KIND_DESTINATION_NOT_SET)
 	}
@@ -246,9 +545,51 @@ func verifyConfig(config *configuration) error {
 	if config.strictref && !config.link {
 		return newMainErr(MAIN_ERR_KIND_STRICTREF_NEEDS_LINK)
 	}
-	// if config.baseUrl != "" && !config.link {
-	// 	return newMainErr(MAIN_ERR_KIND_BASE_URL_NEEDS_LINK)
-	// }
+	if config.filter != "" {
+		if _, err := parseFilter(config.filter); err != nil {
+			return err
+		}
+	}
+	if config.yamlRules != "" {
+		if _, err := loadYamlRules(config.yamlRules); err != nil {
+			return err
+		}
+	}
+	if config.publishable {
+		if _, err := parseFilter(config.pubRule); err != nil {
+			return filterErrFor(FLAG_PUB_RULE, err)
+		}
+		if _, err := parsePubMarker(config.pubMarker); err != nil {
+			return err
+		}
+	}
+	if config.baseUrl != "" {
+		if !config.link {
+			return newMainErr(MAIN_ERR_KIND_BASE_URL_NEEDS_LINK)
+		}
+		u, err := url.Parse(config.baseUrl)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return newMainErr(MAIN_ERR_KIND_INVALID_BASE_URL)
+		}
+	}
+	if config.permalink != "" {
+		if !config.link {
+			return newMainErr(MAIN_ERR_KIND_PERMALINK_NEEDS_LINK)
+		}
+		if _, err := parsePermalinks(config.permalink); err != nil {
+			return err
+		}
+	}
+	if usesPermalinks(config) {
+		// ref と relref には permalink ではなくノートのパスを渡す
+		if config.linkStyle != convert.LINK_STYLE_PATH {
+			return newMainErr(MAIN_ERR_KIND_PERMALINK_WITH_LINK_STYLE)
+		}
+		// 監視中に front matter が変わっても permalink を更新できない
+		if config.watch {
+			return newMainErr(MAIN_ERR_KIND_PERMALINK_WITH_WATCH)
+		}
+	}
 	var validAnchorFormattingStyle bool
 	for _, style := range convert.ANCHOR_FORMATTING_STYLES {
 		if config.formatAnchor == style {
@@ -259,6 +600,48 @@ func verifyConfig(config *configuration) error {
 	if !validAnchorFormattingStyle {
 		return newMainErr(MAIN_ERR_KIND_INVALID_ANCHOR_FORMATTING_STYLE)
 	}
+	var validLinkStyle bool
+	for _, style := range convert.LINK_STYLES {
+		if config.linkStyle == style {
+			validLinkStyle = true
+			break
+		}
+	}
+	if !validLinkStyle {
+		return newMainErr(MAIN_ERR_KIND_INVALID_LINK_STYLE)
+	}
+	// ref と relref には拡張子付きのパスをそのまま渡す
+	if config.formatLink && config.linkStyle != convert.LINK_STYLE_PATH {
+		return newMainErr(MAIN_ERR_KIND_FORMAT_LINK_WITH_LINK_STYLE)
+	}
+	var validImageSizeStyle bool
+	for _, style := range convert.IMAGE_SIZE_STYLES {
+		if config.imageSize == style {
+			validImageSizeStyle = true
+			break
+		}
+	}
+	if !validImageSizeStyle {
+		return newMainErr(MAIN_ERR_KIND_INVALID_IMAGE_SIZE_STYLE)
+	}
+	var validEmbedFallback bool
+	for _, fallback := range convert.EMBED_FALLBACKS {
+		if config.embedFallback == fallback {
+			validEmbedFallback = true
+			break
+		}
+	}
+	if !validEmbedFallback {
+		return newMainErr(MAIN_ERR_KIND_INVALID_EMBED_FALLBACK)
+	}
+	if config.linkTmpl != "" {
+		if !config.link {
+			return newMainErr(MAIN_ERR_KIND_LINK_TEMPLATE_NEEDS_LINK)
+		}
+		if _, err := convert.NewTemplateLinkRenderer(config.linkTmpl); err != nil {
+			return newMainErrf(MAIN_ERR_KIND_INVALID_LINK_TEMPLATE, "%s is not a valid template: %v", FLAG_LINK_TEMPLATE, err)
+		}
+	}
 
 	if config.remapPathPrefix != "" && !config.link {
 		return newMainErr(MAIN_ERR_KIND_INVALID_REMAP_FORMAT)
@@ -273,5 +656,218 @@ func verifyConfig(config *configuration) error {
 	if filepath.Ext(config.tgt) != ".md" && filepath.Ext(config.dst) == ".md" {
 		return newMainErrf(MAIN_ERR_KIND_DESTINATION_IS_MARKDOWN_FILE_BUT_TARGET_IS_NOT, "%s is a markdown file but %s is not", config.dst, config.tgt)
 	}
+	if config.incr && filepath.Ext(config.dst) == ".md" {
+		return newMainErr(MAIN_ERR_KIND_INCREMENTAL_NEEDS_DESTINATION_DIRECTORY)
+	}
+	if config.dryrun && config.watch {
+		return newMainErr(MAIN_ERR_KIND_DRY_RUN_WITH_WATCH)
+	}
+	if config.backlinks != "" {
+		var validBacklinksStyle bool
+		for _, style := range BACKLINKS_STYLES {
+			if config.backlinks == style {
+				validBacklinksStyle = true
+				break
+			}
+		}
+		if !validBacklinksStyle {
+			return newMainErr(MAIN_ERR_KIND_INVALID_BACKLINKS_STYLE)
+		}
+		// 他のノートの変更でリンク元が変わっても検出できない
+		if config.incr || config.watch {
+			return newMainErr(MAIN_ERR_KIND_BACKLINKS_WITH_INCREMENTAL)
+		}
+	}
+	if config.transclude {
+		if !config.link {
+			return newMainErr(MAIN_ERR_KIND_TRANSCLUDE_NEEDS_LINK)
+		}
+		if config.transcludeDepth <= 0 {
+			return newMainErr(MAIN_ERR_KIND_INVALID_TRANSCLUDE_DEPTH)
+		}
+		// 埋め込まれたノートの変更を検出できない
+		if config.incr || config.watch {
+			return newMainErr(MAIN_ERR_KIND_TRANSCLUDE_WITH_INCREMENTAL)
+		}
+	}
+	// リンクの #^id がアンカーを指せなくなる
+	if config.link && !strings.Contains(config.blockAnchor, "{id}") {
+		return newMainErr(MAIN_ERR_KIND_INVALID_BLOCK_ANCHOR)
+	}
+	if config.callout != "" {
+		var validCalloutStyle bool
+		for _, style := range convert.CALLOUT_STYLES {
+			if config.callout == style {
+				validCalloutStyle = true
+				break
+			}
+		}
+		if !validCalloutStyle {
+			return newMainErr(MAIN_ERR_KIND_INVALID_CALLOUT_STYLE)
+		}
+	}
+	if config.highlight && !strings.Contains(config.highlightTmpl, "{text}") {
+		return newMainErr(MAIN_ERR_KIND_INVALID_HIGHLIGHT_TEMPLATE)
+	}
+	var validAttachmentsMode bool
+	for _, mode := range ATTACHMENTS_MODES {
+		if config.attachments == mode {
+			validAttachmentsMode = true
+			break
+		}
+	}
+	if !validAttachmentsMode {
+		return newMainErr(MAIN_ERR_KIND_INVALID_ATTACHMENTS_MODE)
+	}
+	// ノートの変更で新たに参照されたファイルを検出できない
+	if config.attachments == ATTACHMENTS_REFERENCED && config.watch {
+		return newMainErr(MAIN_ERR_KIND_REFERENCED_ATTACHMENTS_WITH_WATCH)
+	}
+	var validAttachmentName bool
+	for _, name := range process.ATTACHMENT_NAMES {
+		if config.attachmentName == name {
+			validAttachmentName = true
+			break
+		}
+	}
+	if !validAttachmentName {
+		return newMainErr(MAIN_ERR_KIND_INVALID_ATTACHMENT_NAME)
+	}
+	if config.attachmentDir != "" {
+		dir := filepath.ToSlash(filepath.Clean(config.attachmentDir))
+		if filepath.IsAbs(config.attachmentDir) || dir == ".." || strings.HasPrefix(dir, "../") {
+			return newMainErr(MAIN_ERR_KIND_INVALID_ATTACHMENT_DIR)
+		}
+	}
+	if relocatesAttachments(config) && !config.link {
+		return newMainErr(MAIN_ERR_KIND_RELOCATING_ATTACHMENTS_NEEDS_LINK)
+	}
+	if config.remapOutput {
+		if err := verifyRemapOutput(config); err != nil {
+			return err
+		}
+	}
+	if config.slug {
+		if !config.link {
+			return newMainErr(MAIN_ERR_KIND_SLUG_NEEDS_LINK)
+		}
+		if filepath.Ext(config.dst) == ".md" {
+			return newMainErr(MAIN_ERR_KIND_SLUG_NEEDS_DESTINATION_DIRECTORY)
+		}
+		// 監視中に slug が変わったノートの古い出力を削除できない
+		if config.watch {
+			return newMainErr(MAIN_ERR_KIND_SLUG_WITH_WATCH)
+		}
+	}
+	// 監視中に内容が変わってもファイル名を変えられない
+	if config.attachmentName == process.ATTACHMENT_NAME_HASH && config.watch {
+		return newMainErr(MAIN_ERR_KIND_HASHED_ATTACHMENT_NAME_WITH_WATCH)
+	}
 	return nil
 }
+
+func verifyRemapOutput(config *configuration) error {
+	if config.remapPathPrefix == "" {
+		return newMainErr(MAIN_ERR_KIND_REMAP_OUTPUT_NEEDS_REMAP_PATH_PREFIX)
+	}
+	remap, err := parsePathPrefixRemap(config.remapPathPrefix)
+	if err != nil {
+		return err
+	}
+	for _, newPrefix := range remap {
+		// 先頭の / は dst を表す
+		prefix := strings.TrimPrefix(newPrefix, "/")
+		if strings.Contains(prefix, "://") || filepath.IsAbs(prefix) {
+			return newMainErr(MAIN_ERR_KIND_INVALID_REMAP_OUTPUT_PREFIX)
+		}
+		for _, segment := range strings.Split(filepath.ToSlash(prefix), "/") {
+			if segment == ".." {
+				return newMainErr(MAIN_ERR_KIND_INVALID_REMAP_OUTPUT_PREFIX)
+			}
+		}
+	}
+	if filepath.Ext(config.dst) == ".md" {
+		return newMainErr(MAIN_ERR_KIND_REMAP_OUTPUT_NEEDS_DESTINATION_DIRECTORY)
+	}
+	// 監視中に出力先が変わったファイルの古い出力を削除できない
+	if config.watch {
+		return newMainErr(MAIN_ERR_KIND_REMAP_OUTPUT_WITH_WATCH)
+	}
+	return nil
+}
+
+// front matter の url や slug, permalink のパターンからリンク先を決めるかどうか
+func usesPermalinks(config *configuration) bool {
+	return config.baseUrl != "" || config.permalink != ""
+}
+
+// 添付ファイルのコピー先やファイル名を変えるかどうか
+func relocatesAttachments(config *configuration) bool {
+	return config.attachmentDir != "" || config.attachmentName == process.ATTACHMENT_NAME_HASH
+}
+
+func verifyReportConfig(config *configuration) error {
+	if strings.HasPrefix(config.src, "-") {
+		return newMainErr(MAIN_ERR_KIND_INVALID_SOURCE_FORMAT)
+	}
+	var validReport bool
+	for _, report := range REPORTS {
+		if config.report == report {
+			validReport = true
+			break
+		}
+	}
+	if !validReport {
+		return newMainErr(MAIN_ERR_KIND_INVALID_REPORT)
+	}
+	var validFormat bool
+	for _, format := range process.REPORT_FORMATS {
+		if config.reportFormat == format {
+			validFormat = true
+			break
+		}
+	}
+	if !validFormat {
+		return newMainErr(MAIN_ERR_KIND_INVALID_REPORT_FORMAT)
+	}
+	// orphans では filter と pubRule で対象のノートを絞る
+	if config.filter != "" {
+		if _, err := parseFilter(config.filter); err != nil {
+			return err
+		}
+	}
+	if config.publishable {
+		if _, err := parseFilter(config.pubRule); err != nil {
+			return filterErrFor(FLAG_PUB_RULE, err)
+		}
+	}
+	return nil
+}
+
+// 出力に影響するオプションのハッシュ値
+// -incr で前回の実行とオプションが変わったかどうかの判定に使う
+func optionsHash(version string, config *configuration) string {
+	c := *config
+	c.src = ""
+	c.dst = ""
+	c.tgt = ""
+	c.ver = false
+	c.debug = false
+	c.config = ""
+	c.incr = false
+	c.watch = false
+	c.dryrun = false
+	c.report = ""
+	c.reportFormat = ""
+	// yamlRules はパスが同じでも内容が変わりうるので, 内容も含める
+	var rules []byte
+	if config.yamlRules != "" {
+		content, err := os.ReadFile(config.yamlRules)
+		if err != nil {
+			content = []byte(err.Error())
+		}
+		rules = content
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s %+v %x", version, c, sha256.Sum256(rules))))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/qawatake/obsdconv/convert"
+	"github.com/qawatake/obsdconv/process"
 )
 
 func TestSetConfig(t *testing.T) {
@@ -22,14 +25,25 @@ func TestSetConfig(t *testing.T) {
 				FLAG_OBSIDIAN_USAGE: "1",
 			},
 			wantConfig: configuration{
-				src:          "src",
-				dst:          "dst",
-				cptag:        true,
-				title:        true,
-				alias:        true,
-				obs:          true,
-				tgt:          "src",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:             "src",
+				dst:             "dst",
+				cptag:           true,
+				title:           true,
+				alias:           true,
+				obs:             true,
+				tgt:             "src",
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				pubRule:         DEFAULT_PUB_RULE,
+				pubMarker:       PUB_MARKER_DRAFT,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
 			},
 		},
 		{
@@ -40,18 +54,29 @@ func TestSetConfig(t *testing.T) {
 				FLAG_STANDARD_USAGE: "1",
 			},
 			wantConfig: configuration{
-				src:          "src",
-				dst:          "dst",
-				rmtag:        true,
-				cptag:        true,
-				title:        true,
-				alias:        true,
-				link:         true,
-				strictref:    true,
-				cmmt:         true,
-				std:          true,
-				tgt:          "src",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:             "src",
+				dst:             "dst",
+				rmtag:           true,
+				cptag:           true,
+				title:           true,
+				alias:           true,
+				link:            true,
+				strictref:       true,
+				cmmt:            true,
+				std:             true,
+				tgt:             "src",
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				pubRule:         DEFAULT_PUB_RULE,
+				pubMarker:       PUB_MARKER_DRAFT,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
 			},
 		},
 		{
@@ -64,19 +89,30 @@ func TestSetConfig(t *testing.T) {
 				FLAG_STANDARD_USAGE: "1",
 			},
 			wantConfig: configuration{
-				src:          "src",
-				dst:          "dst",
-				rmtag:        false,
-				cptag:        true,
-				title:        true,
-				alias:        true,
-				link:         true,
-				cmmt:         true,
-				strictref:    false,
-				obs:          false,
-				std:          true,
-				tgt:          "src",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:             "src",
+				dst:             "dst",
+				rmtag:           false,
+				cptag:           true,
+				title:           true,
+				alias:           true,
+				link:            true,
+				cmmt:            true,
+				strictref:       false,
+				obs:             false,
+				std:             true,
+				tgt:             "src",
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				pubRule:         DEFAULT_PUB_RULE,
+				pubMarker:       PUB_MARKER_DRAFT,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
 			},
 		},
 		{
@@ -87,10 +123,21 @@ func TestSetConfig(t *testing.T) {
 				FLAG_TARGET:      "tgt",
 			},
 			wantConfig: configuration{
-				src:          "src",
-				dst:          "dst",
-				tgt:          "tgt",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:             "src",
+				dst:             "dst",
+				tgt:             "tgt",
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				pubRule:         DEFAULT_PUB_RULE,
+				pubMarker:       PUB_MARKER_DRAFT,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
 			},
 		},
 	}
@@ -105,7 +152,7 @@ func TestSetConfig(t *testing.T) {
 			flagset.Set(cmdname, cmdvalue)
 		}
 
-		setConfig(flagset, gotConfig)
+		setConfig(flagset, gotConfig, nil)
 		if *gotConfig != tt.wantConfig {
 			t.Errorf("[ERROR | %s]\n\t got: %+v,\n\twant: %+v", tt.name, *gotConfig, tt.wantConfig)
 		}
@@ -121,95 +168,749 @@ func TestVerifyConfig(t *testing.T) {
 		{
 			name: "src not set",
 			config: configuration{
-				src:          "",
-				dst:          "dst",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:            "",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 			wantErr: newMainErr(MAIN_ERR_KIND_SOURCE_NOT_SET),
 		},
 		{
 			name: "dst not set",
 			config: configuration{
-				src:          "src",
-				dst:          "",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:            "src",
+				dst:            "",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 			wantErr: newMainErr(MAIN_ERR_KIND_DESTINATION_NOT_SET),
 		},
 		{
 			name: fmt.Sprintf("%s set but not %s", FLAG_STRICT_REF, FLAG_CONVERT_LINKS),
 			config: configuration{
-				src:          "src",
-				dst:          "dst",
-				link:         false,
-				strictref:    true,
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:            "src",
+				dst:            "dst",
+				link:           false,
+				strictref:      true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 			wantErr: newMainErr(MAIN_ERR_KIND_STRICTREF_NEEDS_LINK),
 		},
 		{
 			name: "src begins with \"-\"",
 			config: configuration{
-				src:          "-src",
-				dst:          "dst",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:            "-src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_SOURCE_FORMAT),
 		},
 		{
 			name: "dst begins with \"-\"",
 			config: configuration{
-				src:          "src",
-				dst:          "-dst",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:            "src",
+				dst:            "-dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_DESTINATION_FORMAT),
 		},
 		{
 			name: "tgt is a markdown file but dst is not",
 			config: configuration{
-				src:          "src",
-				dst:          "dst",
-				tgt:          "tgt/main.md",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:            "src",
+				dst:            "dst",
+				tgt:            "tgt/main.md",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 			wantErr: newMainErr(MAIN_ERR_KIND_TARGET_IS_MARKDOWN_FILE_BUT_DESTINATION_IS_NOT),
 		},
 		{
 			name: "dst is a markdown file but tgt is not",
 			config: configuration{
-				src:          "src",
-				dst:          "dst/main.md",
-				tgt:          "tgt",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:            "src",
+				dst:            "dst/main.md",
+				tgt:            "tgt",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 			wantErr: newMainErr(MAIN_ERR_KIND_DESTINATION_IS_MARKDOWN_FILE_BUT_TARGET_IS_NOT),
 		},
 		{
 			name: "both tgt and dst are markdown files",
 			config: configuration{
-				src:          "src",
-				dst:          "dst/dst_main.md",
-				tgt:          "tgt/tgt_main.md",
-				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				src:            "src",
+				dst:            "dst/dst_main.md",
+				tgt:            "tgt/tgt_main.md",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 		},
 		{
 			name: "invalid anchor formatting style",
 			config: configuration{
-				src:          "src",
-				dst:          "dst",
-				formatAnchor: "x",
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   "x",
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
 			},
 			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_ANCHOR_FORMATTING_STYLE),
 		},
 		{
 			name: "valid anchor formatting style",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_MARKDOWN_IT,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+			},
+		},
+		{
+			name: "invalid link style",
+			config: configuration{
+				src:          "src",
+				dst:          "dst",
+				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:    "x",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_LINK_STYLE),
+		},
+		{
+			name: fmt.Sprintf("%s with %s", FLAG_FORMAT_LINK, convert.LINK_STYLE_RELREF),
 			config: configuration{
 				src:          "src",
 				dst:          "dst",
-				formatAnchor: convert.FORMAT_ANCHOR_MARKDOWN_IT,
+				link:         true,
+				formatLink:   true,
+				formatAnchor: convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:    convert.LINK_STYLE_RELREF,
+				blockAnchor:  DEFAULT_BLOCK_ANCHOR,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_FORMAT_LINK_WITH_LINK_STYLE),
+		},
+		{
+			name: fmt.Sprintf("%s set but not %s", FLAG_LINK_TEMPLATE, FLAG_CONVERT_LINKS),
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				linkTmpl:       "[{{.Text}}]({{.Ref}})",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_LINK_TEMPLATE_NEEDS_LINK),
+		},
+		{
+			name: "invalid link template",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				linkTmpl:       "[{{.Text}]({{.Ref}})",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_LINK_TEMPLATE),
+		},
+		{
+			name: "invalid image size style",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      "x",
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_IMAGE_SIZE_STYLE),
+		},
+		{
+			name: "invalid embed fallback",
+			config: configuration{
+				src:           "src",
+				dst:           "dst",
+				formatAnchor:  convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:     convert.LINK_STYLE_PATH,
+				imageSize:     convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback: "x",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_EMBED_FALLBACK),
+		},
+		{
+			name: "invalid attachments mode",
+			config: configuration{
+				src:           "src",
+				dst:           "dst",
+				formatAnchor:  convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:     convert.LINK_STYLE_PATH,
+				imageSize:     convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback: convert.EMBED_FALLBACK_IMAGE,
+				attachments:   "x",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_ATTACHMENTS_MODE),
+		},
+		{
+			name: "referenced attachments with watch",
+			config: configuration{
+				src:           "src",
+				dst:           "dst",
+				formatAnchor:  convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:     convert.LINK_STYLE_PATH,
+				imageSize:     convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback: convert.EMBED_FALLBACK_IMAGE,
+				attachments:   ATTACHMENTS_REFERENCED,
+				watch:         true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_REFERENCED_ATTACHMENTS_WITH_WATCH),
+		},
+		{
+			name: "invalid attachment name",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: "x",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_ATTACHMENT_NAME),
+		},
+		{
+			name: "attachment dir outside dst",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				attachmentDir:  "../static",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_ATTACHMENT_DIR),
+		},
+		{
+			name: "attachment dir without link",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				attachmentDir:  "static",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_RELOCATING_ATTACHMENTS_NEEDS_LINK),
+		},
+		{
+			name: "hashed attachment names with watch",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				link:           true,
+				watch:          true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_HASH,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_HASHED_ATTACHMENT_NAME_WITH_WATCH),
+		},
+		{
+			name: "remap output without remapPathPrefix",
+			config: configuration{
+				src:             "src",
+				dst:             "dst",
+				link:            true,
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				remapPathPrefix: "",
+				remapOutput:     true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_REMAP_OUTPUT_NEEDS_REMAP_PATH_PREFIX),
+		},
+		{
+			name: "remap output to url",
+			config: configuration{
+				src:             "src",
+				dst:             "dst",
+				link:            true,
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				remapPathPrefix: ">https://example.com/",
+				remapOutput:     true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_REMAP_OUTPUT_PREFIX),
+		},
+		{
+			name: "remap output outside dst",
+			config: configuration{
+				src:             "src",
+				dst:             "dst",
+				link:            true,
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				remapPathPrefix: "notes/>../posts/",
+				remapOutput:     true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_REMAP_OUTPUT_PREFIX),
+		},
+		{
+			name: "remap output with incr",
+			config: configuration{
+				src:             "src",
+				dst:             "dst",
+				link:            true,
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				remapPathPrefix: "notes/>posts/",
+				remapOutput:     true,
+				incr:            true,
+			},
+		},
+		{
+			name: "remap output with watch",
+			config: configuration{
+				src:             "src",
+				dst:             "dst",
+				link:            true,
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				remapPathPrefix: "notes/>posts/",
+				remapOutput:     true,
+				watch:           true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_REMAP_OUTPUT_WITH_WATCH),
+		},
+		{
+			name: "slug without link",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				link:           false,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				slug:           true,
 			},
+			wantErr: newMainErr(MAIN_ERR_KIND_SLUG_NEEDS_LINK),
+		},
+		{
+			name: "slug with markdown dst",
+			config: configuration{
+				src:            "src",
+				dst:            "dst.md",
+				tgt:            "src.md",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				slug:           true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_SLUG_NEEDS_DESTINATION_DIRECTORY),
+		},
+		{
+			name: "slug with watch",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				slug:           true,
+				watch:          true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_SLUG_WITH_WATCH),
+		},
+		{
+			name: "invalid filter",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				filter:         "status == ",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT),
+		},
+		{
+			name: "yamlRules not found",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				yamlRules:      filepath.Join("testdata", "not_found.yaml"),
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_YAML_RULES),
+		},
+		{
+			name: "invalid pubRule",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				publishable:    true,
+				pubRule:        "status ==",
+				pubMarker:      PUB_MARKER_DRAFT,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT),
+		},
+		{
+			name: "invalid pubMarker",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				publishable:    true,
+				pubRule:        DEFAULT_PUB_RULE,
+				pubMarker:      "published",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_PUB_MARKER),
+		},
+		{
+			name: "baseUrl without link",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				link:           false,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				baseUrl:        "https://example.com/",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_BASE_URL_NEEDS_LINK),
+		},
+		{
+			name: "relative baseUrl",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				baseUrl:        "/blog/",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_BASE_URL),
+		},
+		{
+			name: "permalink without link",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				link:           false,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				permalink:      "/:filename/",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_PERMALINK_NEEDS_LINK),
+		},
+		{
+			name: "permalink with unknown token",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				permalink:      "posts/>/:author/",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_PERMALINK),
+		},
+		{
+			name: "baseUrl with linkStyle ref",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_REF,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				baseUrl:        "https://example.com/",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_PERMALINK_WITH_LINK_STYLE),
+		},
+		{
+			name: "permalink with watch",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				tgt:            "src",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    DEFAULT_BLOCK_ANCHOR,
+				permalink:      "/:filename/",
+				watch:          true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_PERMALINK_WITH_WATCH),
+		},
+		{
+			name: "block anchor without {id}",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				link:           true,
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				blockAnchor:    "<a></a>",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_BLOCK_ANCHOR),
+		},
+		{
+			name: "invalid callout style",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				callout:        "x",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_CALLOUT_STYLE),
+		},
+		{
+			name: "highlight template without {text}",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				highlight:      true,
+				highlightTmpl:  "<mark></mark>",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_HIGHLIGHT_TEMPLATE),
+		},
+		{
+			name: "report without dst",
+			config: configuration{
+				src:          "src",
+				report:       REPORT_LINKS,
+				reportFormat: process.REPORT_FORMAT_JSON,
+			},
+		},
+		{
+			name: "invalid report",
+			config: configuration{
+				src:             "src",
+				report:          "x",
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_REPORT),
+		},
+		{
+			name: "invalid report format",
+			config: configuration{
+				src:          "src",
+				report:       REPORT_LINKS,
+				reportFormat: "x",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_REPORT_FORMAT),
+		},
+		{
+			name: "invalid backlinks style",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				backlinks:      "x",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_BACKLINKS_STYLE),
+		},
+		{
+			name: "backlinks with incr",
+			config: configuration{
+				src:            "src",
+				dst:            "dst",
+				formatAnchor:   convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:      convert.LINK_STYLE_PATH,
+				imageSize:      convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:  convert.EMBED_FALLBACK_IMAGE,
+				attachments:    ATTACHMENTS_ALL,
+				attachmentName: process.ATTACHMENT_NAME_ORIGINAL,
+				backlinks:      BACKLINKS_YAML,
+				incr:           true,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_BACKLINKS_WITH_INCREMENTAL),
 		},
 	}
 
@@ -229,3 +930,285 @@ func TestVerifyConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifyReportConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  configuration
+		wantErr mainErr
+	}{
+		{
+			name: "orphans",
+			config: configuration{
+				src:          "src",
+				report:       REPORT_ORPHANS,
+				reportFormat: process.REPORT_FORMAT_TEXT,
+				filter:       "path:notes/",
+				publishable:  true,
+				pubRule:      DEFAULT_PUB_RULE,
+			},
+		},
+		{
+			name: "invalid report",
+			config: configuration{
+				src:          "src",
+				report:       "unknown",
+				reportFormat: process.REPORT_FORMAT_TEXT,
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_REPORT),
+		},
+		{
+			name: "invalid filter",
+			config: configuration{
+				src:          "src",
+				report:       REPORT_ORPHANS,
+				reportFormat: process.REPORT_FORMAT_TEXT,
+				filter:       "status == ",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT),
+		},
+		{
+			name: "invalid pubRule",
+			config: configuration{
+				src:          "src",
+				report:       REPORT_ORPHANS,
+				reportFormat: process.REPORT_FORMAT_TEXT,
+				publishable:  true,
+				pubRule:      "status ==",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT),
+		},
+	}
+
+	for _, tt := range cases {
+		err := verifyReportConfig(&tt.config)
+		if err == nil && tt.wantErr != nil {
+			t.Errorf("[ERROR | %s] expected error did not occurr with %+v", tt.name, tt.config)
+		}
+		if err != nil && tt.wantErr == nil {
+			t.Fatalf("[FATAL | %s] unexpected error occurred: %v", tt.name, err)
+		}
+		if err != nil && tt.wantErr != nil {
+			e, ok := err.(mainErr)
+			if !(ok && e.Kind() == tt.wantErr.Kind()) {
+				t.Fatalf("[FATAL | %s] unexpected error occurred: %v", tt.name, err)
+			}
+		}
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	cases := []struct {
+		name        string
+		fileContent string
+		cmdflags    map[string]string
+		wantConfig  configuration
+		wantErr     mainErr
+	}{
+		{
+			name: "file only",
+			fileContent: `src: src
+dst: dst
+std: true
+rmtag: false
+formatAnchor: markdownit
+`,
+			wantConfig: configuration{
+				src:             "src",
+				dst:             "dst",
+				rmtag:           false,
+				cptag:           true,
+				title:           true,
+				alias:           true,
+				link:            true,
+				strictref:       true,
+				cmmt:            true,
+				std:             true,
+				tgt:             "src",
+				formatAnchor:    convert.FORMAT_ANCHOR_MARKDOWN_IT,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				pubRule:         DEFAULT_PUB_RULE,
+				pubMarker:       PUB_MARKER_DRAFT,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
+			},
+		},
+		{
+			name: "command line overrides file",
+			fileContent: `src: src
+dst: dst
+obs: true
+title: false
+`,
+			cmdflags: map[string]string{
+				FLAG_DESTINATION: "cmddst",
+				FLAG_COPY_TITLE:  "1",
+			},
+			wantConfig: configuration{
+				src:             "src",
+				dst:             "cmddst",
+				cptag:           true,
+				title:           true,
+				alias:           true,
+				obs:             true,
+				tgt:             "src",
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				pubRule:         DEFAULT_PUB_RULE,
+				pubMarker:       PUB_MARKER_DRAFT,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
+			},
+		},
+		{
+			name: "-std on command line overrides file",
+			fileContent: `src: src
+dst: dst
+link: false
+rmtag: false
+title: false
+`,
+			cmdflags: map[string]string{
+				FLAG_STANDARD_USAGE: "1",
+			},
+			wantConfig: configuration{
+				src:             "src",
+				dst:             "dst",
+				cptag:           true,
+				rmtag:           true,
+				title:           true,
+				alias:           true,
+				link:            true,
+				cmmt:            true,
+				strictref:       true,
+				std:             true,
+				tgt:             "src",
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				pubRule:         DEFAULT_PUB_RULE,
+				pubMarker:       PUB_MARKER_DRAFT,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
+			},
+		},
+		{
+			name: "file overrides -std in file",
+			fileContent: `src: src
+dst: dst
+std: true
+link: false
+strictref: false
+`,
+			wantConfig: configuration{
+				src:             "src",
+				dst:             "dst",
+				cptag:           true,
+				rmtag:           true,
+				title:           true,
+				alias:           true,
+				cmmt:            true,
+				std:             true,
+				tgt:             "src",
+				formatAnchor:    convert.FORMAT_ANCHOR_HUGO,
+				linkStyle:       convert.LINK_STYLE_PATH,
+				imageSize:       convert.IMAGE_SIZE_STYLE_HTML,
+				embedFallback:   convert.EMBED_FALLBACK_IMAGE,
+				attachments:     ATTACHMENTS_ALL,
+				attachmentName:  process.ATTACHMENT_NAME_ORIGINAL,
+				reportFormat:    process.REPORT_FORMAT_TEXT,
+				transcludeDepth: DEFAULT_TRANSCLUDE_DEPTH,
+				blockAnchor:     DEFAULT_BLOCK_ANCHOR,
+				pubRule:         DEFAULT_PUB_RULE,
+				pubMarker:       PUB_MARKER_DRAFT,
+				highlightTmpl:   DEFAULT_HIGHLIGHT_TEMPLATE,
+			},
+		},
+		{
+			name:        "unknown key",
+			fileContent: "unknown: true\n",
+			wantErr:     newMainErr(MAIN_ERR_KIND_INVALID_CONFIG_FILE),
+		},
+		{
+			name:        "invalid value",
+			fileContent: "rmtag: yes-please\n",
+			wantErr:     newMainErr(MAIN_ERR_KIND_INVALID_CONFIG_FILE),
+		},
+		{
+			name:        "non-scalar value",
+			fileContent: "src:\n- a\n- b\n",
+			wantErr:     newMainErr(MAIN_ERR_KIND_INVALID_CONFIG_FILE),
+		},
+	}
+
+	for _, tt := range cases {
+		configPath := filepath.Join(t.TempDir(), DEFAULT_CONFIG_FILE_NAME)
+		if err := os.WriteFile(configPath, []byte(tt.fileContent), 0o666); err != nil {
+			t.Fatalf("[FATAL | %s] failed to write: %v", tt.name, err)
+		}
+
+		flagset := flag.NewFlagSet(fmt.Sprintf("TestLoadConfigFile | %s", tt.name), flag.ExitOnError)
+		gotConfig := new(configuration)
+		initFlags(flagset, gotConfig)
+		for cmdname, cmdvalue := range tt.cmdflags {
+			flagset.Set(cmdname, cmdvalue)
+		}
+		flagset.Set(FLAG_CONFIG, configPath)
+
+		fileflags, err := loadConfigFile(flagset, gotConfig)
+		if err != nil {
+			if tt.wantErr == nil {
+				t.Fatalf("[FATAL | %s] unexpected error occurred: %v", tt.name, err)
+			}
+			if e, ok := err.(mainErr); !ok || e.Kind() != tt.wantErr.Kind() {
+				t.Fatalf("[FATAL | %s] unexpected error occurred: %v", tt.name, err)
+			}
+			continue
+		}
+		if tt.wantErr != nil {
+			t.Errorf("[ERROR | %s] expected error did not occurr", tt.name)
+			continue
+		}
+
+		setConfig(flagset, gotConfig, fileflags)
+		tt.wantConfig.config = configPath
+		if *gotConfig != tt.wantConfig {
+			t.Errorf("[ERROR | %s]\n\t got: %+v,\n\twant: %+v", tt.name, *gotConfig, tt.wantConfig)
+		}
+	}
+}
+
+func TestOptionsHash(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(rules, []byte("- key: layout\n  default: post\n"), 0o666); err != nil {
+		t.Fatalf("[FATAL] failed to write: %v", err)
+	}
+	config := &configuration{yamlRules: rules}
+	before := optionsHash("v1.0.0", config)
+	if got := optionsHash("v1.0.0", config); got != before {
+		t.Errorf("[ERROR | same rules] got: %s, want: %s", got, before)
+	}
+	if err := os.WriteFile(rules, []byte("- key: layout\n  default: page\n"), 0o666); err != nil {
+		t.Fatalf("[FATAL] failed to write: %v", err)
+	}
+	if got := optionsHash("v1.0.0", config); got == before {
+		t.Errorf("[ERROR | rules changed] hash did not change: %s", got)
+	}
+}
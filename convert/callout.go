@@ -0,0 +1,156 @@
+package convert
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/scan"
+)
+
+const (
+	CALLOUT_STYLE_HUGO    = "hugo"    // {{% callout %}} shortcode
+	CALLOUT_STYLE_DETAILS = "details" // <details> 要素
+	CALLOUT_STYLE_GITHUB  = "github"  // > [!NOTE] 形式の GitHub alert
+)
+
+var CALLOUT_STYLES = []string{CALLOUT_STYLE_HUGO, CALLOUT_STYLE_DETAILS, CALLOUT_STYLE_GITHUB}
+
+const CALLOUT_SHORTCODE_NAME = "callout"
+
+// GitHub alert で使える種類への対応
+// 含まれない種類は NOTE になる
+var githubAlertTypes = map[string]string{
+	"note":      "NOTE",
+	"abstract":  "NOTE",
+	"summary":   "NOTE",
+	"tldr":      "NOTE",
+	"info":      "NOTE",
+	"todo":      "NOTE",
+	"tip":       "TIP",
+	"hint":      "TIP",
+	"success":   "TIP",
+	"check":     "TIP",
+	"done":      "TIP",
+	"important": "IMPORTANT",
+	"question":  "IMPORTANT",
+	"help":      "IMPORTANT",
+	"faq":       "IMPORTANT",
+	"warning":   "WARNING",
+	"caution":   "WARNING",
+	"attention": "WARNING",
+	"failure":   "CAUTION",
+	"fail":      "CAUTION",
+	"missing":   "CAUTION",
+	"danger":    "CAUTION",
+	"error":     "CAUTION",
+	"bug":       "CAUTION",
+}
+
+// > [!type] title で始まる callout を style の形式に書き換える
+// 入れ子の callout も書き換える
+func NewCalloutConverter(style string) *Converter {
+	c := new(Converter)
+
+	c.Set(MiddlewareAsIs(scan.ScanEscaped))
+	c.Set(MiddlewareAsIs(scan.ScanCodeBlock))
+	c.Set(MiddlewareAsIs(scan.ScanComment))
+	c.Set(MiddlewareAsIs(scan.ScanMathBlock))
+	c.Set(MiddlewareAsIs(scan.ScanNormalComment))
+	c.Set(func(raw []rune, ptr int) (advance int, tobewritten []rune, err error) {
+		advance, calloutType, fold, title, content := scan.ScanCallout(raw, ptr)
+		if advance == 0 {
+			return 0, nil, nil
+		}
+		content, err = c.Convert(content)
+		if err != nil {
+			return 0, nil, errors.Wrap(err, "failed to convert nested callouts")
+		}
+		callout, err := formatCallout(style, calloutType, fold, title, content)
+		if err != nil {
+			return 0, nil, err
+		}
+		if raw[ptr+advance-1] == '\n' {
+			callout += "\n"
+		}
+		return advance, []rune(callout), nil
+	})
+	c.Set(MiddlewareAsIs(scan.ScanInlineMath))
+	c.Set(MiddlewareAsIs(scan.ScanInlineCode))
+	c.Set(TransformNone)
+	return c
+}
+
+// 最後の改行は含まない
+func formatCallout(style, calloutType, fold, title string, content []rune) (string, error) {
+	body := strings.TrimRight(string(content), " \t\r\n")
+	calloutType = strings.ToLower(calloutType)
+	switch style {
+	case CALLOUT_STYLE_HUGO:
+		b := new(strings.Builder)
+		fmt.Fprintf(b, "{{%% %s type=%q", CALLOUT_SHORTCODE_NAME, calloutType)
+		if title != "" {
+			fmt.Fprintf(b, " title=%q", title)
+		}
+		if fold != "" {
+			fmt.Fprintf(b, " fold=%q", fold)
+		}
+		b.WriteString(" %}}\n")
+		if body != "" {
+			b.WriteString(body + "\n")
+		}
+		fmt.Fprintf(b, "{{%% /%s %%}}", CALLOUT_SHORTCODE_NAME)
+		return b.String(), nil
+	case CALLOUT_STYLE_DETAILS:
+		if title == "" {
+			title = defaultCalloutTitle(calloutType)
+		}
+		b := new(strings.Builder)
+		fmt.Fprintf(b, "<details class=\"callout\" data-callout=%q", calloutType)
+		// 折りたたみの指定がなければ常に開いておく
+		if fold != "-" {
+			b.WriteString(" open")
+		}
+		fmt.Fprintf(b, ">\n<summary>%s</summary>\n\n", html.EscapeString(title))
+		if body != "" {
+			b.WriteString(body + "\n\n")
+		}
+		b.WriteString("</details>")
+		return b.String(), nil
+	case CALLOUT_STYLE_GITHUB:
+		alertType, ok := githubAlertTypes[calloutType]
+		if !ok {
+			alertType = "NOTE"
+		}
+		lines := []string{fmt.Sprintf("[!%s]", alertType)}
+		if title != "" {
+			lines = append(lines, fmt.Sprintf("**%s**", title))
+			if body != "" {
+				lines = append(lines, "")
+			}
+		}
+		if body != "" {
+			lines = append(lines, strings.Split(body, "\n")...)
+		}
+		for i, line := range lines {
+			line = strings.TrimRight(line, "\r")
+			if line == "" {
+				lines[i] = ">"
+			} else {
+				lines[i] = "> " + line
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	default:
+		return "", newErrTransformf(ERR_KIND_UNEXPECTED, "unknown callout style: %s", style)
+	}
+}
+
+// Obsidian と同じく種類の先頭を大文字にしたもの
+func defaultCalloutTitle(calloutType string) string {
+	if calloutType == "" {
+		return ""
+	}
+	return strings.ToUpper(calloutType[:1]) + calloutType[1:]
+}
@@ -0,0 +1,89 @@
+package convert
+
+import "testing"
+
+func TestCalloutConverter(t *testing.T) {
+	cases := []struct {
+		name  string
+		style string
+		raw   string
+		want  string
+	}{
+		{
+			name:  "hugo",
+			style: CALLOUT_STYLE_HUGO,
+			raw:   "> [!Note] A \"title\"\n> text\n\nnext",
+			want:  "{{% callout type=\"note\" title=\"A \\\"title\\\"\" %}}\ntext\n{{% /callout %}}\n\nnext",
+		},
+		{
+			name:  "hugo collapsed",
+			style: CALLOUT_STYLE_HUGO,
+			raw:   "> [!warning]-\n> text",
+			want:  "{{% callout type=\"warning\" fold=\"-\" %}}\ntext\n{{% /callout %}}",
+		},
+		{
+			name:  "hugo nested",
+			style: CALLOUT_STYLE_HUGO,
+			raw:   "> [!note]\n> outer\n> > [!tip] Inner\n> > inner\n",
+			want:  "{{% callout type=\"note\" %}}\nouter\n{{% callout type=\"tip\" title=\"Inner\" %}}\ninner\n{{% /callout %}}\n{{% /callout %}}\n",
+		},
+		{
+			name:  "details",
+			style: CALLOUT_STYLE_DETAILS,
+			raw:   "> [!info] Title\n> text\n",
+			want:  "<details class=\"callout\" data-callout=\"info\" open>\n<summary>Title</summary>\n\ntext\n\n</details>\n",
+		},
+		{
+			name:  "details collapsed without title",
+			style: CALLOUT_STYLE_DETAILS,
+			raw:   "> [!faq]- \n> text\n",
+			want:  "<details class=\"callout\" data-callout=\"faq\">\n<summary>Faq</summary>\n\ntext\n\n</details>\n",
+		},
+		{
+			name:  "details title with html",
+			style: CALLOUT_STYLE_DETAILS,
+			raw:   "> [!note] <b>A</b> & \"B\"\n> text\n",
+			want:  "<details class=\"callout\" data-callout=\"note\" open>\n<summary>&lt;b&gt;A&lt;/b&gt; &amp; &#34;B&#34;</summary>\n\ntext\n\n</details>\n",
+		},
+		{
+			name:  "github",
+			style: CALLOUT_STYLE_GITHUB,
+			raw:   "> [!danger] Title\n> line 1\n>\n> line 2\n",
+			want:  "> [!CAUTION]\n> **Title**\n>\n> line 1\n>\n> line 2\n",
+		},
+		{
+			name:  "github unknown type",
+			style: CALLOUT_STYLE_GITHUB,
+			raw:   "> [!custom]\n> text\n",
+			want:  "> [!NOTE]\n> text\n",
+		},
+		{
+			name:  "github nested",
+			style: CALLOUT_STYLE_GITHUB,
+			raw:   "> [!note]\n> > [!tip]\n> > inner\n",
+			want:  "> [!NOTE]\n> > [!TIP]\n> > inner\n",
+		},
+		{
+			name:  "blockquote",
+			style: CALLOUT_STYLE_HUGO,
+			raw:   "> quote\n",
+			want:  "> quote\n",
+		},
+		{
+			name:  "in code block",
+			style: CALLOUT_STYLE_HUGO,
+			raw:   "```\n> [!note]\n```\n",
+			want:  "```\n> [!note]\n```\n",
+		},
+	}
+
+	for _, tt := range cases {
+		got, err := NewCalloutConverter(tt.style).Convert([]rune(tt.raw))
+		if err != nil {
+			t.Fatalf("[FATAL | %s] unexpected error ocurred: %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("[ERROR | %s]\n got: %q\nwant: %q", tt.name, string(got), tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package convert
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -189,6 +190,89 @@ func NewTagFinder(tags map[string]struct{}) *Converter {
 	return c
 }
 
+func NewLinkFinder(links *[]Link) *Converter {
+	c := new(Converter)
+
+	c.Set(MiddlewareAsIs(scan.ScanEscaped))
+	c.Set(MiddlewareAsIs(scan.ScanCodeBlock))
+	c.Set(MiddlewareAsIs(scan.ScanComment))
+	c.Set(MiddlewareAsIs(scan.ScanMathBlock))
+	c.Set(MiddlewareAsIs(scan.ScanNormalComment))
+	c.Set(func(raw []rune, ptr int) (advance int, tobewritten []rune, err error) {
+		advance, displayName, ref, _ := scan.ScanExternalLink(raw, ptr)
+		if advance == 0 {
+			return 0, nil, nil
+		}
+		if kind, fileId, fragments, ok := splitExternalRef(ref); ok {
+			*links = append(*links, Link{
+				Kind:        kind,
+				FileId:      fileId,
+				Fragments:   fragments,
+				DisplayName: displayName,
+				Line:        currentLine(raw, ptr),
+			})
+		}
+		return advance, raw[ptr : ptr+advance], nil
+	})
+	c.Set(func(raw []rune, ptr int) (advance int, tobewritten []rune, err error) {
+		advance, content := scan.ScanInternalLink(raw, ptr)
+		if advance == 0 {
+			return 0, nil, nil
+		}
+		link, err := ParseLinkContent(LINK_KIND_INTERNAL, content)
+		if err != nil {
+			return 0, nil, err
+		}
+		if link != nil {
+			link.Line = currentLine(raw, ptr)
+			*links = append(*links, *link)
+		}
+		return advance, raw[ptr : ptr+advance], nil
+	})
+	c.Set(func(raw []rune, ptr int) (advance int, tobewritten []rune, err error) {
+		advance, content := scan.ScanEmbeds(raw, ptr)
+		if advance == 0 {
+			return 0, nil, nil
+		}
+		link, err := ParseLinkContent(LINK_KIND_EMBEDS, content)
+		if err != nil {
+			return 0, nil, err
+		}
+		if link != nil {
+			link.Line = currentLine(raw, ptr)
+			*links = append(*links, *link)
+		}
+		return advance, raw[ptr : ptr+advance], nil
+	})
+	c.Set(MiddlewareAsIs(scan.ScanInlineMath))
+	c.Set(MiddlewareAsIs(scan.ScanInlineCode))
+	c.Set(MiddlewareAsIs(func(raw []rune, ptr int) (advance int) {
+		advance, _ = scan.ScanTag(raw, ptr)
+		return advance
+	}))
+	c.Set(TransformNone)
+	return c
+}
+
+// [[...]] や ![[...]] の中身を Link にする
+// 中身が空の場合は nil を返す
+func ParseLinkContent(kind LinkKind, content string) (*Link, error) {
+	if content == "" {
+		return nil, nil
+	}
+	identifier, displayName := splitDisplayName(content)
+	fileId, fragments, err := splitFragments(identifier)
+	if err != nil {
+		return nil, errors.Wrap(err, "splitFragments failed")
+	}
+	return &Link{
+		Kind:        kind,
+		FileId:      fileId,
+		Fragments:   fragments,
+		DisplayName: displayName,
+	}, nil
+}
+
 func NewTitleFinder(title *string) *Converter {
 	c := new(Converter)
 
@@ -248,12 +332,101 @@ func newLinkConverter(internal, embeds, external TransformerFunc) *Converter {
 }
 
 func NewLinkConverter(db PathDB, anchorFormattingStyle string) *Converter {
-	internal := defaultTransformInternalLinkFunc(db, anchorFormattingStyle)
-	embeds := defaultTransformEmbedsFunc(db)
-	external := defaultTransformExternalLinkFunc(db)
+	return NewLinkConverterWithLinkStyle(db, anchorFormattingStyle, LINK_STYLE_PATH)
+}
+
+// ノートへのリンクを linkStyle の形式で出力する LinkConverter
+func NewLinkConverterWithLinkStyle(db PathDB, anchorFormattingStyle string, linkStyle string) *Converter {
+	return NewLinkConverterWithRenderer(db, anchorFormattingStyle, linkStyle, nil)
+}
+
+// 変換後のリンクを renderer で出力する LinkConverter
+// renderer が nil なら markdown のリンクを出力する
+func NewLinkConverterWithRenderer(db PathDB, anchorFormattingStyle string, linkStyle string, renderer LinkRenderer) *Converter {
+	internal := defaultTransformInternalLinkFunc(db, anchorFormattingStyle, linkStyle, renderer)
+	embeds := defaultTransformEmbedsFunc(db, renderer)
+	external := defaultTransformExternalLinkFunc(db, linkStyle, renderer)
+	return newLinkConverter(internal, embeds, external)
+}
+
+// 埋め込みの変換を t に差し替えた LinkConverter
+func NewLinkConverterWithEmbedsTransformer(db PathDB, anchorFormattingStyle string, linkStyle string, renderer LinkRenderer, t EmbedsTransformer) *Converter {
+	internal := defaultTransformInternalLinkFunc(db, anchorFormattingStyle, linkStyle, renderer)
+	embeds := TransformEmnbedsFunc(t)
+	external := defaultTransformExternalLinkFunc(db, linkStyle, renderer)
 	return newLinkConverter(internal, embeds, external)
 }
 
+// 段落やリストの項目の末尾の ^blockId を anchorFormat に置き換える
+// anchorFormat 中の {id} は blockId に置き換えられる
+func NewBlockIdConverter(anchorFormat string) *Converter {
+	c := new(Converter)
+
+	c.Set(MiddlewareAsIs(scan.ScanEscaped))
+	c.Set(MiddlewareAsIs(scan.ScanCodeBlock))
+	c.Set(MiddlewareAsIs(scan.ScanComment))
+	c.Set(MiddlewareAsIs(scan.ScanMathBlock))
+	c.Set(MiddlewareAsIs(scan.ScanNormalComment))
+	c.Set(MiddlewareAsIs(func(raw []rune, ptr int) (advance int) {
+		advance, _, _, _ = scan.ScanExternalLink(raw, ptr)
+		return advance
+	}))
+	c.Set(MiddlewareAsIs(func(raw []rune, ptr int) (advance int) {
+		advance, _ = scan.ScanInternalLink(raw, ptr)
+		return advance
+	}))
+	c.Set(MiddlewareAsIs(func(raw []rune, ptr int) (advance int) {
+		advance, _ = scan.ScanEmbeds(raw, ptr)
+		return advance
+	}))
+	c.Set(MiddlewareAsIs(scan.ScanInlineMath))
+	c.Set(MiddlewareAsIs(scan.ScanInlineCode))
+	c.Set(func(raw []rune, ptr int) (advance int, tobewritten []rune, err error) {
+		advance, id := scan.ScanBlockId(raw, ptr)
+		if advance == 0 {
+			return 0, nil, nil
+		}
+		return advance, []rune(strings.ReplaceAll(anchorFormat, "{id}", id)), nil
+	})
+	c.Set(TransformNone)
+	return c
+}
+
+// ==text== を template に置き換える
+// template 中の {text} は text に置き換えられる
+func NewHighlightConverter(template string) *Converter {
+	c := new(Converter)
+
+	c.Set(MiddlewareAsIs(scan.ScanEscaped))
+	c.Set(MiddlewareAsIs(scan.ScanCodeBlock))
+	c.Set(MiddlewareAsIs(scan.ScanComment))
+	c.Set(MiddlewareAsIs(scan.ScanMathBlock))
+	c.Set(MiddlewareAsIs(scan.ScanNormalComment))
+	c.Set(MiddlewareAsIs(func(raw []rune, ptr int) (advance int) {
+		advance, _, _, _ = scan.ScanExternalLink(raw, ptr)
+		return advance
+	}))
+	c.Set(MiddlewareAsIs(func(raw []rune, ptr int) (advance int) {
+		advance, _ = scan.ScanInternalLink(raw, ptr)
+		return advance
+	}))
+	c.Set(MiddlewareAsIs(func(raw []rune, ptr int) (advance int) {
+		advance, _ = scan.ScanEmbeds(raw, ptr)
+		return advance
+	}))
+	c.Set(MiddlewareAsIs(scan.ScanInlineMath))
+	c.Set(MiddlewareAsIs(scan.ScanInlineCode))
+	c.Set(func(raw []rune, ptr int) (advance int, tobewritten []rune, err error) {
+		advance, text := scan.ScanHighlight(raw, ptr)
+		if advance == 0 {
+			return 0, nil, nil
+		}
+		return advance, []rune(strings.ReplaceAll(template, "{text}", text)), nil
+	})
+	c.Set(TransformNone)
+	return c
+}
+
 func NewCommentEraser() *Converter {
 	c := new(Converter)
 
@@ -1,6 +1,7 @@
 package convert
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
 )
@@ -119,6 +120,51 @@ func TestTagFinder(t *testing.T) {
 	}
 }
 
+func TestLinkFinder(t *testing.T) {
+	cases := []struct {
+		name      string
+		raw       []rune
+		wantLinks []Link
+	}{
+		{
+			name: "internal link and embeds",
+			raw:  []rune("[[test#section|display]]\n![[image.png]]\n"),
+			wantLinks: []Link{
+				{Kind: LINK_KIND_INTERNAL, FileId: "test", Fragments: []string{"section"}, DisplayName: "display", Line: 1},
+				{Kind: LINK_KIND_EMBEDS, FileId: "image.png", Line: 2},
+			},
+		},
+		{
+			name: "external links",
+			raw:  []rune("[google](https://google.com)\n[obs](obsidian://open?vault=notes&file=test)\n[file](test.md#section)\n"),
+			wantLinks: []Link{
+				{Kind: LINK_KIND_OBSIDIAN_URI, FileId: "test", DisplayName: "obs", Line: 2},
+				{Kind: LINK_KIND_EXTERNAL, FileId: "test.md", Fragments: []string{"section"}, DisplayName: "file", Line: 3},
+			},
+		},
+		{
+			name:      "in code and comments",
+			raw:       []rune("`[[test]]`\n%%[[test]]%%\n<!-- ![[image.png]] -->\n[[ ]]"),
+			wantLinks: nil,
+		},
+	}
+
+	for _, tt := range cases {
+		var links []Link
+		c := NewLinkFinder(&links)
+		got, err := c.Convert(tt.raw)
+		if err != nil {
+			t.Fatalf("[FATAL] | %v] unexpected error ocurred: %v", tt.name, err)
+		}
+		if string(got) != string(tt.raw) {
+			t.Errorf("[ERROR | output - %v]\n\t got: %q\n\twant: %q", tt.name, got, tt.raw)
+		}
+		if fmt.Sprintf("%+v", links) != fmt.Sprintf("%+v", tt.wantLinks) {
+			t.Errorf("[ERROR | links - %v]\n\t got: %+v\n\twant: %+v", tt.name, links, tt.wantLinks)
+		}
+	}
+}
+
 func TestTitleFinder(t *testing.T) {
 	cases := []struct {
 		name      string
@@ -267,8 +313,22 @@ func TestLinkConverter(t *testing.T) {
 			name:                  "display name - embeds",
 			vault:                 "embeds/displayname",
 			anchorFormattingStyle: FORMAT_ANCHOR_HUGO,
+			raw:                   []rune("![[image.png | alt text]]"),
+			want:                  []rune("![alt text](image.png)"),
+		},
+		{
+			name:                  "width - embeds",
+			vault:                 "embeds/displayname",
+			anchorFormattingStyle: FORMAT_ANCHOR_HUGO,
 			raw:                   []rune("![[image.png | 211026]]"),
-			want:                  []rune("![211026](image.png)"),
+			want:                  []rune(`<img src="image.png" alt="image.png" width="211026">`),
+		},
+		{
+			name:                  "alt text, width and height - embeds",
+			vault:                 "embeds/displayname",
+			anchorFormattingStyle: FORMAT_ANCHOR_HUGO,
+			raw:                   []rune("![[image.png|\"alt\"|300x200]]"),
+			want:                  []rune(`<img src="image.png" alt="&#34;alt&#34;" width="300" height="200">`),
 		},
 		// {
 		// 	name:  "fragments - embeds",
@@ -304,6 +364,20 @@ func TestLinkConverter(t *testing.T) {
 			raw:                   []rune("[[#😗Obsidian (オブシディアン)]]"),
 			want:                  []rune("[😗Obsidian (オブシディアン)](#😗obsidian-(オブシディアン))"),
 		},
+		{
+			name:                  "block id (hugo)",
+			vault:                 "internal",
+			anchorFormattingStyle: FORMAT_ANCHOR_HUGO,
+			raw:                   []rune("[[#^Abc-123|block]]"),
+			want:                  []rune("[block](#Abc-123)"),
+		},
+		{
+			name:                  "block id (markdown it)",
+			vault:                 "internal",
+			anchorFormattingStyle: FORMAT_ANCHOR_MARKDOWN_IT,
+			raw:                   []rune("[[#^Abc-123|block]]"),
+			want:                  []rune("[block](#Abc-123)"),
+		},
 	}
 
 	for _, tt := range cases {
@@ -320,6 +394,82 @@ func TestLinkConverter(t *testing.T) {
 	}
 }
 
+func TestLinkConverterWithLinkStyle(t *testing.T) {
+	vault := filepath.Join("testdata", "linkconverter", "linkstyle")
+	cases := []struct {
+		name      string
+		linkStyle string
+		raw       []rune
+		want      []rune
+	}{
+		{
+			name:      "path",
+			linkStyle: LINK_STYLE_PATH,
+			raw:       []rune("[[note#Section A]]"),
+			want:      []rune("[note > Section A](posts/note.md#section-a)"),
+		},
+		{
+			name:      "relref",
+			linkStyle: LINK_STYLE_RELREF,
+			raw:       []rune("[[note#Section A|text]]"),
+			want:      []rune(`[text]({{< relref "posts/note.md#section-a" >}})`),
+		},
+		{
+			name:      "ref",
+			linkStyle: LINK_STYLE_REF,
+			raw:       []rune("[[note]]"),
+			want:      []rune(`[note]({{< ref "posts/note.md" >}})`),
+		},
+		{
+			name:      "relref to self",
+			linkStyle: LINK_STYLE_RELREF,
+			raw:       []rune("[[#Section A]]"),
+			want:      []rune(`[Section A]({{< relref "#section-a" >}})`),
+		},
+		{
+			name:      "relref - external",
+			linkStyle: LINK_STYLE_RELREF,
+			raw:       []rune("[text](note#section)"),
+			want:      []rune(`[text]({{< relref "posts/note.md#section" >}})`),
+		},
+		{
+			name:      "relref - obsidian url",
+			linkStyle: LINK_STYLE_RELREF,
+			raw:       []rune("[text](obsidian://open?vault=obsidian&file=note)"),
+			want:      []rune(`[text]({{< relref "posts/note.md" >}})`),
+		},
+		{
+			name:      "relref to a file other than notes",
+			linkStyle: LINK_STYLE_RELREF,
+			raw:       []rune("[[image.png]]"),
+			want:      []rune("[image.png](image.png)"),
+		},
+		{
+			name:      "relref - embeds",
+			linkStyle: LINK_STYLE_RELREF,
+			raw:       []rune("![[image.png]]"),
+			want:      []rune("![image.png](image.png)"),
+		},
+		{
+			name:      "relref to a missing note",
+			linkStyle: LINK_STYLE_RELREF,
+			raw:       []rune("[[missing]]"),
+			want:      []rune("[missing]()"),
+		},
+	}
+
+	for _, tt := range cases {
+		db := NewPathDB(vault)
+		got, err := NewLinkConverterWithLinkStyle(db, FORMAT_ANCHOR_HUGO, tt.linkStyle).Convert(tt.raw)
+		if err != nil {
+			t.Fatalf("[FATAL] | %v] unexpected error ocurred: %v", tt.name, err)
+		}
+		if string(got) != string(tt.want) {
+			t.Errorf("[ERROR | %v]\n\t got: %q\n\twant: %q", tt.name, string(got), string(tt.want))
+		}
+	}
+}
+
 func TestCommentEraser(t *testing.T) {
 	cases := []struct {
 		name string
@@ -375,3 +525,121 @@ func TestCommentEraser(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockIdConverter(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  []rune
+		want []rune
+	}{
+		{
+			name: "end of paragraph",
+			raw:  []rune("first line\nsecond line ^abc\n\nnext"),
+			want: []rune("first line\nsecond line <a id=\"abc\"></a>\n\nnext"),
+		},
+		{
+			name: "list item",
+			raw:  []rune("- item ^item-1\n- other"),
+			want: []rune("- item <a id=\"item-1\"></a>\n- other"),
+		},
+		{
+			name: "own line",
+			raw:  []rune("| a | b |\n\n^table"),
+			want: []rune("| a | b |\n\n<a id=\"table\"></a>"),
+		},
+		{
+			name: "not end of line",
+			raw:  []rune("x ^abc y"),
+			want: []rune("x ^abc y"),
+		},
+		{
+			name: "footnote",
+			raw:  []rune("text[^1]\n\n[^1]: note"),
+			want: []rune("text[^1]\n\n[^1]: note"),
+		},
+		{
+			name: "inline code",
+			raw:  []rune("`x ^abc`"),
+			want: []rune("`x ^abc`"),
+		},
+		{
+			name: "code block",
+			raw:  []rune("```\nx ^abc\n```"),
+			want: []rune("```\nx ^abc\n```"),
+		},
+	}
+
+	c := NewBlockIdConverter(`<a id="{id}"></a>`)
+
+	for _, tt := range cases {
+		got, err := c.Convert(tt.raw)
+		if err != nil {
+			t.Fatalf("[FATAL] | %v] unexpected error ocurred: %v", tt.name, err)
+		}
+		if string(got) != string(tt.want) {
+			t.Errorf("[ERROR | %v]\n\tgot: %q\n\twant: %q", tt.name, string(got), string(tt.want))
+		}
+	}
+}
+
+func TestHighlightConverter(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		raw      []rune
+		want     []rune
+	}{
+		{
+			name:     "mark",
+			template: "<mark>{text}</mark>",
+			raw:      []rune("a ==highlighted **text**== b"),
+			want:     []rune("a <mark>highlighted **text**</mark> b"),
+		},
+		{
+			name:     "custom template",
+			template: `<span class="hl">{text}</span>`,
+			raw:      []rune("==x=="),
+			want:     []rune(`<span class="hl">x</span>`),
+		},
+		{
+			name:     "inline code",
+			template: "<mark>{text}</mark>",
+			raw:      []rune("`==x==`"),
+			want:     []rune("`==x==`"),
+		},
+		{
+			name:     "code block",
+			template: "<mark>{text}</mark>",
+			raw:      []rune("```\n==x==\n```"),
+			want:     []rune("```\n==x==\n```"),
+		},
+		{
+			name:     "inline math",
+			template: "<mark>{text}</mark>",
+			raw:      []rune("$a==b==c$"),
+			want:     []rune("$a==b==c$"),
+		},
+		{
+			name:     "comment",
+			template: "<mark>{text}</mark>",
+			raw:      []rune("%%==x==%%"),
+			want:     []rune("%%==x==%%"),
+		},
+		{
+			name:     "link",
+			template: "<mark>{text}</mark>",
+			raw:      []rune("[a](https://example.com/?q==x==)"),
+			want:     []rune("[a](https://example.com/?q==x==)"),
+		},
+	}
+
+	for _, tt := range cases {
+		got, err := NewHighlightConverter(tt.template).Convert(tt.raw)
+		if err != nil {
+			t.Fatalf("[FATAL] | %v] unexpected error ocurred: %v", tt.name, err)
+		}
+		if string(got) != string(tt.want) {
+			t.Errorf("[ERROR | %v]\n\tgot: %q\n\twant: %q", tt.name, string(got), string(tt.want))
+		}
+	}
+}
@@ -5,21 +5,71 @@ import (
 	"io/fs"
 	"net/url"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"golang.org/x/text/unicode/norm"
 )
 
+type LinkKind uint
+
+const (
+	LINK_KIND_INTERNAL LinkKind = iota + 1
+	LINK_KIND_EMBEDS
+	LINK_KIND_EXTERNAL
+	LINK_KIND_OBSIDIAN_URI
+)
+
+// vault 内のファイルへの参照
+type Link struct {
+	Kind        LinkKind
+	FileId      string
+	Fragments   []string
+	DisplayName string
+	Line        int
+}
+
 type PathDB interface {
 	Get(fileId string) (path string, err error)
 }
 
 type pathDbImpl struct {
 	vault     string
+	mu        sync.RWMutex
 	vaultdict map[string][]string
 }
 
+// vault 内のファイルの追加や削除を反映できる PathDB
+type MutablePathDB interface {
+	PathDB
+	// relativePath は vault からの相対パス
+	Add(relativePath string)
+	Remove(relativePath string)
+}
+
+// 参照先の候補を列挙できる PathDB
+type SearchablePathDB interface {
+	PathDB
+	// fileId に最もよく一致するパスをすべて返す
+	// パスは vault からの相対パスで, 辞書順に並ぶ
+	Candidates(fileId string) (paths []string, err error)
+}
+
 func NewPathDB(vault string) PathDB {
+	return newPathDbImpl(vault)
+}
+
+func NewMutablePathDB(vault string) MutablePathDB {
+	return newPathDbImpl(vault)
+}
+
+func NewSearchablePathDB(vault string) SearchablePathDB {
+	return newPathDbImpl(vault)
+}
+
+func newPathDbImpl(vault string) *pathDbImpl {
 	db := new(pathDbImpl)
 	db.vault = vault
 	db.vaultdict = make(map[string][]string)
@@ -39,7 +89,50 @@ func NewPathDB(vault string) PathDB {
 	return db
 }
 
+func (f *pathDbImpl) Add(relativePath string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := filepath.Join(f.vault, relativePath)
+	base := norm.NFC.String(filepath.Base(path))
+	for _, pth := range f.vaultdict[base] {
+		if pth == path {
+			return
+		}
+	}
+	f.vaultdict[base] = append(f.vaultdict[base], path)
+}
+
+func (f *pathDbImpl) Remove(relativePath string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := filepath.Join(f.vault, relativePath)
+	base := norm.NFC.String(filepath.Base(path))
+	paths := f.vaultdict[base]
+	remained := make([]string, 0, len(paths))
+	for _, pth := range paths {
+		if pth != path {
+			remained = append(remained, pth)
+		}
+	}
+	if len(remained) == 0 {
+		delete(f.vaultdict, base)
+		return
+	}
+	f.vaultdict[base] = remained
+}
+
 func (f *pathDbImpl) Get(fileId string) (path string, err error) {
+	paths, err := f.Candidates(fileId)
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+	return paths[0], nil
+}
+
+func (f *pathDbImpl) Candidates(fileId string) (paths []string, err error) {
 	var filename string
 	if filepath.Ext(fileId) == "" {
 		filename = fileId + ".md"
@@ -48,31 +141,33 @@ func (f *pathDbImpl) Get(fileId string) (path string, err error) {
 	}
 
 	base := filepath.Base(filename)
-	paths, ok := f.vaultdict[base]
-	if !ok || len(paths) == 0 {
-		return "", nil
-	}
+	f.mu.RLock()
+	found := f.vaultdict[base]
+	f.mu.RUnlock()
 
 	bestscore := -1
-	bestmatch := ""
-	for _, pth := range paths {
+	bestmatches := make([]string, 0)
+	for _, pth := range found {
 		if score := pathMatchScore(pth, filename); score < 0 {
 			continue
-		} else if bestmatch == "" || score < bestscore || (score == bestscore && strings.Compare(pth, bestmatch) < 0) {
+		} else if bestscore < 0 || score < bestscore {
 			bestscore = score
-			bestmatch = pth
-			continue
+			bestmatches = []string{pth}
+		} else if score == bestscore {
+			bestmatches = append(bestmatches, pth)
 		}
 	}
+	sort.Strings(bestmatches)
 
-	if bestscore < 0 {
-		return "", nil
-	}
-	path, err = filepath.Rel(f.vault, bestmatch)
-	if err != nil {
-		return "", newErrTransformf(ERR_KIND_UNEXPECTED, "filepath.Rel failed: %v", err)
+	paths = make([]string, 0, len(bestmatches))
+	for _, pth := range bestmatches {
+		rel, err := filepath.Rel(f.vault, pth)
+		if err != nil {
+			return nil, newErrTransformf(ERR_KIND_UNEXPECTED, "filepath.Rel failed: %v", err)
+		}
+		paths = append(paths, filepath.ToSlash(rel))
 	}
-	return filepath.ToSlash(path), nil
+	return paths, nil
 }
 
 func pathMatchScore(path string, filename string) int {
@@ -183,13 +278,24 @@ func (w *pathDBWrapperImplRemappingPathPrefix) Get(fileId string) (path string,
 	if err != nil {
 		return "", err
 	}
-	for oldPrefix, newPrefix := range w.remap {
-		if strings.HasPrefix(path, oldPrefix) {
-			newPath := strings.Replace(path, oldPrefix, newPrefix, 1)
-			return newPath, nil
+	return RemapPathPrefix(w.remap, path), nil
+}
+
+// path の先頭が remap のキーに一致すれば, 対応する値に置き換える
+// 複数のキーに一致する場合は最も長いキーを使う
+func RemapPathPrefix(remap map[string]string, path string) string {
+	matched := false
+	var oldPrefix string
+	for prefix := range remap {
+		if strings.HasPrefix(path, prefix) && (!matched || len(prefix) > len(oldPrefix)) {
+			matched = true
+			oldPrefix = prefix
 		}
 	}
-	return path, nil
+	if !matched {
+		return path
+	}
+	return remap[oldPrefix] + strings.TrimPrefix(path, oldPrefix)
 }
 
 func WrapForRemappingPathPrefix(pathPrefixRemap map[string]string, original PathDB) PathDB {
@@ -199,25 +305,34 @@ func WrapForRemappingPathPrefix(pathPrefixRemap map[string]string, original Path
 	}
 }
 
-// type pathDBWrapperImplSettingBaseUrl struct {
-// 	baseUrl  string
-// 	original PathDB
-// }
+type pathDBWrapperImplSettingBaseUrl struct {
+	baseUrl  string
+	original PathDB
+}
 
-// func (w *pathDBWrapperImplSettingBaseUrl) Get(fileId string) (path string, err error) {
-// 	if w.original == nil {
-// 		panic("original PathDB not set but used")
-// 	}
-// 	path, err = w.original.Get(fileId)
-// 	return w.baseUrl + path, err
-// }
+func (w *pathDBWrapperImplSettingBaseUrl) Get(fileId string) (path string, err error) {
+	if w.original == nil {
+		panic("original PathDB not set but used")
+	}
+	path, err = w.original.Get(fileId)
+	if err != nil || path == "" {
+		return path, err
+	}
+	// remapPathPrefix などで既に URL になっている場合はそのまま
+	if strings.Contains(path, "://") {
+		return path, nil
+	}
+	return strings.TrimSuffix(w.baseUrl, "/") + "/" + strings.TrimPrefix(path, "/"), nil
+}
 
-// func WrapForSettingBaseUrl(baseUrl string, original PathDB) PathDB {
-// 	return &pathDBWrapperImplSettingBaseUrl{
-// 		baseUrl:  baseUrl,
-// 		original: original,
-// 	}
-// }
+// パスの前に baseUrl をつけて絶対 URL にする PathDB を返す
+// 見つからなかったパス (空文字列) はそのまま
+func WrapForSettingBaseUrl(baseUrl string, original PathDB) PathDB {
+	return &pathDBWrapperImplSettingBaseUrl{
+		baseUrl:  baseUrl,
+		original: original,
+	}
+}
 
 type pathDBWrapperImplReturningNotFoundPathError struct {
 	original PathDB
@@ -253,6 +368,56 @@ func splitDisplayName(fullname string) (identifier string, displayname string) {
 	}
 }
 
+const (
+	MEDIA_IMAGE = "image"
+	MEDIA_AUDIO = "audio"
+	MEDIA_VIDEO = "video"
+	MEDIA_PDF   = "pdf"
+)
+
+// Obsidian で埋め込めるファイルの拡張子
+var (
+	IMAGE_EXTENSIONS = []string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".svg", ".webp", ".avif"}
+	AUDIO_EXTENSIONS = []string{".mp3", ".wav", ".m4a", ".ogg", ".3gp", ".flac"}
+	VIDEO_EXTENSIONS = []string{".mp4", ".webm", ".ogv", ".mov", ".mkv"}
+	PDF_EXTENSIONS   = []string{".pdf"}
+)
+
+// 拡張子からファイルの種類を返す. 画像, 音声, 動画, PDF のいずれでもなければ空文字列
+// path が見つからなければ fileId の拡張子で判定する
+func mediaOf(path string, fileId string) string {
+	name := path
+	if name == "" {
+		name = fileId
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for media, exts := range map[string][]string{
+		MEDIA_IMAGE: IMAGE_EXTENSIONS,
+		MEDIA_AUDIO: AUDIO_EXTENSIONS,
+		MEDIA_VIDEO: VIDEO_EXTENSIONS,
+		MEDIA_PDF:   PDF_EXTENSIONS,
+	} {
+		for _, e := range exts {
+			if ext == e {
+				return media
+			}
+		}
+	}
+	return ""
+}
+
+var imageSizePattern = regexp.MustCompile(`^(?:(.*)\|)?\s*([0-9]+)(?:x([0-9]+))?$`)
+
+// 表示名の 300, 300x200, alt|300x200 を代替テキストと幅と高さに分ける
+// 大きさの指定がなければ ok = false
+func splitImageSize(displayName string) (alt string, width string, height string, ok bool) {
+	m := imageSizePattern.FindStringSubmatch(displayName)
+	if m == nil {
+		return "", "", "", false
+	}
+	return strings.TrimSpace(m[1]), m[2], m[3], true
+}
+
 func splitFragments(identifier string) (fileId string, fragments []string, err error) {
 	strs := strings.Split(identifier, "#")
 	if len(strs) == 1 {
@@ -271,6 +436,34 @@ func splitFragments(identifier string) (fileId string, fragments []string, err e
 	return fileId, fragments, nil
 }
 
+// obsidian URI (obsidian://open?file=..., obsidian://vault/my_vault/my_note) と fileId から参照先を取り出す
+// 通常のリンク (https://...) など vault 内を参照しないものは ok = false
+func splitExternalRef(ref string) (kind LinkKind, fileId string, fragments []string, ok bool) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return 0, "", nil, false
+	}
+	if u.Scheme == "obsidian" && u.Host == "open" {
+		fileId = u.Query().Get("file")
+		return LINK_KIND_OBSIDIAN_URI, fileId, nil, fileId != ""
+	}
+	if u.Scheme == "obsidian" && u.Host == "vault" {
+		segments := strings.Split(u.Path, "/")
+		if len(segments) != 3 {
+			return 0, "", nil, false
+		}
+		return LINK_KIND_OBSIDIAN_URI, segments[2], nil, true
+	}
+	if u.Scheme == "" && u.Host == "" {
+		fileId, fragments, err := splitFragments(ref)
+		if err != nil {
+			return 0, "", nil, false
+		}
+		return LINK_KIND_EXTERNAL, fileId, fragments, true
+	}
+	return 0, "", nil, false
+}
+
 func buildLinkText(displayName string, fileId string, fragments []string) (linktext string) {
 	if displayName != "" {
 		return displayName
@@ -2,6 +2,7 @@ package convert
 
 import (
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -132,6 +133,64 @@ func TestPathDBGet(t *testing.T) {
 	}
 }
 
+func TestMutablePathDB(t *testing.T) {
+	db := NewMutablePathDB(filepath.Join("testdata", "pathdbget", "subdir_x2"))
+	cases := []struct {
+		name   string
+		update func()
+		fileId string
+		want   string
+	}{
+		{name: "initial", update: func() {}, fileId: "test", want: "a/test.md"},
+		{name: "added closer to root", update: func() { db.Add("test.md") }, fileId: "test", want: "test.md"},
+		{name: "added twice", update: func() { db.Add("test.md") }, fileId: "test", want: "test.md"},
+		{name: "removed", update: func() { db.Remove("test.md") }, fileId: "test", want: "a/test.md"},
+		{name: "renamed", update: func() { db.Remove("a/test.md"); db.Add("a/renamed.md") }, fileId: "test", want: "b/test.md"},
+		{name: "new name", update: func() {}, fileId: "renamed", want: "a/renamed.md"},
+		{name: "all removed", update: func() { db.Remove("b/test.md") }, fileId: "test", want: ""},
+	}
+
+	for _, tt := range cases {
+		tt.update()
+		got, err := db.Get(tt.fileId)
+		if err != nil {
+			t.Errorf("[FAIL | %v] %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("[ERROR | %v] got: %v, want: %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	rootDir := filepath.Join("testdata", "pathdbget")
+	cases := []struct {
+		name   string
+		vault  string
+		fileId string
+		want   []string
+	}{
+		{name: "unique", vault: "simple", fileId: "test", want: []string{"test.md"}},
+		{name: "ambiguous", vault: "subdir_x2", fileId: "test", want: []string{"a/test.md", "b/test.md"}},
+		{name: "specified", vault: "subdir_x2", fileId: "b/test", want: []string{"b/test.md"}},
+		{name: "closest only", vault: "cur_subdir", fileId: "test", want: []string{"test.md"}},
+		{name: "not found", vault: "simple", fileId: "none", want: []string{}},
+	}
+
+	for _, tt := range cases {
+		db := NewSearchablePathDB(filepath.Join(rootDir, tt.vault))
+		got, err := db.Candidates(tt.fileId)
+		if err != nil {
+			t.Errorf("[FAIL | %v] %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[ERROR | %v] got: %v, want: %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestBuildLinkText(t *testing.T) {
 	cases := []struct {
 		displayName string
@@ -214,3 +273,86 @@ func TestWrapForReturningNotFoundPathError(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitImageSize(t *testing.T) {
+	cases := []struct {
+		name        string
+		displayName string
+		wantAlt     string
+		wantWidth   string
+		wantHeight  string
+		wantOk      bool
+	}{
+		{name: "width", displayName: "300", wantWidth: "300", wantOk: true},
+		{name: "width and height", displayName: "300x200", wantWidth: "300", wantHeight: "200", wantOk: true},
+		{name: "alt text and width", displayName: "alt text|300", wantAlt: "alt text", wantWidth: "300", wantOk: true},
+		{name: "alt text only", displayName: "alt text", wantOk: false},
+		{name: "number in alt text", displayName: "300 px", wantOk: false},
+		{name: "empty", displayName: "", wantOk: false},
+	}
+
+	for _, tt := range cases {
+		alt, width, height, ok := splitImageSize(tt.displayName)
+		if ok != tt.wantOk {
+			t.Errorf("[ERROR | ok - %s] got: %v, want: %v", tt.name, ok, tt.wantOk)
+			continue
+		}
+		if alt != tt.wantAlt || width != tt.wantWidth || height != tt.wantHeight {
+			t.Errorf("[ERROR | %s] got: (%q, %q, %q), want: (%q, %q, %q)", tt.name, alt, width, height, tt.wantAlt, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func TestRemapPathPrefix(t *testing.T) {
+	remap := map[string]string{
+		"notes/":         "content/posts/",
+		"notes/private/": "content/private/",
+		"static/":        "/",
+	}
+	cases := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "matched", path: "notes/a.md", want: "content/posts/a.md"},
+		{name: "longest prefix", path: "notes/private/a.md", want: "content/private/a.md"},
+		{name: "root", path: "static/image.png", want: "/image.png"},
+		{name: "not matched", path: "other/notes/a.md", want: "other/notes/a.md"},
+	}
+
+	for _, tt := range cases {
+		if got := RemapPathPrefix(remap, tt.path); got != tt.want {
+			t.Errorf("[ERROR | %s] got: %q, want: %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWrapForSettingBaseUrl(t *testing.T) {
+	cases := []struct {
+		name     string
+		baseUrl  string
+		fileId   string
+		wantPath string
+	}{
+		{name: "with trailing slash", baseUrl: "https://example.com/", fileId: "test", wantPath: "https://example.com/test.md"},
+		{name: "without trailing slash", baseUrl: "https://example.com/blog", fileId: "test", wantPath: "https://example.com/blog/test.md"},
+		{name: "not found", baseUrl: "https://example.com/", fileId: "not_found", wantPath: ""},
+	}
+
+	for _, tt := range cases {
+		db := WrapForSettingBaseUrl(tt.baseUrl, new(fakePathDbImpl))
+		gotPath, err := db.Get(tt.fileId)
+		if err != nil {
+			t.Fatalf("[FATAL | %s] unexpected error occurred: %v", tt.name, err)
+		}
+		if gotPath != tt.wantPath {
+			t.Errorf("[ERROR | %s] got: %q, want: %q", tt.name, gotPath, tt.wantPath)
+		}
+	}
+
+	// 既に URL になっているパスはそのまま
+	db := WrapForSettingBaseUrl("https://example.com/", WrapForRemappingPathPrefix(map[string]string{"": "https://example.org/"}, new(fakePathDbImpl)))
+	if gotPath, err := db.Get("test"); err != nil || gotPath != "https://example.org/test.md" {
+		t.Errorf("[ERROR | url] got: (%q, %v), want: %q", gotPath, err, "https://example.org/test.md")
+	}
+}
@@ -0,0 +1,91 @@
+package convert
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	LINK_DATA_KIND_INTERNAL = "internal" // [[note]]
+	LINK_DATA_KIND_EMBED    = "embed"    // ![[image.png]]
+	LINK_DATA_KIND_EXTERNAL = "external" // [text](https://example.com) や [text](note)
+	LINK_DATA_KIND_URI      = "uri"      // [text](obsidian://open?file=note)
+)
+
+// 変換後のリンクを出力するのに使う値
+type LinkData struct {
+	Kind      string   // internal, embed, external, uri
+	Text      string   // 表示名
+	Path      string   // PathDB で解決したパス. 通常のリンクなら URL
+	Anchor    string   // 変換後のアンカー
+	Fragments []string // 変換前の # 以降
+	Title     string   // [text](ref "title") の title
+	Ref       string   // リンク先. Path と Anchor を linkStyle に合わせて組み立てたもの
+	Width     string   // ![[image.png|300x200]] の 300. 指定がなければ空文字列
+	Height    string   // ![[image.png|300x200]] の 200. 指定がなければ空文字列
+	Media     string   // 埋め込むファイルの種類 (image, audio, video, pdf). いずれでもなければ空文字列
+}
+
+// 変換後のリンクを出力する
+type LinkRenderer interface {
+	RenderLink(data *LinkData) (link string, err error)
+}
+
+type templateLinkRendererImpl struct {
+	tmpl *template.Template
+}
+
+// text/template で書かれた tmpl に LinkData を渡してリンクを出力する LinkRenderer
+// Example: {{if eq .Kind "embed"}}<img src="{{.Ref}}">{{else}}<a href="{{.Ref}}">{{.Text}}</a>{{end}}
+func NewTemplateLinkRenderer(tmpl string) (LinkRenderer, error) {
+	t, err := template.New("link").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse link template")
+	}
+	return &templateLinkRendererImpl{tmpl: t}, nil
+}
+
+func (r *templateLinkRendererImpl) RenderLink(data *LinkData) (link string, err error) {
+	b := new(strings.Builder)
+	if err := r.tmpl.Execute(b, data); err != nil {
+		return "", errors.Wrap(err, "failed to execute link template")
+	}
+	return b.String(), nil
+}
+
+// renderer が nil なら markdown のリンクを出力する
+func renderLink(renderer LinkRenderer, data *LinkData) (link string, err error) {
+	if renderer != nil {
+		return renderer.RenderLink(data)
+	}
+	if data.Kind == LINK_DATA_KIND_EMBED {
+		return fmt.Sprintf("![%s](%s)", data.Text, data.Ref), nil
+	}
+	if data.Title == "" {
+		return fmt.Sprintf("[%s](%s)", data.Text, data.Ref), nil
+	}
+	return fmt.Sprintf("[%s](%s \"%s\")", data.Text, data.Ref, data.Title), nil
+}
+
+var linkTextEscaper = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`)
+
+// fileId のノートへのリンクを本文中の内部リンクと同じ形式で出力する
+// markdown のリンクとして出力するときは text の [ と ] をエスケープする
+func RenderNoteLink(db PathDB, linkStyle string, renderer LinkRenderer, fileId string, text string) (link string, err error) {
+	path, err := db.Get(fileId)
+	if err != nil {
+		return "", errors.Wrap(err, "PathDB.Get failed")
+	}
+	if renderer == nil {
+		text = linkTextEscaper.Replace(text)
+	}
+	return renderLink(renderer, &LinkData{
+		Kind: LINK_DATA_KIND_INTERNAL,
+		Text: text,
+		Path: path,
+		Ref:  formatRef(linkStyle, fileId, path, false, ""),
+	})
+}
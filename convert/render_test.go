@@ -0,0 +1,166 @@
+package convert
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateLinkRenderer(t *testing.T) {
+	vault := filepath.Join("testdata", "linkconverter", "linkstyle")
+	tmpl := `{{if eq .Kind "embed"}}<Image src="{{.Ref}}" alt="{{.Text}}" />{{else if eq .Kind "internal"}}{% link {{.Path}} %}{{if .Anchor}}#{{.Anchor}}{{end}}{{else}}<a href="{{.Ref}}"{{if .Title}} title="{{.Title}}"{{end}}>{{.Text}}</a>{{end}}`
+	cases := []struct {
+		name      string
+		linkStyle string
+		raw       []rune
+		want      []rune
+	}{
+		{
+			name:      "internal",
+			linkStyle: LINK_STYLE_PATH,
+			raw:       []rune("[[note#Section A]]"),
+			want:      []rune("{% link posts/note.md %}#section-a"),
+		},
+		{
+			name:      "embeds",
+			linkStyle: LINK_STYLE_PATH,
+			raw:       []rune("![[image.png|alt]]"),
+			want:      []rune(`<Image src="image.png" alt="alt" />`),
+		},
+		{
+			name:      "external",
+			linkStyle: LINK_STYLE_PATH,
+			raw:       []rune(`[google](https://google.com "search")`),
+			want:      []rune(`<a href="https://google.com" title="search">google</a>`),
+		},
+		{
+			name:      "ref is fileId with relref",
+			linkStyle: LINK_STYLE_RELREF,
+			raw:       []rune("[text](note)"),
+			want:      []rune(`<a href="{{< relref "posts/note.md" >}}">text</a>`),
+		},
+		{
+			name:      "obsidian url",
+			linkStyle: LINK_STYLE_PATH,
+			raw:       []rune("[text](obsidian://open?vault=obsidian&file=note)"),
+			want:      []rune(`<a href="posts/note.md">text</a>`),
+		},
+	}
+
+	renderer, err := NewTemplateLinkRenderer(tmpl)
+	if err != nil {
+		t.Fatalf("[FATAL] unexpected error ocurred: %v", err)
+	}
+	for _, tt := range cases {
+		got, err := NewLinkConverterWithRenderer(NewPathDB(vault), FORMAT_ANCHOR_HUGO, tt.linkStyle, renderer).Convert(tt.raw)
+		if err != nil {
+			t.Fatalf("[FATAL | %s] unexpected error ocurred: %v", tt.name, err)
+		}
+		if string(got) != string(tt.want) {
+			t.Errorf("[ERROR | %s]\n\t got: %q\n\twant: %q", tt.name, string(got), string(tt.want))
+		}
+	}
+}
+
+func TestNewTemplateLinkRenderer(t *testing.T) {
+	cases := []struct {
+		name    string
+		tmpl    string
+		wantErr bool
+	}{
+		{name: "valid", tmpl: "[{{.Text}}]({{.Ref}})", wantErr: false},
+		{name: "unclosed action", tmpl: "[{{.Text}]({{.Ref}})", wantErr: true},
+		{name: "unknown function", tmpl: "{{unknown .Text}}", wantErr: true},
+	}
+
+	for _, tt := range cases {
+		_, err := NewTemplateLinkRenderer(tt.tmpl)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("[ERROR | %s] got: %v, wantErr: %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEmbedsTransformerMedia(t *testing.T) {
+	vault := filepath.Join("testdata", "linkconverter", "media")
+	cases := []struct {
+		name     string
+		fallback string
+		raw      []rune
+		want     []rune
+	}{
+		{name: "image", fallback: EMBED_FALLBACK_IMAGE, raw: []rune("![[image.png]]"), want: []rune("![image.png](image.png)")},
+		{name: "audio", fallback: EMBED_FALLBACK_IMAGE, raw: []rune("![[song.mp3]]"), want: []rune(`<audio controls src="song.mp3"></audio>`)},
+		{name: "video", fallback: EMBED_FALLBACK_IMAGE, raw: []rune("![[clip.webm]]"), want: []rune(`<video controls src="clip.webm"></video>`)},
+		{name: "pdf", fallback: EMBED_FALLBACK_IMAGE, raw: []rune("![[doc.pdf]]"), want: []rune(`<iframe src="doc.pdf" title="doc.pdf"></iframe>`)},
+		{name: "pdf with page", fallback: EMBED_FALLBACK_IMAGE, raw: []rune("![[doc.pdf#page=3]]"), want: []rune(`<iframe src="doc.pdf#page=3" title="doc.pdf &gt; page=3"></iframe>`)},
+		{name: "fallback image", fallback: EMBED_FALLBACK_IMAGE, raw: []rune("![[data.csv]]"), want: []rune("![data.csv](data.csv)")},
+		{name: "fallback link", fallback: EMBED_FALLBACK_LINK, raw: []rune("![[data.csv]]"), want: []rune("[data.csv](data.csv)")},
+	}
+
+	for _, tt := range cases {
+		db := NewPathDB(vault)
+		c := NewLinkConverterWithEmbedsTransformer(db, FORMAT_ANCHOR_HUGO, LINK_STYLE_PATH, nil, NewEmbedsTransformer(db, IMAGE_SIZE_STYLE_HTML, tt.fallback, nil))
+		got, err := c.Convert(tt.raw)
+		if err != nil {
+			t.Fatalf("[FATAL | %s] unexpected error ocurred: %v", tt.name, err)
+		}
+		if string(got) != string(tt.want) {
+			t.Errorf("[ERROR | %s]\n\t got: %q\n\twant: %q", tt.name, string(got), string(tt.want))
+		}
+	}
+}
+
+func TestRenderNoteLink(t *testing.T) {
+	vault := filepath.Join("testdata", "linkconverter", "linkstyle")
+	cases := []struct {
+		name      string
+		linkStyle string
+		tmpl      string
+		text      string
+		want      string
+	}{
+		{
+			name:      "path",
+			linkStyle: LINK_STYLE_PATH,
+			text:      "Note",
+			want:      "[Note](posts/note.md)",
+		},
+		{
+			name:      "relref",
+			linkStyle: LINK_STYLE_RELREF,
+			text:      "Note",
+			want:      `[Note]({{< relref "posts/note.md" >}})`,
+		},
+		{
+			name:      "brackets in text",
+			linkStyle: LINK_STYLE_PATH,
+			text:      `[draft] note\`,
+			want:      `[\[draft\] note\\](posts/note.md)`,
+		},
+		{
+			name:      "template",
+			linkStyle: LINK_STYLE_PATH,
+			tmpl:      `<a href="{{.Ref}}">{{.Text}}</a>`,
+			text:      "[draft] note",
+			want:      `<a href="posts/note.md">[draft] note</a>`,
+		},
+	}
+
+	for _, tt := range cases {
+		var renderer LinkRenderer
+		if tt.tmpl != "" {
+			var err error
+			renderer, err = NewTemplateLinkRenderer(tt.tmpl)
+			if err != nil {
+				t.Fatalf("[FATAL | %s] unexpected error ocurred: %v", tt.name, err)
+			}
+		}
+		got, err := RenderNoteLink(NewPathDB(vault), tt.linkStyle, renderer, "posts/note.md", tt.text)
+		if err != nil {
+			t.Fatalf("[FATAL | %s] unexpected error ocurred: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("[ERROR | %s]\n\t got: %q\n\twant: %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package convert
+
+import (
+	"strings"
+
+	"github.com/qawatake/obsdconv/scan"
+)
+
+// heading で始まるセクションを返す
+// セクションは見出し自身から, 同じかより上のレベルの次の見出しの直前まで
+// heading の比較では, 前後の空白と anchor に変換したときの違いを無視する
+func FindSection(raw []rune, heading string) (section []rune, ok bool) {
+	type header struct {
+		ptr   int
+		level int
+		text  string
+	}
+	var headers []header
+
+	c := new(Converter)
+	c.Set(MiddlewareAsIs(scan.ScanEscaped))
+	c.Set(MiddlewareAsIs(scan.ScanCodeBlock))
+	c.Set(MiddlewareAsIs(scan.ScanComment))
+	c.Set(MiddlewareAsIs(scan.ScanMathBlock))
+	c.Set(MiddlewareAsIs(scan.ScanNormalComment))
+	c.Set(func(raw []rune, ptr int) (advance int, tobewritten []rune, err error) {
+		advance, level, headertext := scan.ScanHeader(raw, ptr)
+		if advance == 0 {
+			return 0, nil, nil
+		}
+		headers = append(headers, header{ptr: ptr, level: level, text: headertext})
+		return advance, raw[ptr : ptr+advance], nil
+	})
+	c.Set(TransformNone)
+	if _, err := c.Convert(raw); err != nil {
+		return nil, false
+	}
+
+	heading = strings.TrimSpace(heading)
+	for i, h := range headers {
+		if h.text != heading && formatAnchor(h.text) != formatAnchor(heading) {
+			continue
+		}
+		end := len(raw)
+		for _, next := range headers[i+1:] {
+			if next.level <= h.level {
+				end = next.ptr
+				break
+			}
+		}
+		return raw[h.ptr:end], true
+	}
+	return nil, false
+}
+
+// ^blockId が付いたブロックを返す
+// ブロックは ^blockId で終わる段落か, ^blockId で終わる行がリストの項目ならその行だけ
+// ^blockId だけの行の場合は, 直前のブロック
+// 返すブロックから ^blockId は取り除く
+func FindBlock(raw []rune, blockId string) (block []rune, ok bool) {
+	marker := "^" + blockId
+	lines := strings.SplitAfter(string(raw), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t\r\n")
+		var last int
+		if trimmed == marker {
+			// 表や引用には空行を挟んで ^blockId だけの行を付ける
+			last = i - 1
+			for last >= 0 && strings.TrimSpace(lines[last]) == "" {
+				last--
+			}
+		} else if strings.HasSuffix(trimmed, " "+marker) {
+			// 段落の途中の行に付いたものはブロック ID ではない
+			if !isListItem(line) && i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && !isListItem(lines[i+1]) {
+				continue
+			}
+			last = i
+			lines[i] = strings.TrimRight(strings.TrimSuffix(trimmed, marker), " \t") + "\n"
+			if isListItem(line) {
+				return []rune(lines[i]), true
+			}
+		} else {
+			continue
+		}
+		if last < 0 {
+			return nil, false
+		}
+		first := last
+		for first > 0 && strings.TrimSpace(lines[first-1]) != "" {
+			first--
+		}
+		block := strings.Join(lines[first:last+1], "")
+		if !strings.HasSuffix(block, "\n") {
+			block += "\n"
+		}
+		return []rune(block), true
+	}
+	return nil, false
+}
+
+func isListItem(line string) bool {
+	line = strings.TrimLeft(line, " \t")
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, bullet) {
+			return true
+		}
+	}
+	digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+	return digits > 0 && (strings.HasPrefix(line[digits:], ". ") || strings.HasPrefix(line[digits:], ") "))
+}
@@ -0,0 +1,58 @@
+package convert
+
+import "testing"
+
+func TestFindSection(t *testing.T) {
+	raw := []rune("# Title\nintro\n## Section A\na\n### Sub\nsub\n```\n## not a heading\n```\n## Section B\nb\n")
+	cases := []struct {
+		name    string
+		heading string
+		want    string
+		wantOk  bool
+	}{
+		{name: "until the next heading of the same level", heading: "Section A", want: "## Section A\na\n### Sub\nsub\n```\n## not a heading\n```\n", wantOk: true},
+		{name: "until the next heading of a higher level", heading: "Sub", want: "### Sub\nsub\n```\n## not a heading\n```\n", wantOk: true},
+		{name: "until the end", heading: "Section B", want: "## Section B\nb\n", wantOk: true},
+		{name: "compared as anchors", heading: "section-b", want: "## Section B\nb\n", wantOk: true},
+		{name: "in code block", heading: "not a heading", wantOk: false},
+		{name: "not found", heading: "none", wantOk: false},
+	}
+
+	for _, tt := range cases {
+		got, ok := FindSection(raw, tt.heading)
+		if ok != tt.wantOk {
+			t.Errorf("[ERROR | ok - %s] got: %v, want: %v", tt.name, ok, tt.wantOk)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("[ERROR | %s]\n got: %q\nwant: %q", tt.name, string(got), tt.want)
+		}
+	}
+}
+
+func TestFindBlock(t *testing.T) {
+	raw := []rune("first line\nsecond line ^para\n\n- item 1\n- item 2 ^item\n\n| a | b |\n| - | - |\n\n^table\n\nwrapped ^wrapped\nline\n")
+	cases := []struct {
+		name    string
+		blockId string
+		want    string
+		wantOk  bool
+	}{
+		{name: "paragraph", blockId: "para", want: "first line\nsecond line\n", wantOk: true},
+		{name: "list item", blockId: "item", want: "- item 2\n", wantOk: true},
+		{name: "on its own line", blockId: "table", want: "| a | b |\n| - | - |\n", wantOk: true},
+		{name: "not found", blockId: "none", wantOk: false},
+		{name: "middle of paragraph", blockId: "wrapped", wantOk: false},
+	}
+
+	for _, tt := range cases {
+		got, ok := FindBlock(raw, tt.blockId)
+		if ok != tt.wantOk {
+			t.Errorf("[ERROR | ok - %s] got: %v, want: %v", tt.name, ok, tt.wantOk)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("[ERROR | %s]\n got: %q\nwant: %q", tt.name, string(got), tt.want)
+		}
+	}
+}
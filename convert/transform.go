@@ -2,7 +2,9 @@ package convert
 
 import (
 	"fmt"
+	"html"
 	"net/url"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -32,8 +34,8 @@ func TransformInternalLinkFunc(t InternalLinkTransformer) TransformerFunc {
 	}
 }
 
-func defaultTransformInternalLinkFunc(db PathDB, anchorFormattingStyle string) TransformerFunc {
-	return TransformInternalLinkFunc(newInternalLinkTransformerImpl(db, anchorFormattingStyle))
+func defaultTransformInternalLinkFunc(db PathDB, anchorFormattingStyle string, linkStyle string, renderer LinkRenderer) TransformerFunc {
+	return TransformInternalLinkFunc(newInternalLinkTransformerImpl(db, anchorFormattingStyle, linkStyle, renderer))
 }
 
 func TransformEmnbedsFunc(t EmbedsTransformer) TransformerFunc {
@@ -50,8 +52,8 @@ func TransformEmnbedsFunc(t EmbedsTransformer) TransformerFunc {
 	}
 }
 
-func defaultTransformEmbedsFunc(db PathDB) TransformerFunc {
-	return TransformEmnbedsFunc(newEmbedsTransformerImpl(db))
+func defaultTransformEmbedsFunc(db PathDB, renderer LinkRenderer) TransformerFunc {
+	return TransformEmnbedsFunc(newEmbedsTransformerImpl(db, IMAGE_SIZE_STYLE_HTML, EMBED_FALLBACK_IMAGE, renderer))
 }
 
 func TransformExternalLinkFunc(t ExternalLinkTransformer) TransformerFunc {
@@ -69,8 +71,8 @@ func TransformExternalLinkFunc(t ExternalLinkTransformer) TransformerFunc {
 	}
 }
 
-func defaultTransformExternalLinkFunc(db PathDB) TransformerFunc {
-	return TransformExternalLinkFunc(newExternalLinkTransformerImpl(db))
+func defaultTransformExternalLinkFunc(db PathDB, linkStyle string, renderer LinkRenderer) TransformerFunc {
+	return TransformExternalLinkFunc(newExternalLinkTransformerImpl(db, linkStyle, renderer))
 }
 
 func TransformInternalLinkToPlain(raw []rune, ptr int) (advance int, tobewritten []rune, err error) {
@@ -110,9 +112,11 @@ type InternalLinkTransformer interface {
 type InternalLinkTransformerImpl struct {
 	PathDB
 	anchorFormattingStyle string
+	linkStyle             string
+	renderer              LinkRenderer
 }
 
-func newInternalLinkTransformerImpl(db PathDB, anchorFormattingStyle string) *InternalLinkTransformerImpl {
+func newInternalLinkTransformerImpl(db PathDB, anchorFormattingStyle string, linkStyle string, renderer LinkRenderer) *InternalLinkTransformerImpl {
 	var validAnchorFormattingStyle bool
 	if anchorFormattingStyle == FORMAT_ANCHOR_HUGO {
 		validAnchorFormattingStyle = true
@@ -126,6 +130,8 @@ func newInternalLinkTransformerImpl(db PathDB, anchorFormattingStyle string) *In
 	return &InternalLinkTransformerImpl{
 		PathDB:                db,
 		anchorFormattingStyle: anchorFormattingStyle,
+		linkStyle:             linkStyle,
+		renderer:              renderer,
 	}
 }
 
@@ -134,6 +140,14 @@ const FORMAT_ANCHOR_MARKDOWN_IT = "markdownit"
 
 var ANCHOR_FORMATTING_STYLES = []string{FORMAT_ANCHOR_HUGO, FORMAT_ANCHOR_MARKDOWN_IT}
 
+const (
+	LINK_STYLE_PATH   = "path"   // [text](path/to/note.md#anchor)
+	LINK_STYLE_REF    = "ref"    // [text]({{< ref "path/to/note.md#anchor" >}})
+	LINK_STYLE_RELREF = "relref" // [text]({{< relref "path/to/note.md#anchor" >}})
+)
+
+var LINK_STYLES = []string{LINK_STYLE_PATH, LINK_STYLE_REF, LINK_STYLE_RELREF}
+
 func (t *InternalLinkTransformerImpl) TransformInternalLink(content string) (externalLink string, err error) {
 	if content == "" {
 		return "", nil // [[ ]] はスキップ
@@ -150,20 +164,26 @@ func (t *InternalLinkTransformerImpl) TransformInternalLink(content string) (ext
 	}
 
 	linktext := buildLinkText(displayName, fileId, fragments)
-	var ref string
-	if fragments == nil {
-		ref = path
-	} else {
-		var anchor string
-		if t.anchorFormattingStyle == FORMAT_ANCHOR_HUGO {
+	var anchor string
+	if fragments != nil {
+		if id, ok := blockIdOf(fragments[len(fragments)-1]); ok {
+			anchor = id
+		} else if t.anchorFormattingStyle == FORMAT_ANCHOR_HUGO {
 			anchor = formatAnchor(fragments[len(fragments)-1])
 		} else if t.anchorFormattingStyle == FORMAT_ANCHOR_MARKDOWN_IT {
 			anchor = formatAnchorByMarkdownItAnchorRule(fragments[len(fragments)-1])
 		}
-		ref = path + "#" + anchor
 	}
-
-	return fmt.Sprintf("[%s](%s)", linktext, ref), nil
+	ref := formatRef(t.linkStyle, fileId, path, fragments != nil, anchor)
+
+	return renderLink(t.renderer, &LinkData{
+		Kind:      LINK_DATA_KIND_INTERNAL,
+		Text:      linktext,
+		Path:      path,
+		Anchor:    anchor,
+		Fragments: fragments,
+		Ref:       ref,
+	})
 }
 
 type EmbedsTransformer interface {
@@ -172,11 +192,40 @@ type EmbedsTransformer interface {
 
 type EmbedsTransformerImpl struct {
 	PathDB
+	imageSizeStyle string
+	fallback       string
+	renderer       LinkRenderer
+}
+
+const (
+	IMAGE_SIZE_STYLE_HTML = "html" // <img src="path" width="300" height="200">
+	IMAGE_SIZE_STYLE_HUGO = "hugo" // {{< figure src="path" width="300" height="200" >}}
+)
+
+var IMAGE_SIZE_STYLES = []string{IMAGE_SIZE_STYLE_HTML, IMAGE_SIZE_STYLE_HUGO}
+
+// 画像, 音声, 動画, PDF 以外の埋め込みの出力
+const (
+	EMBED_FALLBACK_IMAGE = "image" // ![text](path)
+	EMBED_FALLBACK_LINK  = "link"  // [text](path)
+)
+
+var EMBED_FALLBACKS = []string{EMBED_FALLBACK_IMAGE, EMBED_FALLBACK_LINK}
+
+// renderer が nil ならファイルの種類ごとに出力する
+// 画像は ![text](path) の形式. ただし ![[image.png|300]] のように大きさが指定されたものは imageSizeStyle の形式
+// 音声と動画は <audio> と <video>, PDF は <iframe>
+// それ以外は fallback の形式
+func NewEmbedsTransformer(db PathDB, imageSizeStyle string, fallback string, renderer LinkRenderer) EmbedsTransformer {
+	return newEmbedsTransformerImpl(db, imageSizeStyle, fallback, renderer)
 }
 
-func newEmbedsTransformerImpl(db PathDB) *EmbedsTransformerImpl {
+func newEmbedsTransformerImpl(db PathDB, imageSizeStyle string, fallback string, renderer LinkRenderer) *EmbedsTransformerImpl {
 	return &EmbedsTransformerImpl{
-		PathDB: db,
+		PathDB:         db,
+		imageSizeStyle: imageSizeStyle,
+		fallback:       fallback,
+		renderer:       renderer,
 	}
 }
 
@@ -195,15 +244,85 @@ func (t *EmbedsTransformerImpl) TransformEmbeds(content string) (emnbeddedLink s
 		return "", errors.Wrap(err, "PathDB.Get failed")
 	}
 
+	media := mediaOf(path, fileId)
+	var width, height string
+	if media == MEDIA_IMAGE {
+		var ok bool
+		var alt string
+		if alt, width, height, ok = splitImageSize(displayName); ok {
+			displayName = alt
+		}
+	}
 	linktext := buildLinkText(displayName, fileId, fragments)
-	var ref string
-	if fragments == nil {
-		ref = path
-	} else {
-		ref = path + "#" + formatAnchor(fragments[len(fragments)-1])
+	var anchor string
+	ref := path
+	if fragments != nil {
+		if media == MEDIA_PDF {
+			// #page=3 などはそのまま PDF ビューアに渡す
+			anchor = strings.Join(fragments, "&")
+		} else if id, ok := blockIdOf(fragments[len(fragments)-1]); ok {
+			anchor = id
+		} else {
+			anchor = formatAnchor(fragments[len(fragments)-1])
+		}
+		ref = path + "#" + anchor
+	}
+
+	data := &LinkData{
+		Kind:      LINK_DATA_KIND_EMBED,
+		Text:      linktext,
+		Path:      path,
+		Anchor:    anchor,
+		Fragments: fragments,
+		Ref:       ref,
+		Width:     width,
+		Height:    height,
+		Media:     media,
+	}
+	if t.renderer != nil {
+		return renderLink(t.renderer, data)
+	}
+	switch media {
+	case MEDIA_IMAGE:
+		if width != "" {
+			return formatSizedImage(t.imageSizeStyle, data)
+		}
+		return renderLink(nil, data)
+	case MEDIA_AUDIO:
+		return fmt.Sprintf("<audio controls src=\"%s\"></audio>", html.EscapeString(data.Ref)), nil
+	case MEDIA_VIDEO:
+		return fmt.Sprintf("<video controls src=\"%s\"></video>", html.EscapeString(data.Ref)), nil
+	case MEDIA_PDF:
+		return fmt.Sprintf("<iframe src=\"%s\" title=\"%s\"></iframe>", html.EscapeString(data.Ref), html.EscapeString(data.Text)), nil
 	}
+	switch t.fallback {
+	case EMBED_FALLBACK_IMAGE:
+		return renderLink(nil, data)
+	case EMBED_FALLBACK_LINK:
+		return fmt.Sprintf("[%s](%s)", data.Text, data.Ref), nil
+	default:
+		return "", newErrTransformf(ERR_KIND_UNEXPECTED, "unknown embed fallback: %s", t.fallback)
+	}
+}
 
-	return fmt.Sprintf("![%s](%s)", linktext, ref), nil
+// 大きさが指定された画像を style の形式で出力する
+func formatSizedImage(style string, data *LinkData) (string, error) {
+	switch style {
+	case IMAGE_SIZE_STYLE_HTML:
+		img := fmt.Sprintf("<img src=\"%s\" alt=\"%s\" width=\"%s\"", html.EscapeString(data.Ref), html.EscapeString(data.Text), data.Width)
+		if data.Height != "" {
+			img += fmt.Sprintf(" height=\"%s\"", data.Height)
+		}
+		return img + ">", nil
+	case IMAGE_SIZE_STYLE_HUGO:
+		figure := fmt.Sprintf("{{< figure src=%q alt=%q width=%q", data.Ref, data.Text, data.Width)
+		if data.Height != "" {
+			figure += fmt.Sprintf(" height=%q", data.Height)
+		}
+		return figure + " >}}", nil
+	default:
+		return "", newErrTransformf(ERR_KIND_UNEXPECTED, "unknown image size style: %s", style)
+	}
 }
 
 type ExternalLinkTransformer interface {
@@ -212,11 +331,15 @@ type ExternalLinkTransformer interface {
 
 type ExternalLinkTransformerImpl struct {
 	PathDB
+	linkStyle string
+	renderer  LinkRenderer
 }
 
-func newExternalLinkTransformerImpl(db PathDB) *ExternalLinkTransformerImpl {
+func newExternalLinkTransformerImpl(db PathDB, linkStyle string, renderer LinkRenderer) *ExternalLinkTransformerImpl {
 	return &ExternalLinkTransformerImpl{
-		PathDB: db,
+		PathDB:    db,
+		linkStyle: linkStyle,
+		renderer:  renderer,
 	}
 }
 
@@ -228,11 +351,13 @@ func (t *ExternalLinkTransformerImpl) TransformExternalLink(displayName, ref str
 
 	// ref = 通常のリンク
 	if (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
-		if title == "" {
-			return fmt.Sprintf("[%s](%s)", displayName, ref), nil
-		} else {
-			return fmt.Sprintf("[%s](%s \"%s\")", displayName, ref, title), nil
-		}
+		return renderLink(t.renderer, &LinkData{
+			Kind:  LINK_DATA_KIND_EXTERNAL,
+			Text:  displayName,
+			Path:  ref,
+			Title: title,
+			Ref:   ref,
+		})
 	}
 
 	// ref = obsidian URI (obsidian://open?...)
@@ -248,11 +373,13 @@ func (t *ExternalLinkTransformerImpl) TransformExternalLink(displayName, ref str
 		if err != nil {
 			return "", errors.Wrap(err, "PathDB.Get failed")
 		}
-		if title == "" {
-			return fmt.Sprintf("[%s](%s)", displayName, path), nil
-		} else {
-			return fmt.Sprintf("[%s](%s \"%s\")", displayName, path, title), nil
-		}
+		return renderLink(t.renderer, &LinkData{
+			Kind:  LINK_DATA_KIND_URI,
+			Text:  displayName,
+			Path:  path,
+			Title: title,
+			Ref:   formatRef(t.linkStyle, fileId, path, false, ""),
+		})
 	}
 
 	// ref = obsidian URI (obsidian://vault/my_vault/my_note)
@@ -268,11 +395,13 @@ func (t *ExternalLinkTransformerImpl) TransformExternalLink(displayName, ref str
 		if err != nil {
 			return "", errors.Wrap(err, "PathDB.Get failed")
 		}
-		if title == "" {
-			return fmt.Sprintf("[%s](%s)", displayName, path), nil
-		} else {
-			return fmt.Sprintf("[%s](%s \"%s\")", displayName, path, title), nil
-		}
+		return renderLink(t.renderer, &LinkData{
+			Kind:  LINK_DATA_KIND_URI,
+			Text:  displayName,
+			Path:  path,
+			Title: title,
+			Ref:   formatRef(t.linkStyle, fileId, path, false, ""),
+		})
 	}
 
 	// ref = fileId
@@ -285,22 +414,50 @@ func (t *ExternalLinkTransformerImpl) TransformExternalLink(displayName, ref str
 		if err != nil {
 			return "", errors.Wrap(err, "PathDB.Get failed")
 		}
-		var newref string
-		if fragments == nil {
-			newref = path
-		} else {
-			newref = path + "#" + strings.Join(fragments, "#")
-		}
-		if title == "" {
-			return fmt.Sprintf("[%s](%s)", displayName, newref), nil
-		} else {
-			return fmt.Sprintf("[%s](%s \"%s\")", displayName, newref, title), nil
-		}
+		anchor := strings.Join(fragments, "#")
+		return renderLink(t.renderer, &LinkData{
+			Kind:      LINK_DATA_KIND_EXTERNAL,
+			Text:      displayName,
+			Path:      path,
+			Anchor:    anchor,
+			Fragments: fragments,
+			Title:     title,
+			Ref:       formatRef(t.linkStyle, fileId, path, fragments != nil, anchor),
+		})
 	}
 
 	return "", newErrTransformf(ERR_KIND_UNEXPECTED_HREF, "unexpected href: %s", ref)
 }
 
+// ^blockId 形式の fragment なら blockId を返す
+// blockId は BlockIdConverter が出力するアンカーの id にそのまま使われる
+func blockIdOf(fragment string) (id string, ok bool) {
+	if !strings.HasPrefix(fragment, "^") || len(fragment) == 1 {
+		return "", false
+	}
+	return fragment[1:], true
+}
+
+// リンク先を linkStyle の形式で返す
+// ref と relref は Hugo がリンク先を検証できるようにノートへのリンクにだけ使う
+// ノート以外へのリンクと参照先が見つからないリンクは linkStyle によらず path の形式にする
+func formatRef(linkStyle string, fileId string, path string, hasAnchor bool, anchor string) string {
+	ref := path
+	if hasAnchor {
+		ref = path + "#" + anchor
+	}
+	toNote := filepath.Ext(path) == ".md" || (fileId == "" && path == "")
+	if !toNote {
+		return ref
+	}
+	switch linkStyle {
+	case LINK_STYLE_REF, LINK_STYLE_RELREF:
+		return fmt.Sprintf("{{< %s %q >}}", linkStyle, ref)
+	default:
+		return ref
+	}
+}
+
 func formatAnchor(rawAnchor string) (anchor string) {
 	loweredAnchor := strings.ToLower(rawAnchor)
 	rawRunes := []rune(loweredAnchor)
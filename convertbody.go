@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -8,15 +9,34 @@ import (
 	"github.com/qawatake/obsdconv/process"
 )
 
+const (
+	BACKLINKS_YAML    = "yaml"
+	BACKLINKS_SECTION = "section"
+)
+
+var BACKLINKS_STYLES = []string{BACKLINKS_YAML, BACKLINKS_SECTION}
+
+const BACKLINKS_SECTION_HEADER = "## Linked from"
+
+// ^blockId を置き換えるアンカー. {id} は blockId に置き換えられる
+const DEFAULT_BLOCK_ANCHOR = `<a id="{id}"></a>`
+
+// ==text== を置き換える html. {text} は text に置き換えられる
+const DEFAULT_HIGHLIGHT_TEMPLATE = "<mark>{text}</mark>"
+
 type bodyConvAuxOutImpl struct {
-	title string
-	tags  map[string]struct{}
+	title     string
+	tags      map[string]struct{}
+	backlinks []process.Backlink // Path は出力されるリンクの形式
+	body      []rune             // 変換前の本文
 }
 
-func newBodyConvAuxOutImpl(title string, tags map[string]struct{}) *bodyConvAuxOutImpl {
+func newBodyConvAuxOutImpl(title string, tags map[string]struct{}, backlinks []process.Backlink, body []rune) *bodyConvAuxOutImpl {
 	return &bodyConvAuxOutImpl{
-		title: title,
-		tags:  tags,
+		title:     title,
+		tags:      tags,
+		backlinks: backlinks,
+		body:      body,
 	}
 }
 
@@ -30,10 +50,27 @@ type bodyConverterImpl struct {
 	rmH1                  bool
 	formatLink            bool
 	anchorFormattingStyle string
+	linkStyle             string
+	linkRenderer          convert.LinkRenderer
+	imageSizeStyle        string
+	embedFallback         string
 	pathPrefixRemap       map[string]string
+	baseUrl               string
+	backlinks             *process.BacklinkIndex
+	backlinksStyle        string
+	transclusion          *transclusionConfig
+	blockAnchor           string
+	calloutStyle          string
+	highlightTmpl         string
 }
 
-func newBodyConverterImpl(db convert.PathDB, cptag bool, rmtag bool, cmmt bool, title bool, link bool, rmH1 bool, formatLink bool, anchorFormattingStyle string, pathPrefixRemap map[string]string) *bodyConverterImpl {
+// linkRenderer が nil なら markdown のリンクを出力する
+// baseUrl が空文字列ならリンクは相対パスのまま
+// backlinks が nil なら backlinksStyle は無視される
+// transclusion が nil なら埋め込まれたノートは展開しない
+// calloutStyle が空文字列なら callout は変換しない
+// highlightTmpl が空文字列なら ==text== は変換しない
+func newBodyConverterImpl(db convert.PathDB, cptag bool, rmtag bool, cmmt bool, title bool, link bool, rmH1 bool, formatLink bool, anchorFormattingStyle string, linkStyle string, linkRenderer convert.LinkRenderer, imageSizeStyle string, embedFallback string, pathPrefixRemap map[string]string, baseUrl string, backlinks *process.BacklinkIndex, backlinksStyle string, transclusion *transclusionConfig, blockAnchor string, calloutStyle string, highlightTmpl string) *bodyConverterImpl {
 	c := new(bodyConverterImpl)
 	c.db = db
 	c.cptag = cptag
@@ -44,7 +81,18 @@ func newBodyConverterImpl(db convert.PathDB, cptag bool, rmtag bool, cmmt bool,
 	c.rmH1 = rmH1
 	c.formatLink = formatLink
 	c.anchorFormattingStyle = anchorFormattingStyle
+	c.linkStyle = linkStyle
+	c.linkRenderer = linkRenderer
+	c.imageSizeStyle = imageSizeStyle
+	c.embedFallback = embedFallback
 	c.pathPrefixRemap = pathPrefixRemap
+	c.baseUrl = baseUrl
+	c.backlinks = backlinks
+	c.backlinksStyle = backlinksStyle
+	c.transclusion = transclusion
+	c.blockAnchor = blockAnchor
+	c.calloutStyle = calloutStyle
+	c.highlightTmpl = highlightTmpl
 	return c
 }
 
@@ -73,55 +121,155 @@ func (c *bodyConverterImpl) ConvertBody(raw []rune, selfRelativePath string) (ou
 			return nil, nil, errors.Wrap(err, "TitleFinder failed")
 		}
 	}
+	var transcluding []string
+	if c.transclusion != nil {
+		self, err := c.transclusion.vaultPath(selfRelativePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		transcluding = []string{self}
+	}
+	output, err = c.convertOutput(output, selfRelativePath, transcluding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var backlinks []process.Backlink
+	if c.backlinks != nil {
+		if c.backlinksStyle == BACKLINKS_SECTION {
+			output, err = c.appendBacklinksSection(output, selfRelativePath)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "failed to append backlinks")
+			}
+		} else {
+			backlinks, err = c.findBacklinks(selfRelativePath)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "failed to find backlinks")
+			}
+		}
+	}
+
+	aux = newBodyConvAuxOutImpl(title, tags, backlinks, raw)
+	return output, aux, nil
+}
+
+// 本文を変換する
+// transcluding は展開中のノートの vault からの相対パス
+func (c *bodyConverterImpl) convertOutput(raw []rune, selfRelativePath string, transcluding []string) (output []rune, err error) {
+	output = raw
 	if c.rmtag {
 		output, err = convert.NewTagRemover().Convert(output)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "TagRemover failed")
+			return nil, errors.Wrap(err, "TagRemover failed")
 		}
 	}
 	if c.cmmt {
 		output, err = convert.NewCommentEraser().Convert(output)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "CommentEraser failed")
+			return nil, errors.Wrap(err, "CommentEraser failed")
 		}
 	}
 	if c.link {
-		db := c.db
-		if c.formatLink {
-			db = convert.WrapForUsingSelfForEmptyFileId(selfRelativePath, db)
-			db = convert.WrapForTrimmingSuffixMd(db)
-			db = convert.WrapForEncodingPaths(db)
+		output, err = convert.NewBlockIdConverter(c.blockAnchor).Convert(output)
+		if err != nil {
+			return nil, errors.Wrap(err, "BlockIdConverter failed")
 		}
-		if c.pathPrefixRemap != nil {
-			db = convert.WrapForRemappingPathPrefix(c.pathPrefixRemap, db)
+		db := c.linkDB(selfRelativePath)
+		var linkConverter *convert.Converter
+		if c.transclusion != nil {
+			linkConverter = convert.NewLinkConverterWithEmbedsTransformer(db, c.anchorFormattingStyle, c.linkStyle, c.linkRenderer, newTransclusionTransformerImpl(c, db, transcluding))
+		} else {
+			linkConverter = convert.NewLinkConverterWithEmbedsTransformer(db, c.anchorFormattingStyle, c.linkStyle, c.linkRenderer, convert.NewEmbedsTransformer(db, c.imageSizeStyle, c.embedFallback, c.linkRenderer))
 		}
-		output, err = convert.NewLinkConverter(db, c.anchorFormattingStyle).Convert(output)
+		output, err = linkConverter.Convert(output)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "LinkConverter failed")
-		}
-		// if c.baseUrl == "" {
-		// 	output, err = convert.NewLinkConverter(c.db).Convert(output)
-		// 	if err != nil {
-		// 		return nil, nil, errors.Wrap(err, "LinkConverter failed")
-		// 	}
-		// } else {
-		// 	// fmt.Println(newpath)
-		// 	db := convert.WrapForTrimmingSuffixMd(convert.WrapForSettingBaseUrl(c.baseUrl, convert.WrapForUsingSelfForEmptyFileId(selfRelativePath, c.db)))
-		// 	output, err = convert.NewLinkConverter(db).Convert(output)
-		// 	if err != nil {
-		// 		return nil, nil, errors.Wrap(err, "LinkConverter failed")
-		// 	}
-		// }
+			return nil, errors.Wrap(err, "LinkConverter failed")
+		}
 	}
 	if c.rmH1 {
 		output, err = convert.NewH1Remover().Convert(output)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "H1Remover failed")
+			return nil, errors.Wrap(err, "H1Remover failed")
+		}
+	}
+	if c.highlightTmpl != "" {
+		output, err = convert.NewHighlightConverter(c.highlightTmpl).Convert(output)
+		if err != nil {
+			return nil, errors.Wrap(err, "HighlightConverter failed")
+		}
+	}
+	if c.calloutStyle != "" {
+		output, err = convert.NewCalloutConverter(c.calloutStyle).Convert(output)
+		if err != nil {
+			return nil, errors.Wrap(err, "CalloutConverter failed")
 		}
 	}
 
-	aux = newBodyConvAuxOutImpl(title, tags)
-	return output, aux, nil
+	return output, nil
+}
+
+// リンクの変換に使う PathDB
+func (c *bodyConverterImpl) linkDB(selfRelativePath string) convert.PathDB {
+	db := c.db
+	if c.formatLink {
+		db = convert.WrapForUsingSelfForEmptyFileId(selfRelativePath, db)
+		db = convert.WrapForTrimmingSuffixMd(db)
+		db = convert.WrapForEncodingPaths(db)
+	}
+	if c.pathPrefixRemap != nil {
+		db = convert.WrapForRemappingPathPrefix(c.pathPrefixRemap, db)
+	}
+	if c.baseUrl != "" {
+		db = convert.WrapForSettingBaseUrl(c.baseUrl, db)
+	}
+	return db
+}
+
+// リンク元のパスをリンクと同じ形式に変換して返す
+func (c *bodyConverterImpl) findBacklinks(selfRelativePath string) ([]process.Backlink, error) {
+	found, err := c.backlinks.Get(selfRelativePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	db := c.linkDB(selfRelativePath)
+	backlinks := make([]process.Backlink, 0, len(found))
+	for _, b := range found {
+		path, err := db.Get(b.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, "PathDB.Get failed")
+		}
+		backlinks = append(backlinks, process.Backlink{Path: path, Title: b.Title})
+	}
+	return backlinks, nil
+}
+
+// リンク元へのリンクを本文中のリンクと同じ形式で末尾に追加する
+func (c *bodyConverterImpl) appendBacklinksSection(body []rune, selfRelativePath string) ([]rune, error) {
+	found, err := c.backlinks.Get(selfRelativePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return body, nil
+	}
+	db := c.linkDB(selfRelativePath)
+	b := new(strings.Builder)
+	b.WriteString(string(body))
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	b.WriteString("\n" + BACKLINKS_SECTION_HEADER + "\n\n")
+	for _, backlink := range found {
+		link, err := convert.RenderNoteLink(db, c.linkStyle, c.linkRenderer, backlink.Path, backlink.Title)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to render a backlink")
+		}
+		fmt.Fprintf(b, "- %s\n", link)
+	}
+	return []rune(b.String()), nil
 }
 
 func parsePathPrefixRemap(input string) (remap map[string]string, err error) {
@@ -139,3 +287,35 @@ func parsePathPrefixRemap(input string) (remap map[string]string, err error) {
 	}
 	return remap, nil
 }
+
+// "prefix>pattern|pattern" の形式
+// prefix を省略したパターンはキーが空文字列になり, すべてのノートに一致する
+func parsePermalinks(input string) (patterns map[string]string, err error) {
+	if input == "" {
+		return nil, nil
+	}
+	patterns = make(map[string]string)
+	for _, entry := range strings.Split(input, "|") {
+		pair := strings.Split(entry, ">")
+		var prefix, pattern string
+		switch len(pair) {
+		case 1:
+			pattern = pair[0]
+		case 2:
+			prefix, pattern = pair[0], pair[1]
+		default:
+			return nil, newMainErrf(MAIN_ERR_KIND_INVALID_PERMALINK, "invalid format of %s: \"%s\"", FLAG_PERMALINK, input)
+		}
+		if pattern == "" {
+			return nil, newMainErrf(MAIN_ERR_KIND_INVALID_PERMALINK, "empty pattern in %s: \"%s\"", FLAG_PERMALINK, input)
+		}
+		if _, ok := patterns[prefix]; ok {
+			return nil, newMainErrf(MAIN_ERR_KIND_INVALID_PERMALINK, "duplicate prefix %q in %s", prefix, FLAG_PERMALINK)
+		}
+		if err := process.VerifyPermalinkPattern(pattern); err != nil {
+			return nil, newMainErrf(MAIN_ERR_KIND_INVALID_PERMALINK, "invalid %s: %v", FLAG_PERMALINK, err)
+		}
+		patterns[prefix] = pattern
+	}
+	return patterns, nil
+}
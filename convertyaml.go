@@ -10,35 +10,77 @@ import (
 )
 
 type yamlConvAuxInImpl struct {
-	title   string
-	alias   string
-	newtags []string
+	title     string
+	alias     string
+	newtags   []string
+	backlinks []process.Backlink
+	body      []rune // 変換前の本文
 }
 
-func newYamlConvAuxInImpl(title string, alias string, newtags []string) *yamlConvAuxInImpl {
+func newYamlConvAuxInImpl(title string, alias string, newtags []string, backlinks []process.Backlink, body []rune) *yamlConvAuxInImpl {
 	return &yamlConvAuxInImpl{
-		title:   title,
-		alias:   alias,
-		newtags: newtags,
+		title:     title,
+		alias:     alias,
+		newtags:   newtags,
+		backlinks: backlinks,
+		body:      body,
 	}
 }
 
 type yamlConverterImpl struct {
-	synctag     bool
-	synctlal    bool
-	publishable bool
-	remap       map[string]string
+	synctag   bool
+	synctlal  bool
+	pubMarker *publishMarker
+	remap     map[string]string
+	rules     yamlRules
 }
 
-func newYamlConverterImpl(synctag bool, synctlal bool, publishable bool, remap map[string]string) *yamlConverterImpl {
+// pubMarker が nil なら公開状態を書き込まない
+// rules は remap の後に適用する
+func newYamlConverterImpl(synctag bool, synctlal bool, pubMarker *publishMarker, remap map[string]string, rules yamlRules) *yamlConverterImpl {
 	return &yamlConverterImpl{
-		synctag:     synctag,
-		synctlal:    synctlal,
-		publishable: publishable,
-		remap:       remap,
+		synctag:   synctag,
+		synctlal:  synctlal,
+		pubMarker: pubMarker,
+		remap:     remap,
+		rules:     rules,
 	}
 }
 
+const (
+	PUB_MARKER_DRAFT = "draft" // draft がなければ draft: false を書き込む
+	PUB_MARKER_NONE  = "none"  // 何も書き込まない
+)
+
+// -pub のときに front matter に書き込む公開状態
+type publishMarker struct {
+	kind  string // PUB_MARKER_DRAFT, PUB_MARKER_NONE または空文字列 (key に value を書き込む)
+	key   string
+	value interface{} // yaml として読んだ値
+}
+
+// "draft", "none" または "key=value" の形式
+func parsePubMarker(input string) (marker *publishMarker, err error) {
+	switch input {
+	case PUB_MARKER_DRAFT, PUB_MARKER_NONE:
+		return &publishMarker{kind: input}, nil
+	}
+	pair := strings.SplitN(input, "=", 2)
+	if len(pair) != 2 || pair[0] == "" {
+		return nil, newMainErrf(MAIN_ERR_KIND_INVALID_PUB_MARKER, "invalid format of %s: %q. must be %s, %s or key=value", FLAG_PUB_MARKER, input, PUB_MARKER_DRAFT, PUB_MARKER_NONE)
+	}
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(pair[1]), &value); err != nil {
+		return nil, newMainErrf(MAIN_ERR_KIND_INVALID_PUB_MARKER, "invalid value of %s: %q", FLAG_PUB_MARKER, pair[1])
+	}
+	switch value.(type) {
+	case bool, int, float64, string:
+	default:
+		return nil, newMainErrf(MAIN_ERR_KIND_INVALID_PUB_MARKER, "value of %s must be a boolean, number or string: %q", FLAG_PUB_MARKER, pair[1])
+	}
+	return &publishMarker{key: pair[0], value: value}, nil
+}
+
 func parseRemap(input string) (remap map[string]string, err error) {
 	remap = make(map[string]string)
 	if input == "" {
@@ -59,6 +101,8 @@ func (c *yamlConverterImpl) ConvertYAML(raw []byte, aux process.YamlConvAuxIn) (
 	title := ""
 	alias := ""
 	var newtags []string
+	var backlinks []process.Backlink
+	var body []rune
 
 	if v, ok := aux.(*yamlConvAuxInImpl); !ok {
 		return nil, errors.New("input (YamlConverterInput) cannot be converted to yamlConverterInputImpl")
@@ -66,6 +110,8 @@ func (c *yamlConverterImpl) ConvertYAML(raw []byte, aux process.YamlConvAuxIn) (
 		title = v.title
 		alias = v.alias
 		newtags = v.newtags
+		backlinks = v.backlinks
+		body = v.body
 	}
 
 	m := make(map[interface{}]interface{})
@@ -154,22 +200,37 @@ func (c *yamlConverterImpl) ConvertYAML(raw []byte, aux process.YamlConvAuxIn) (
 		}
 	}
 
+	// backlinks
+	// 既存のフィールドは上書きする
+	if len(backlinks) > 0 {
+		entries := make([]map[string]string, 0, len(backlinks))
+		for _, b := range backlinks {
+			entries = append(entries, map[string]string{
+				"path":  b.Path,
+				"title": b.Title,
+			})
+		}
+		m["backlinks"] = entries
+	}
+
 	// publishable -> draft
-	// if draft field already exists, then keep it as is.
-	_, ok := m["draft"]
-	if !ok && c.publishable {
-		if p, ok := m["publish"]; !ok {
-			m["draft"] = true
-		} else {
-			if publishable, ok := p.(bool); !ok {
-				return nil, fmt.Errorf("publish field found but its field type is not bool: %T", p)
-			} else {
-				m["draft"] = !publishable
+	if c.pubMarker != nil {
+		switch c.pubMarker.kind {
+		case PUB_MARKER_DRAFT:
+			// ここに来るノートは pubRule を満たしているので公開する
+			// if draft field already exists, then keep it as is.
+			if _, ok := m["draft"]; !ok {
+				m["draft"] = false
 			}
+		case PUB_MARKER_NONE:
+		default:
+			// 既存のフィールドは上書きする
+			m[c.pubMarker.key] = c.pubMarker.value
 		}
 	}
 
 	// remap keys in front matter
+	renamed := make(map[interface{}]interface{})
 	if len(c.remap) > 0 {
 		for oldKey, newKey := range c.remap {
 			v, ok := m[oldKey]
@@ -181,16 +242,19 @@ func (c *yamlConverterImpl) ConvertYAML(raw []byte, aux process.YamlConvAuxIn) (
 				continue
 			}
 			m[newKey] = v
+			renamed[oldKey] = newKey
 		}
 	}
 
+	// rules
+	if err := c.rules.apply(m, body); err != nil {
+		return nil, err
+	}
+
 	// for empty front matters
 	if len(m) == 0 {
 		return nil, nil
 	}
-	output, err = yaml.Marshal(m)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal output yaml: %w", err)
-	}
-	return output, nil
+	// 変更したキー以外は元の書式のまま残す
+	return marshalFrontMatter(raw, m, renamed)
 }
@@ -2,40 +2,78 @@ package main
 
 import (
 	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/process"
 	"gopkg.in/yaml.v2"
 )
 
-type yamlExaminatorImpl struct {
+// publish: true または draft: false のノートを公開する
+const DEFAULT_PUB_RULE = "draft == false || !has(draft) && publish == true"
+
+type noteExaminatorImpl struct {
+	vault       string
 	filter      string
+	filterNode  *nodeImpl // filter の構文木
 	publishable bool
+	pubRule     string
+	pubRuleNode *nodeImpl // pubRule の構文木. publishable が false なら nil
 }
 
-func newYamlExaminatorImpl(filter string, publishable bool) *yamlExaminatorImpl {
-	return &yamlExaminatorImpl{
+// vault はフィルタの path: で使う相対パスの基準
+// pubRule は publishable が true のときに公開するノートの条件. filter と同じ記法
+// filter と pubRule はここで一度だけ構文木にする
+func newNoteExaminatorImpl(vault string, filter string, publishable bool, pubRule string) (*noteExaminatorImpl, error) {
+	examinator := &noteExaminatorImpl{
+		vault:       vault,
 		filter:      filter,
 		publishable: publishable,
+		pubRule:     pubRule,
+	}
+	nd, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
 	}
+	examinator.filterNode = nd
+	if publishable {
+		nd, err := parseFilter(pubRule)
+		if err != nil {
+			return nil, filterErrFor(FLAG_PUB_RULE, err)
+		}
+		examinator.pubRuleNode = nd
+	}
+	return examinator, nil
 }
 
-func (examinator *yamlExaminatorImpl) ExamineYaml(yml []byte) (beProcessed bool, err error) {
+func (examinator *noteExaminatorImpl) ExamineNote(yml []byte, note *process.NoteInfo) (beProcessed bool, err error) {
 	fm := make(map[interface{}]interface{})
 	if err := yaml.Unmarshal(yml, fm); err != nil {
 		return false, errors.Wrap(err, "failed to unmarshal front matter")
 	}
 
+	rpath, err := filepath.Rel(examinator.vault, note.Path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get the path of %s relative to %s", note.Path, examinator.vault)
+	}
+	rpath = filepath.ToSlash(rpath)
+
 	if examinator.publishable {
-		if ok, err := checkPublishable(fm); err != nil {
-			return false, errors.Wrap(err, "failed to check publish or draft field in front matter")
+		if ok, err := evaluateFilter(fm, rpath, note.Tags, examinator.pubRule, examinator.pubRuleNode); err != nil {
+			return false, errors.Wrap(filterErrFor(FLAG_PUB_RULE, err), "failed to check whether the note is published")
 		} else if !ok {
 			return false, nil
 		}
 	}
 
 	if examinator.filter != "" {
-		if ok, err := checkFilter(fm, examinator.filter); err != nil {
+		if ok, err := evaluateFilter(fm, rpath, note.Tags, examinator.filter, examinator.filterNode); err != nil {
 			return false, errors.Wrap(err, "failed to check filter field in front matter")
 		} else if !ok {
 			return false, nil
@@ -45,69 +83,316 @@ func (examinator *yamlExaminatorImpl) ExamineYaml(yml []byte) (beProcessed bool,
 	return true, nil
 }
 
-func checkPublishable(fm map[interface{}]interface{}) (ok bool, err error) {
-	if vdraft, ok := fm["draft"]; ok {
-		if isdraft, ok := vdraft.(bool); ok && !isdraft {
-			return true, nil
-		}
-	} else if vpublishable, ok := fm["publish"]; ok {
-		if ispublishable, ok := vpublishable.(bool); ok && ispublishable {
-			return true, nil
-		}
+// rpath は vault からの相対パス, tags は本文中のタグ
+func checkFilter(fm map[interface{}]interface{}, rpath string, tags map[string]struct{}, filter string) (value bool, err error) {
+	nd, err := parseFilter(filter)
+	if err != nil {
+		return false, err
 	}
-	return false, nil
+	return evaluateFilter(fm, rpath, tags, filter, nd)
 }
 
-func checkFilter(fm map[interface{}]interface{}, filter string) (value bool, err error) {
+// nd は parseFilter(filter) で作った構文木. filter はエラーメッセージにだけ使う
+func evaluateFilter(fm map[interface{}]interface{}, rpath string, tags map[string]struct{}, filter string, nd *nodeImpl) (value bool, err error) {
+	evaluator := &filterEvaluator{filter: filter, fm: fm, rpath: rpath, tags: tags}
+	return evaluator.evaluateBool(nd)
+}
+
+// filter を構文木にする
+// 空文字列ならすべてのノートに一致する
+func parseFilter(filter string) (nd *nodeImpl, err error) {
 	token, err := tokenizeFilter(filter)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	nd, _, err := parseTokens(token)
+	if token.kind == TOKEN_EOS {
+		return newParentNode(NODE_TRUE, nil, nil), nil
+	}
+	parser := &filterParser{filter: filter}
+	nd, cur, err := parser.parseTokens(token)
 	if err != nil {
-		return false, newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT)
+		return nil, err
+	}
+	if cur.kind != TOKEN_EOS {
+		return nil, parser.unexpected(cur)
 	}
+	return nd, nil
+}
+
+// front matter の値の日付として受け付ける形式
+var filterDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
 
-	return evaluateNode(nd, fm)
+type filterEvaluator struct {
+	filter string
+	fm     map[interface{}]interface{}
+	rpath  string              // vault からの相対パス
+	tags   map[string]struct{} // 本文中のタグ
 }
 
-func evaluateNode(nd *nodeImpl, fm map[interface{}]interface{}) (value bool, err error) {
-	if nd.kind == NODE_IDENT {
-		name := nd.name
-		if v, ok := fm[name]; ok {
-			if value, ok := v.(bool); ok {
-				return value, nil
-			}
-		} else {
-			return false, nil
-		}
-		return false, newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT)
-	} else if nd.kind == NODE_TRUE {
+func (e *filterEvaluator) evaluateBool(nd *nodeImpl) (value bool, err error) {
+	switch nd.kind {
+	case NODE_TRUE:
 		return true, nil
-	} else if nd.kind == NODE_NOT {
-		if v, err := evaluateNode(nd.left, fm); err != nil {
+	case NODE_NOT:
+		v, err := e.evaluateBool(nd.left)
+		if err != nil {
+			return false, err
+		}
+		return !v, nil
+	case NODE_AND, NODE_OR:
+		left, err := e.evaluateBool(nd.left)
+		if err != nil {
 			return false, err
-		} else {
-			return !v, nil
 		}
+		if (nd.kind == NODE_AND && !left) || (nd.kind == NODE_OR && left) {
+			return left, nil
+		}
+		return e.evaluateBool(nd.right)
+	case NODE_HAS:
+		v, ok := e.fm[nd.name]
+		return ok && v != nil, nil
+	case NODE_COMPARE:
+		return e.compare(nd)
+	case NODE_TAG:
+		return e.hasTag(nd.name), nil
+	case NODE_PATH:
+		return matchPathPattern(strings.Split(nd.name, "/"), strings.Split(e.rpath, "/")), nil
+	}
+
+	v, found, err := e.evaluateValue(nd)
+	if err != nil {
+		return false, err
+	}
+	// 存在しないキーは false として扱う
+	if !found {
+		return false, nil
+	}
+	value, ok := v.(bool)
+	if !ok {
+		return false, newFilterErrAt(FILTER_ERROR_EVALUATE, e.filter, nd.pos, "%s is not boolean: %v", describeNode(nd), v)
+	}
+	return value, nil
+}
+
+// found = false ならキーが front matter に存在しない
+func (e *filterEvaluator) evaluateValue(nd *nodeImpl) (value interface{}, found bool, err error) {
+	switch nd.kind {
+	case NODE_IDENT:
+		v, ok := e.fm[nd.name]
+		if !ok || v == nil {
+			return nil, false, nil
+		}
+		return normalizeFilterValue(v), true, nil
+	case NODE_STRING, NODE_NUMBER, NODE_DATE, NODE_BOOL:
+		return nd.value, true, nil
+	case NODE_NOW:
+		return time.Now(), true, nil
+	default:
+		v, err := e.evaluateBool(nd)
+		if err != nil {
+			return nil, false, err
+		}
+		return v, true, nil
 	}
+}
 
-	left, err := evaluateNode(nd.left, fm)
+// 存在しないキーとの比較は != 以外 false になる
+func (e *filterEvaluator) compare(nd *nodeImpl) (value bool, err error) {
+	left, lfound, err := e.evaluateValue(nd.left)
 	if err != nil {
 		return false, err
 	}
-	right, err := evaluateNode(nd.right, fm)
+	right, rfound, err := e.evaluateValue(nd.right)
 	if err != nil {
 		return false, err
 	}
+	if !lfound || !rfound {
+		return nd.op == TOKEN_NE, nil
+	}
 
-	if nd.kind == NODE_AND {
-		return left && right, nil
-	} else {
-		return left || right, nil
+	switch nd.op {
+	case TOKEN_EQ:
+		return equalFilterValues(left, right), nil
+	case TOKEN_NE:
+		return !equalFilterValues(left, right), nil
+	case TOKEN_IN:
+		switch r := right.(type) {
+		case []interface{}:
+			for _, item := range r {
+				if equalFilterValues(left, normalizeFilterValue(item)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			l, ok := left.(string)
+			if !ok {
+				return false, newFilterErrAt(FILTER_ERROR_EVALUATE, e.filter, nd.left.pos, "%s is not a string: %v", describeNode(nd.left), left)
+			}
+			return strings.Contains(r, l), nil
+		default:
+			return false, newFilterErrAt(FILTER_ERROR_EVALUATE, e.filter, nd.right.pos, "%s is neither a list nor a string: %v", describeNode(nd.right), right)
+		}
+	case TOKEN_MATCH, TOKEN_NOT_MATCH:
+		l, ok := left.(string)
+		if !ok {
+			return false, newFilterErrAt(FILTER_ERROR_EVALUATE, e.filter, nd.left.pos, "%s is not a string: %v", describeNode(nd.left), left)
+		}
+		return nd.pattern.MatchString(l) == (nd.op == TOKEN_MATCH), nil
 	}
 
+	c, ok := orderFilterValues(left, right)
+	if !ok {
+		return false, newFilterErrAt(FILTER_ERROR_EVALUATE, e.filter, nd.pos, "cannot compare %v with %v", left, right)
+	}
+	switch nd.op {
+	case TOKEN_LT:
+		return c < 0, nil
+	case TOKEN_LE:
+		return c <= 0, nil
+	case TOKEN_GT:
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// 本文か front matter の tags にタグがあるかどうか
+// Obsidian と同じく tag:a は #a/b にも一致する
+func (e *filterEvaluator) hasTag(name string) bool {
+	tags := make([]string, 0, len(e.tags))
+	for t := range e.tags {
+		tags = append(tags, t)
+	}
+	switch v := e.fm["tags"].(type) {
+	case []interface{}:
+		for _, t := range v {
+			if tt, ok := t.(string); ok {
+				tags = append(tags, tt)
+			}
+		}
+	case string:
+		tags = append(tags, v)
+	}
+	for _, t := range tags {
+		t = strings.TrimPrefix(t, "#")
+		if strings.EqualFold(t, name) || strings.HasPrefix(strings.ToLower(t), strings.ToLower(name)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ** は 0 個以上のディレクトリに一致する. それ以外は path.Match と同じ
+func matchPathPattern(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchPathPattern(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+		return false
+	}
+	return matchPathPattern(pattern[1:], segments[1:])
+}
+
+// 数値はすべて float64 にそろえる
+func normalizeFilterValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	default:
+		return v
+	}
+}
+
+func equalFilterValues(a, b interface{}) bool {
+	if c, ok := orderFilterValues(a, b); ok {
+		return c == 0
+	}
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok {
+			return x == y
+		}
+	}
+	return false
+}
+
+// 順序を比べられない組み合わせなら ok = false
+// 一方が日付 (now を含む) ならもう一方も日付として読む
+func orderFilterValues(a, b interface{}) (c int, ok bool) {
+	if _, isDate := a.(time.Time); isDate {
+		return orderFilterDates(a, b)
+	}
+	if _, isDate := b.(time.Time); isDate {
+		return orderFilterDates(a, b)
+	}
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	}
+	return 0, false
+}
+
+func orderFilterDates(a, b interface{}) (c int, ok bool) {
+	x, ok := filterDate(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := filterDate(b)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case x.Before(y):
+		return -1, true
+	case x.After(y):
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func filterDate(v interface{}) (date time.Time, ok bool) {
+	switch d := v.(type) {
+	case time.Time:
+		return d, true
+	case string:
+		for _, layout := range filterDateLayouts {
+			if date, err := time.Parse(layout, d); err == nil {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
 }
 
 type tokenKind = uint
@@ -119,14 +404,60 @@ const (
 	TOKEN_NOT
 	TOKEN_RESERVED
 	TOKEN_EOS
+	TOKEN_STRING    // "text" または 'text'
+	TOKEN_NUMBER    // 2, -1.5
+	TOKEN_DATE      // 2024-01-01
+	TOKEN_TRUE      // true
+	TOKEN_FALSE     // false
+	TOKEN_EQ        // ==
+	TOKEN_NE        // !=
+	TOKEN_LT        // <
+	TOKEN_LE        // <=
+	TOKEN_GT        // >
+	TOKEN_GE        // >=
+	TOKEN_IN        // in
+	TOKEN_MATCH     // =~
+	TOKEN_NOT_MATCH // !~
+	TOKEN_TAG       // tag:name
+	TOKEN_PATH      // path:pattern
+	TOKEN_NOW       // now
 )
 
 type tokenImpl struct {
 	kind tokenKind
-	name string
+	name string // 文字列リテラルならクォートを外した値
+	text string // filter に書かれたままの文字列
+	pos  int    // filter 内の位置 (rune 単位)
 	next *tokenImpl
 }
 
+// 長いものから順に試す
+var filterOperators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"&&", TOKEN_AND},
+	{"||", TOKEN_OR},
+	{"==", TOKEN_EQ},
+	{"!=", TOKEN_NE},
+	{"<=", TOKEN_LE},
+	{">=", TOKEN_GE},
+	{"=~", TOKEN_MATCH},
+	{"!~", TOKEN_NOT_MATCH},
+	{"<", TOKEN_LT},
+	{">", TOKEN_GT},
+	{"!", TOKEN_NOT},
+}
+
+func isComparisonToken(kind tokenKind) bool {
+	switch kind {
+	case TOKEN_EQ, TOKEN_NE, TOKEN_LT, TOKEN_LE, TOKEN_GT, TOKEN_GE, TOKEN_IN, TOKEN_MATCH, TOKEN_NOT_MATCH:
+		return true
+	default:
+		return false
+	}
+}
+
 type nodeKind uint
 
 const (
@@ -135,22 +466,48 @@ const (
 	NODE_OR
 	NODE_NOT
 	NODE_TRUE
+	NODE_STRING
+	NODE_NUMBER
+	NODE_DATE
+	NODE_BOOL
+	NODE_HAS     // has(key)
+	NODE_COMPARE // ==, !=, <, <=, >, >=, in, =~, !~
+	NODE_TAG     // tag:name
+	NODE_PATH    // path:pattern
+	NODE_NOW     // 評価した時刻
 )
 
 type nodeImpl struct {
-	kind  nodeKind
-	name  string
-	left  *nodeImpl
-	right *nodeImpl
+	kind    nodeKind
+	name    string
+	value   interface{}    // リテラルの値
+	op      tokenKind      // NODE_COMPARE の演算子
+	pattern *regexp.Regexp // =~ と !~ の右辺
+	pos     int            // filter 内の位置 (rune 単位)
+	left    *nodeImpl
+	right   *nodeImpl
 }
 
-func parseTokens(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
-	left, cur, err := andNode(cur)
+// エラーメッセージ用
+func describeNode(nd *nodeImpl) string {
+	if nd.kind == NODE_IDENT {
+		return nd.name
+	}
+	return "value"
+}
+
+// 優先順位の低い順に ||, &&, !, 比較演算子
+type filterParser struct {
+	filter string
+}
+
+func (p *filterParser) parseTokens(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
+	left, cur, err := p.andNode(cur)
 	if err != nil {
 		return nil, nil, err
 	}
-	if cur != nil && cur.kind == TOKEN_OR {
-		right, next, err := parseTokens(cur.next)
+	if cur.kind == TOKEN_OR {
+		right, next, err := p.parseTokens(cur.next)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -159,134 +516,330 @@ func parseTokens(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
 	return left, cur, nil
 }
 
-func andNode(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
-	left, cur, err := unaryNode(cur)
+func (p *filterParser) andNode(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
+	left, cur, err := p.unaryNode(cur)
 	if err != nil {
 		return nil, nil, err
 	}
-	if cur != nil && cur.kind == TOKEN_AND {
-		right, next, err := andNode(cur.next)
+	if cur.kind == TOKEN_AND {
+		right, next, err := p.andNode(cur.next)
 		if err != nil {
 			return nil, nil, err
 		}
 		return newParentNode(NODE_AND, left, right), next, nil
 	}
-	return left, cur, err
+	return left, cur, nil
 }
 
-func unaryNode(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
-	if cur == nil {
-		return nil, nil, newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT)
-	}
+func (p *filterParser) unaryNode(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
 	if cur.kind == TOKEN_NOT {
-		left, next, err := unaryNode(cur.next)
+		left, next, err := p.unaryNode(cur.next)
 		if err != nil {
 			return nil, nil, err
 		}
 		nd = newParentNode(NODE_NOT, left, nil)
 		return nd, next, nil
 	}
-	return primaryNode(cur)
+	return p.comparisonNode(cur)
 }
 
-func primaryNode(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
-	if cur == nil {
-		return nil, nil, newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT)
+func (p *filterParser) comparisonNode(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
+	left, cur, err := p.primaryNode(cur)
+	if err != nil {
+		return nil, nil, err
 	}
-	if cur.kind == TOKEN_IDENT {
-		next = cur.next
-		return newIdentNode(cur.name), next, nil
+	if !isComparisonToken(cur.kind) {
+		return left, cur, nil
 	}
-	if cur.kind == TOKEN_RESERVED && cur.name == "(" {
-		nd, cur, err = parseTokens(cur.next)
+	op := cur
+	right, next, err := p.primaryNode(cur.next)
+	if err != nil {
+		return nil, nil, err
+	}
+	nd = newParentNode(NODE_COMPARE, left, right)
+	nd.op = op.kind
+	nd.pos = op.pos
+	if op.kind == TOKEN_MATCH || op.kind == TOKEN_NOT_MATCH {
+		if right.kind != NODE_STRING {
+			return nil, nil, newFilterErrAt(FILTER_ERROR_PARSE, p.filter, right.pos, "right side of %s must be a string", op.text)
+		}
+		pattern, err := regexp.Compile(right.value.(string))
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, newFilterErrAt(FILTER_ERROR_PARSE, p.filter, right.pos, "invalid regular expression: %v", err)
+		}
+		nd.pattern = pattern
+	}
+	return nd, next, nil
+}
+
+func (p *filterParser) primaryNode(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
+	switch cur.kind {
+	case TOKEN_IDENT:
+		if cur.name == "has" && cur.next.kind == TOKEN_RESERVED && cur.next.name == "(" {
+			return p.hasNode(cur)
+		}
+		return newIdentNode(cur.name, cur.pos), cur.next, nil
+	case TOKEN_STRING:
+		return newValueNode(NODE_STRING, cur.name, cur.pos), cur.next, nil
+	case TOKEN_NUMBER:
+		v, err := strconv.ParseFloat(cur.text, 64)
+		if err != nil {
+			return nil, nil, newFilterErrAt(FILTER_ERROR_PARSE, p.filter, cur.pos, "invalid number %s", cur.text)
+		}
+		return newValueNode(NODE_NUMBER, v, cur.pos), cur.next, nil
+	case TOKEN_DATE:
+		v, err := time.Parse("2006-01-02", cur.text)
+		if err != nil {
+			return nil, nil, newFilterErrAt(FILTER_ERROR_PARSE, p.filter, cur.pos, "invalid date %s", cur.text)
 		}
-		if cur.kind == TOKEN_RESERVED && cur.name == ")" {
-			next = cur.next
-			return nd, next, nil
+		return newValueNode(NODE_DATE, v, cur.pos), cur.next, nil
+	case TOKEN_TRUE, TOKEN_FALSE:
+		return newValueNode(NODE_BOOL, cur.kind == TOKEN_TRUE, cur.pos), cur.next, nil
+	case TOKEN_NOW:
+		return newValueNode(NODE_NOW, nil, cur.pos), cur.next, nil
+	case TOKEN_TAG:
+		nd = newIdentNode(strings.TrimPrefix(cur.name, "#"), cur.pos)
+		nd.kind = NODE_TAG
+		return nd, cur.next, nil
+	case TOKEN_PATH:
+		if _, err := path.Match(cur.name, ""); err != nil {
+			return nil, nil, newFilterErrAt(FILTER_ERROR_PARSE, p.filter, cur.pos, "invalid path pattern %s", cur.name)
 		}
+		// 末尾の / はディレクトリ以下のすべてのファイルに一致させる
+		name := strings.TrimPrefix(cur.name, "/")
+		if strings.HasSuffix(name, "/") {
+			name += "**"
+		}
+		nd = newIdentNode(name, cur.pos)
+		nd.kind = NODE_PATH
+		return nd, cur.next, nil
+	case TOKEN_RESERVED:
+		if cur.name == "(" {
+			nd, cur, err = p.parseTokens(cur.next)
+			if err != nil {
+				return nil, nil, err
+			}
+			if cur.kind == TOKEN_RESERVED && cur.name == ")" {
+				return nd, cur.next, nil
+			}
+			return nil, nil, newFilterErrAt(FILTER_ERROR_PARSE, p.filter, cur.pos, "expected )")
+		}
+	}
+	return nil, nil, p.unexpected(cur)
+}
+
+// has(key)
+func (p *filterParser) hasNode(cur *tokenImpl) (nd *nodeImpl, next *tokenImpl, err error) {
+	key := cur.next.next
+	if key.kind != TOKEN_IDENT {
+		return nil, nil, newFilterErrAt(FILTER_ERROR_PARSE, p.filter, key.pos, "expected a key in has()")
+	}
+	if closing := key.next; !(closing.kind == TOKEN_RESERVED && closing.name == ")") {
+		return nil, nil, newFilterErrAt(FILTER_ERROR_PARSE, p.filter, closing.pos, "expected )")
 	}
+	nd = newIdentNode(key.name, cur.pos)
+	nd.kind = NODE_HAS
+	return nd, key.next.next, nil
+}
+
+func (p *filterParser) unexpected(cur *tokenImpl) *FilterErr {
 	if cur.kind == TOKEN_EOS {
-		return newParentNode(NODE_TRUE, nil, nil), nil, nil
+		return newFilterErrAt(FILTER_ERROR_PARSE, p.filter, cur.pos, "unexpected end of filter")
 	}
-	return nil, nil, newMainErr(MAIN_ERR_KIND_INVALID_FILTER_FORMAT)
+	return newFilterErrAt(FILTER_ERROR_PARSE, p.filter, cur.pos, "unexpected %s", cur.text)
 }
 
 func newParentNode(kind nodeKind, left *nodeImpl, right *nodeImpl) *nodeImpl {
-	return &nodeImpl{
+	nd := &nodeImpl{
 		kind:  kind,
 		left:  left,
 		right: right,
 	}
+	if left != nil {
+		nd.pos = left.pos
+	}
+	return nd
 }
 
-func newIdentNode(name string) *nodeImpl {
+func newIdentNode(name string, pos int) *nodeImpl {
 	return &nodeImpl{
 		kind: NODE_IDENT,
 		name: name,
+		pos:  pos,
 	}
 }
 
-func newTokenImpl(kind tokenKind, name string, pre *tokenImpl) *tokenImpl {
+func newValueNode(kind nodeKind, value interface{}, pos int) *nodeImpl {
+	return &nodeImpl{
+		kind:  kind,
+		value: value,
+		pos:   pos,
+	}
+}
+
+func newTokenImpl(kind tokenKind, name string, text string, pos int, pre *tokenImpl) *tokenImpl {
 	child := &tokenImpl{
 		kind: kind,
 		name: name,
+		text: text,
+		pos:  pos,
 	}
 	pre.next = child
 	return child
 }
 
+var (
+	filterDatePattern   = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}`)
+	filterNumberPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?`)
+)
+
 func tokenizeFilter(input string) (token *tokenImpl, err error) {
 	head := new(tokenImpl)
 	runes := []rune(input)
 	cur := head
 	p := 0
-	for i := 0; i < len(runes); i++ {
-		if p >= len(runes) {
-			break
+	for p < len(runes) {
+		if unicode.IsSpace(runes[p]) {
+			p++
+			continue
 		}
 
-		if p < len(runes)-1 {
-			if string(runes[p:p+2]) == "&&" {
-				cur = newTokenImpl(TOKEN_AND, "", cur)
-				p += 2
-				continue
-			}
+		if kind, length := consumeOperator(runes[p:]); length > 0 {
+			text := string(runes[p : p+length])
+			cur = newTokenImpl(kind, text, text, p, cur)
+			p += length
+			continue
+		}
 
-			if string(runes[p:p+2]) == "||" {
-				cur = newTokenImpl(TOKEN_OR, "", cur)
-				p += 2
-				continue
-			}
+		if runes[p] == '(' || runes[p] == ')' {
+			text := string(runes[p : p+1])
+			cur = newTokenImpl(TOKEN_RESERVED, text, text, p, cur)
+			p++
+			continue
+		}
 
+		if runes[p] == '"' || runes[p] == '\'' {
+			value, length := consumeString(runes[p:])
+			if length == 0 {
+				return nil, newTokinizeErr(input, p, "unterminated string")
+			}
+			cur = newTokenImpl(TOKEN_STRING, value, string(runes[p:p+length]), p, cur)
+			p += length
+			continue
 		}
 
-		if string(runes[p:p+1]) == "!" {
-			cur = newTokenImpl(TOKEN_NOT, "", cur)
-			p++
+		if kind, value, length := consumePredicate(runes[p:]); length > 0 {
+			cur = newTokenImpl(kind, value, string(runes[p:p+length]), p, cur)
+			p += length
 			continue
+		} else if length < 0 {
+			return nil, newTokinizeErr(input, p, "empty or unterminated value")
 		}
 
-		if runes[p] == '(' || runes[p] == ')' {
-			cur = newTokenImpl(TOKEN_RESERVED, string(runes[p:p+1]), cur)
-			p++
+		if kind, length := consumeLiteral(runes[p:]); length > 0 {
+			text := string(runes[p : p+length])
+			cur = newTokenImpl(kind, text, text, p, cur)
+			p += length
 			continue
 		}
 
 		if length := consumeIdent(runes[p:]); length > 0 {
-			cur = newTokenImpl(TOKEN_IDENT, string(runes[p:p+length]), cur)
+			text := string(runes[p : p+length])
+			var kind tokenKind = TOKEN_IDENT
+			switch text {
+			case "true":
+				kind = TOKEN_TRUE
+			case "false":
+				kind = TOKEN_FALSE
+			case "in":
+				kind = TOKEN_IN
+			case "now":
+				kind = TOKEN_NOW
+			}
+			cur = newTokenImpl(kind, text, text, p, cur)
 			p += length
 			continue
 		}
 
-		return nil, newTokinizeErr(string(runes), p)
-
+		return nil, newTokinizeErr(input, p, "unexpected character %q", runes[p])
 	}
-	cur = newTokenImpl(TOKEN_EOS, "", cur)
+	newTokenImpl(TOKEN_EOS, "", "", p, cur)
 	return head.next, nil
 }
 
+func consumeOperator(runes []rune) (kind tokenKind, length int) {
+	for _, op := range filterOperators {
+		n := len([]rune(op.text))
+		if len(runes) >= n && string(runes[:n]) == op.text {
+			return op.kind, n
+		}
+	}
+	return 0, 0
+}
+
+// クォートを含めた長さを返す. 閉じていなければ length = 0
+// \ の次の文字はそのまま値に含める
+func consumeString(runes []rune) (value string, length int) {
+	quote := runes[0]
+	b := new(strings.Builder)
+	for i := 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				b.WriteRune(runes[i])
+			}
+		case quote:
+			return b.String(), i + 1
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0
+}
+
+// tag:name と path:pattern. 値は空白, 括弧, & または | の直前まで. それらを含むならクォートで囲む
+// tag: や path: で始まらなければ length = 0, 値が空かクォートが閉じていなければ length < 0
+func consumePredicate(runes []rune) (kind tokenKind, value string, length int) {
+	var prefix string
+	switch {
+	case strings.HasPrefix(string(runes), "tag:"):
+		kind, prefix = TOKEN_TAG, "tag:"
+	case strings.HasPrefix(string(runes), "path:"):
+		kind, prefix = TOKEN_PATH, "path:"
+	default:
+		return 0, "", 0
+	}
+	start := len([]rune(prefix))
+	if start < len(runes) && (runes[start] == '"' || runes[start] == '\'') {
+		value, n := consumeString(runes[start:])
+		if n == 0 || value == "" {
+			return 0, "", -1
+		}
+		return kind, value, start + n
+	}
+	end := start
+	for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("()&|", runes[end]) {
+		end++
+	}
+	if end == start {
+		return 0, "", -1
+	}
+	return kind, string(runes[start:end]), end
+}
+
+// 日付と数値. キーの一部であれば length = 0
+func consumeLiteral(runes []rune) (kind tokenKind, length int) {
+	s := string(runes)
+	if loc := filterDatePattern.FindStringIndex(s); loc != nil && consumeIdent(runes[loc[1]:]) == 0 {
+		return TOKEN_DATE, loc[1]
+	}
+	if loc := filterNumberPattern.FindStringIndex(s); loc != nil && consumeIdent(runes[loc[1]:]) == 0 {
+		return TOKEN_NUMBER, loc[1]
+	}
+	return 0, 0
+}
+
 func consumeIdent(runes []rune) (length int) {
 	for i := 0; i < len(runes); i++ {
 		char := runes[i]
@@ -318,20 +871,53 @@ const (
 type FilterErr struct {
 	kind    FilterErrorKind
 	message string
+	flag    string // 空文字列なら FLAG_FILTER
+	filter  string
+	pos     int // filter 内の位置 (rune 単位). 負なら位置を示さない
 }
 
 func newFilterErrf(kind FilterErrorKind, format string, a ...interface{}) *FilterErr {
 	err := new(FilterErr)
 	err.kind = kind
 	err.message = fmt.Sprintf(format, a...)
+	err.pos = -1
+	return err
+}
+
+// filter のどこで失敗したかを ↑ で示す
+func newFilterErrAt(kind FilterErrorKind, filter string, pos int, format string, a ...interface{}) *FilterErr {
+	err := newFilterErrf(kind, format, a...)
+	err.filter = filter
+	err.pos = pos
 	return err
 }
 
 func (err *FilterErr) Error() string {
-	return err.message
+	if err.pos < 0 {
+		return err.message
+	}
+	flag := err.flag
+	if flag == "" {
+		flag = FLAG_FILTER
+	}
+	head := fmt.Sprintf("-%s=", flag)
+	errHere := strings.Repeat(" ", len(head)+err.pos) + "↑"
+	return fmt.Sprintf("%s\n%s%s\n%s", err.message, head, err.filter, errHere)
+}
+
+// filter と同じ記法の別のフラグで起きたエラーとして表示する
+func filterErrFor(flag string, err error) error {
+	if e, ok := err.(*FilterErr); ok {
+		e.flag = flag
+	}
+	return err
+}
+
+// verifyConfig から mainErr として返せるようにする
+func (err *FilterErr) Kind() mainErrKind {
+	return MAIN_ERR_KIND_INVALID_FILTER_FORMAT
 }
 
-func newTokinizeErr(filter string, pos int) *FilterErr {
-	errHere := strings.Repeat(" ", pos) + "↑"
-	return newFilterErrf(FILTER_ERROR_TOKENIZE, "-filter=%s\n        %s", filter, errHere)
+func newTokinizeErr(filter string, pos int, format string, a ...interface{}) *FilterErr {
+	return newFilterErrAt(FILTER_ERROR_TOKENIZE, filter, pos, format, a...)
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/qawatake/obsdconv/convert"
+	"github.com/qawatake/obsdconv/process"
+)
+
+const (
+	SUBCOMMAND_GRAPH  = "graph"
+	FLAG_GRAPH_FORMAT = "format"
+)
+
+type graphConfiguration struct {
+	src    string
+	format string
+}
+
+func initGraphFlags(flagset *flag.FlagSet, config *graphConfiguration) {
+	flagset.StringVar(&config.src, FLAG_SOURCE, "", "source directory")
+	flagset.StringVar(&config.format, FLAG_GRAPH_FORMAT, process.GRAPH_FORMAT_JSON, fmt.Sprintf("output format. Available formats: %s", strings.Join(process.GRAPH_FORMATS, ", ")))
+}
+
+func verifyGraphConfig(config *graphConfiguration) error {
+	if config.src == "" {
+		return newMainErr(MAIN_ERR_KIND_SOURCE_NOT_SET)
+	}
+	if strings.HasPrefix(config.src, "-") {
+		return newMainErr(MAIN_ERR_KIND_INVALID_SOURCE_FORMAT)
+	}
+	for _, format := range process.GRAPH_FORMATS {
+		if config.format == format {
+			return nil
+		}
+	}
+	return newMainErr(MAIN_ERR_KIND_INVALID_GRAPH_FORMAT)
+}
+
+// obsdconv graph の本体
+// ノートをノード, リンクを辺とするグラフを out に書き出す
+func runGraph(config *graphConfiguration, out io.Writer) error {
+	if err := verifyGraphConfig(config); err != nil {
+		return err
+	}
+	skipper, err := process.NewSkipper(filepath.Join(config.src, DEFAULT_IGNORE_FILE_NAME))
+	if err != nil {
+		return err
+	}
+	db := process.WrapForSkipping(convert.NewPathDB(config.src), skipper)
+	graph, err := process.BuildGraph(config.src, skipper, db)
+	if err != nil {
+		return err
+	}
+	return process.WriteGraph(out, graph, config.format)
+}
@@ -6,7 +6,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/qawatake/obsdconv/convert"
 	"github.com/qawatake/obsdconv/process"
 )
 
@@ -16,17 +18,30 @@ var (
 
 const (
 	DEFAULT_IGNORE_FILE_NAME = ".obsdconvignore"
+	DEFAULT_CONFIG_FILE_NAME = "obsdconv.yaml"
+	WATCH_INTERVAL           = 500 * time.Millisecond
 )
 
 func main() {
+	// サブコマンド
+	if len(os.Args) > 1 && os.Args[1] == SUBCOMMAND_GRAPH {
+		config := new(graphConfiguration)
+		flagset := flag.NewFlagSet(SUBCOMMAND_GRAPH, flag.ExitOnError)
+		initGraphFlags(flagset, config)
+		flagset.Parse(os.Args[2:])
+		if err := runGraph(config, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// config を設定
 	config := new(configuration)
 	initFlags(flag.CommandLine, config)
 	flag.Parse()
-	setConfig(flag.CommandLine, config)
 
 	// main 部分
-	versionText, bufferredErrs, err := run(Version, config)
+	versionText, bufferredErrs, err := run(Version, flag.CommandLine, config)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -39,10 +54,16 @@ func main() {
 	}
 }
 
-func run(version string, config *configuration) (verionText string, bufferredErrs []error, err error) {
+// flagset は initFlags で config と結びつけて, 値を設定したもの
+func run(version string, flagset *flag.FlagSet, config *configuration) (verionText string, bufferredErrs []error, err error) {
 	if config.ver {
 		return fmt.Sprintf("v%s", version), nil, nil
 	}
+	fileflags, err := loadConfigFile(flagset, config)
+	if err != nil {
+		return "", nil, err
+	}
+	setConfig(flagset, config, fileflags)
 	if err := verifyConfig(config); err != nil {
 		return "", nil, err
 	}
@@ -50,12 +71,109 @@ func run(version string, config *configuration) (verionText string, bufferredErr
 	if err != nil {
 		return "", nil, err
 	}
-	processor, err := newDefaultProcessor(config)
+	if config.report == REPORT_LINKS {
+		problems, err := process.FindLinkProblems(config.src, config.tgt, skipper, process.WrapSearchableForSkipping(convert.NewSearchablePathDB(config.src), skipper))
+		if err != nil {
+			return "", nil, err
+		}
+		if err := process.WriteLinkReport(os.Stdout, problems, config.reportFormat); err != nil {
+			return "", nil, err
+		}
+		return "", nil, nil
+	}
+	if config.report == REPORT_ORPHANS {
+		db := process.WrapForSkipping(convert.NewPathDB(config.src), skipper)
+		examinator, err := newNoteExaminatorImpl(config.src, config.filter, config.publishable, config.pubRule)
+		if err != nil {
+			return "", nil, err
+		}
+		attachments, err := process.BuildAttachmentIndex(config.src, config.tgt, skipper, examinator, db, config.transclude)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := process.WriteOrphanReport(os.Stdout, attachments.Orphans(), config.reportFormat); err != nil {
+			return "", nil, err
+		}
+		return "", nil, nil
+	}
+	vaultdb := convert.NewMutablePathDB(config.src)
+	if config.dryrun {
+		// dry run では manifest も読み書きしない
+		writer := process.NewDiffWriter()
+		processor, err := newDefaultProcessor(config, vaultdb, nil, writer)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := process.WalkDryRun(config.tgt, config.dst, skipper, processor); err != nil {
+			return "", nil, err
+		}
+		if err := writer.Flush(os.Stdout); err != nil {
+			return "", nil, err
+		}
+		return "", processor.errbuf, nil
+	}
+	var manifest *process.Manifest
+	if config.incr {
+		manifest, err = process.LoadManifest(config.dst, optionsHash(version, config))
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	processor, err := newDefaultProcessor(config, vaultdb, manifest, process.NewFileWriter())
 	if err != nil {
 		return "", nil, err
 	}
-	if err := process.Walk(config.tgt, config.dst, skipper, processor); err != nil {
+	walk := process.Walk
+	if config.remapOutput || config.slug {
+		// src と同じ構造の空のディレクトリを作らない
+		walk = process.WalkWithoutMkdir
+	}
+	if err := walk(config.tgt, config.dst, skipper, processor); err != nil {
 		return "", nil, err
 	}
+	if manifest != nil {
+		if err := manifest.RemoveVanished(config.dst); err != nil {
+			return "", nil, err
+		}
+		if err := manifest.Save(); err != nil {
+			return "", nil, err
+		}
+	}
+	if config.watch {
+		watcher, err := process.NewWatcher(config.src, config.tgt, config.dst, skipper, processor, vaultdb)
+		if err != nil {
+			return "", nil, err
+		}
+		watch(watcher, processor, manifest)
+	}
 	return "", processor.errbuf, nil
 }
+
+// 変更を監視し続ける
+// 変換中のエラーは出力するだけで, 監視は止めない
+func watch(watcher *process.Watcher, processor *processorImplWithErrHandling, manifest *process.Manifest) {
+	flushErrs := func() {
+		for _, err := range processor.errbuf {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		processor.errbuf = nil
+	}
+	flushErrs()
+	fmt.Fprintln(os.Stderr, "watching for changes...")
+	for {
+		time.Sleep(WATCH_INTERVAL)
+		processed, err := watcher.Poll()
+		for _, path := range processed {
+			fmt.Fprintf(os.Stderr, "converted: %s\n", path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		flushErrs()
+		if manifest != nil && len(processed) > 0 {
+			if err := manifest.Save(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+}
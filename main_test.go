@@ -13,6 +13,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/qawatake/obsdconv/convert"
+	"github.com/qawatake/obsdconv/process"
 )
 
 func TestRun(t *testing.T) {
@@ -194,14 +195,54 @@ func TestRun(t *testing.T) {
 			},
 			wantDstDir: filepath.Join(testdataDir, "obs_filter", dst),
 		},
+		{
+			name: "-filter tag: path:",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:      filepath.Join(testdataDir, "filter_tag_path", src),
+				FLAG_DESTINATION: filepath.Join(testdataDir, "filter_tag_path", tmp),
+				FLAG_FILTER:      "tag:public || path:projects/** && draft != true",
+			},
+			wantDstDir: filepath.Join(testdataDir, "filter_tag_path", dst),
+		},
+		{
+			// 既定の pubMarker でも pubRule を満たしたノートは draft: false になる
+			name: "-pub -pubRule",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:      filepath.Join(testdataDir, "pub_rule", src),
+				FLAG_DESTINATION: filepath.Join(testdataDir, "pub_rule", tmp),
+				FLAG_PUBLISHABLE: "1",
+				FLAG_PUB_RULE:    "status == 'published' && visibility == 'public' && (!has(publishDate) || publishDate <= now)",
+			},
+			wantDstDir: filepath.Join(testdataDir, "pub_rule", dst),
+		},
+		{
+			name: "-pub -pubRule -pubMarker",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:      filepath.Join(testdataDir, "pub_rule", src),
+				FLAG_DESTINATION: filepath.Join(testdataDir, "pub_rule", tmp),
+				FLAG_PUBLISHABLE: "1",
+				FLAG_PUB_RULE:    "status == 'published' && visibility == 'public' && (!has(publishDate) || publishDate <= now)",
+				FLAG_PUB_MARKER:  "draft=false",
+			},
+			wantDstDir: filepath.Join(testdataDir, "pub_rule", dst),
+		},
+		{
+			name: "-yamlRules",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:      filepath.Join(testdataDir, "yaml_rules", src),
+				FLAG_DESTINATION: filepath.Join(testdataDir, "yaml_rules", tmp),
+				FLAG_YAML_RULES:  filepath.Join(testdataDir, "yaml_rules", "rules.yaml"),
+			},
+			wantDstDir: filepath.Join(testdataDir, "yaml_rules", dst),
+		},
 		{
 			name: "-std -baseUrl",
 			cmdflags: map[string]string{
-				FLAG_SOURCE:            filepath.Join(testdataDir, "std_baseUrl", src),
-				FLAG_DESTINATION:       filepath.Join(testdataDir, "std_baseUrl", tmp),
-				FLAG_STANDARD_USAGE:    "1",
-				FLAG_FORMAT_LINK:       "1",
-				FLAG_REMAP_PATH_PREFIX: ">https://example.com/",
+				FLAG_SOURCE:         filepath.Join(testdataDir, "std_baseUrl", src),
+				FLAG_DESTINATION:    filepath.Join(testdataDir, "std_baseUrl", tmp),
+				FLAG_STANDARD_USAGE: "1",
+				FLAG_FORMAT_LINK:    "1",
+				FLAG_BASE_URL:       "https://example.com/",
 			},
 			wantDstDir: filepath.Join(testdataDir, "std_baseUrl", dst),
 		},
@@ -227,6 +268,39 @@ func TestRun(t *testing.T) {
 			},
 			wantDstDir: filepath.Join(testdataDir, "tgt_remapPathPrefix", dst),
 		},
+		{
+			name: "-link -remapPathPrefix -remapOutput",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:            filepath.Join(testdataDir, "remapOutput", src),
+				FLAG_DESTINATION:       filepath.Join(testdataDir, "remapOutput", tmp),
+				FLAG_CONVERT_LINKS:     "1",
+				FLAG_REMAP_PATH_PREFIX: "notes/>content/posts/|static/>/",
+				FLAG_REMAP_OUTPUT:      "1",
+			},
+			wantDstDir: filepath.Join(testdataDir, "remapOutput", dst),
+		},
+		{
+			name: "-link -slug",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "slug", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "slug", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_SLUG:          "1",
+			},
+			wantDstDir: filepath.Join(testdataDir, "slug", dst),
+		},
+		{
+			name: "-link -formatLink -baseUrl -permalink",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "permalink", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "permalink", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_FORMAT_LINK:   "1",
+				FLAG_BASE_URL:      "https://example.com/",
+				FLAG_PERMALINK:     "posts/>/posts/:year/:slug/",
+			},
+			wantDstDir: filepath.Join(testdataDir, "permalink", dst),
+		},
 		{
 			name: fmt.Sprintf("-link -formatAnchor=%s", convert.FORMAT_ANCHOR_HUGO),
 			cmdflags: map[string]string{
@@ -246,6 +320,136 @@ func TestRun(t *testing.T) {
 			},
 			wantDstDir: filepath.Join(testdataDir, "formatAnchorMarkdownIt", dst),
 		},
+		{
+			name: fmt.Sprintf("-link -formatLink -backlinks=%s", BACKLINKS_YAML),
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "backlinks_yaml", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "backlinks_yaml", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_FORMAT_LINK:   "1",
+				FLAG_BACKLINKS:     BACKLINKS_YAML,
+			},
+			wantDstDir: filepath.Join(testdataDir, "backlinks_yaml", dst),
+		},
+		{
+			name: fmt.Sprintf("-link -formatLink -backlinks=%s", BACKLINKS_SECTION),
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "backlinks_section", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "backlinks_section", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_FORMAT_LINK:   "1",
+				FLAG_BACKLINKS:     BACKLINKS_SECTION,
+			},
+			wantDstDir: filepath.Join(testdataDir, "backlinks_section", dst),
+		},
+		{
+			name: fmt.Sprintf("-link -linkStyle=relref -backlinks=%s", BACKLINKS_SECTION),
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "backlinks_section_linkStyle", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "backlinks_section_linkStyle", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_LINK_STYLE:    convert.LINK_STYLE_RELREF,
+				FLAG_BACKLINKS:     BACKLINKS_SECTION,
+			},
+			wantDstDir: filepath.Join(testdataDir, "backlinks_section_linkStyle", dst),
+		},
+		{
+			name: "-link -transclude -transcludeDepth=2",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:           filepath.Join(testdataDir, "link_transclude", src),
+				FLAG_DESTINATION:      filepath.Join(testdataDir, "link_transclude", tmp),
+				FLAG_CONVERT_LINKS:    "1",
+				FLAG_TRANSCLUDE:       "1",
+				FLAG_TRANSCLUDE_DEPTH: "2",
+			},
+			wantDstDir: filepath.Join(testdataDir, "link_transclude", dst),
+		},
+		{
+			name: "-link -transclude -pub -attachments=referenced",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "link_transclude_pub", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "link_transclude_pub", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_TRANSCLUDE:    "1",
+				FLAG_PUBLISHABLE:   "1",
+				FLAG_ATTACHMENTS:   ATTACHMENTS_REFERENCED,
+			},
+			wantDstDir: filepath.Join(testdataDir, "link_transclude_pub", dst),
+		},
+		{
+			name: "-link -blockAnchor",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "link_blockref", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "link_blockref", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_BLOCK_ANCHOR:  `<span id="{id}"></span>`,
+			},
+			wantDstDir: filepath.Join(testdataDir, "link_blockref", dst),
+		},
+		{
+			name: "-link -callout=details",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "callout_details", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "callout_details", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_CALLOUT:       convert.CALLOUT_STYLE_DETAILS,
+			},
+			wantDstDir: filepath.Join(testdataDir, "callout_details", dst),
+		},
+		{
+			name: "-highlight",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:      filepath.Join(testdataDir, "highlight", src),
+				FLAG_DESTINATION: filepath.Join(testdataDir, "highlight", tmp),
+				FLAG_HIGHLIGHT:   "1",
+			},
+			wantDstDir: filepath.Join(testdataDir, "highlight", dst),
+		},
+		{
+			name: "-link -imageSize=hugo",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "link_imageSize", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "link_imageSize", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_IMAGE_SIZE:    convert.IMAGE_SIZE_STYLE_HUGO,
+			},
+			wantDstDir: filepath.Join(testdataDir, "link_imageSize", dst),
+		},
+		{
+			name: "-link -pub -attachments=referenced",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:        filepath.Join(testdataDir, "attachments_referenced", src),
+				FLAG_DESTINATION:   filepath.Join(testdataDir, "attachments_referenced", tmp),
+				FLAG_CONVERT_LINKS: "1",
+				FLAG_PUBLISHABLE:   "1",
+				FLAG_ATTACHMENTS:   ATTACHMENTS_REFERENCED,
+			},
+			wantDstDir: filepath.Join(testdataDir, "attachments_referenced", dst),
+		},
+		{
+			name: "-link -attachmentDir -attachmentName=hash",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:          filepath.Join(testdataDir, "attachments_relocate", src),
+				FLAG_DESTINATION:     filepath.Join(testdataDir, "attachments_relocate", tmp),
+				FLAG_CONVERT_LINKS:   "1",
+				FLAG_ATTACHMENT_DIR:  "static/attachments",
+				FLAG_ATTACHMENT_NAME: process.ATTACHMENT_NAME_HASH,
+			},
+			wantDstDir: filepath.Join(testdataDir, "attachments_relocate", dst),
+		},
+		{
+			// 内容が同じ a.png と b.png のうち, 参照されている b.png をコピーする
+			name: "-link -attachments=referenced -attachmentDir -attachmentName=hash",
+			cmdflags: map[string]string{
+				FLAG_SOURCE:          filepath.Join(testdataDir, "attachments_referenced_relocate", src),
+				FLAG_DESTINATION:     filepath.Join(testdataDir, "attachments_referenced_relocate", tmp),
+				FLAG_CONVERT_LINKS:   "1",
+				FLAG_ATTACHMENTS:     ATTACHMENTS_REFERENCED,
+				FLAG_ATTACHMENT_DIR:  "static",
+				FLAG_ATTACHMENT_NAME: process.ATTACHMENT_NAME_HASH,
+			},
+			wantDstDir: filepath.Join(testdataDir, "attachments_referenced_relocate", dst),
+		},
 	}
 
 	for _, tt := range cases {
@@ -256,9 +460,8 @@ func TestRun(t *testing.T) {
 		for cmdname, cmdvalue := range tt.cmdflags { // flag.Parse() に相当
 			flagset.Set(cmdname, cmdvalue)
 		}
-		setConfig(flagset, config)
 
-		gotVersionText, gotBufferredErrs, err := run(tt.version, config)
+		gotVersionText, gotBufferredErrs, err := run(tt.version, flagset, config)
 		if err != nil {
 			t.Fatalf("[FATAL | %s] unexpected err occurred: %v", tt.name, err)
 		}
@@ -433,9 +636,113 @@ func compareDirContent(dir1, dir2 string) (msg string, err error) {
 	return "", nil
 }
 
+func TestRunWithBrokenConfigFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), DEFAULT_CONFIG_FILE_NAME)
+	if err := os.WriteFile(configPath, []byte("unknown: true\n"), 0o666); err != nil {
+		t.Fatalf("[FATAL] failed to write %s: %v", configPath, err)
+	}
+	cases := []struct {
+		name            string
+		cmdflags        map[string]string
+		wantVersionText string
+		wantErr         mainErr
+	}{
+		{
+			name: "version",
+			cmdflags: map[string]string{
+				FLAG_CONFIG:  configPath,
+				FLAG_VERSION: "1",
+			},
+			wantVersionText: "v1.0.0",
+		},
+		{
+			name: "conversion",
+			cmdflags: map[string]string{
+				FLAG_CONFIG:      configPath,
+				FLAG_SOURCE:      "src",
+				FLAG_DESTINATION: "dst",
+			},
+			wantErr: newMainErr(MAIN_ERR_KIND_INVALID_CONFIG_FILE),
+		},
+	}
+
+	for _, tt := range cases {
+		config := new(configuration)
+		flagset := flag.NewFlagSet(fmt.Sprintf("TestRunWithBrokenConfigFile | %s", tt.name), flag.ExitOnError)
+		initFlags(flagset, config)
+		for cmdname, cmdvalue := range tt.cmdflags {
+			flagset.Set(cmdname, cmdvalue)
+		}
+		gotVersionText, _, err := run("1.0.0", flagset, config)
+		if tt.wantErr != nil {
+			if e, ok := err.(mainErr); !ok || e.Kind() != tt.wantErr.Kind() {
+				t.Errorf("[ERROR | %s] got: %v, want: %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[FATAL | %s] unexpected err occurred: %v", tt.name, err)
+		}
+		if gotVersionText != tt.wantVersionText {
+			t.Errorf("[ERROR | %s] got: %q, want: %q", tt.name, gotVersionText, tt.wantVersionText)
+		}
+	}
+}
+
+func TestCheckFilterErr(t *testing.T) {
+	cases := []struct {
+		name     string
+		fm       map[interface{}]interface{}
+		filter   string
+		wantKind FilterErrorKind
+		wantPos  int
+	}{
+		{name: "unexpected character", filter: "key1 && key2@", wantKind: FILTER_ERROR_TOKENIZE, wantPos: 12},
+		{name: "unterminated string", filter: `status == "done`, wantKind: FILTER_ERROR_TOKENIZE, wantPos: 10},
+		{name: "missing right operand", filter: "key1 &&", wantKind: FILTER_ERROR_PARSE, wantPos: 7},
+		{name: "missing closing parenthesis", filter: "(key1 || key2", wantKind: FILTER_ERROR_PARSE, wantPos: 13},
+		{name: "chained comparison", filter: "a == b == c", wantKind: FILTER_ERROR_PARSE, wantPos: 7},
+		{name: "invalid regular expression", filter: `title =~ "("`, wantKind: FILTER_ERROR_PARSE, wantPos: 9},
+		{name: "has without key", filter: `has("key")`, wantKind: FILTER_ERROR_PARSE, wantPos: 4},
+		{name: "empty tag", filter: "tag: && key", wantKind: FILTER_ERROR_TOKENIZE, wantPos: 0},
+		{name: "invalid path pattern", filter: "key && path:[a", wantKind: FILTER_ERROR_PARSE, wantPos: 7},
+		{
+			name:     "not boolean",
+			fm:       map[interface{}]interface{}{"status": "done"},
+			filter:   "draft || status",
+			wantKind: FILTER_ERROR_EVALUATE,
+			wantPos:  9,
+		},
+		{
+			name:     "different types",
+			fm:       map[interface{}]interface{}{"priority": "high"},
+			filter:   "priority > 2",
+			wantKind: FILTER_ERROR_EVALUATE,
+			wantPos:  9,
+		},
+	}
+
+	for _, tt := range cases {
+		_, err := checkFilter(tt.fm, "", nil, tt.filter)
+		if err == nil {
+			t.Errorf("[ERROR | %s] expected error did not occur", tt.name)
+			continue
+		}
+		e, ok := err.(*FilterErr)
+		if !ok {
+			t.Fatalf("[FATAL | %s] unexpected error occurred: %v", tt.name, err)
+		}
+		if e.kind != tt.wantKind || e.pos != tt.wantPos {
+			t.Errorf("[ERROR | %s] got kind: %d, pos: %d, want kind: %d, pos: %d\n%v", tt.name, e.kind, e.pos, tt.wantKind, tt.wantPos, err)
+		}
+	}
+}
+
 func TestCheckFilter(t *testing.T) {
 	cases := []struct {
 		fm     map[interface{}]interface{}
+		rpath  string
+		tags   map[string]struct{}
 		filter string
 		want   bool
 	}{
@@ -510,10 +817,154 @@ func TestCheckFilter(t *testing.T) {
 			filter: "",
 			want:   true,
 		},
+		{
+			fm: map[interface{}]interface{}{
+				"status": "done",
+			},
+			filter: `status == "done"`,
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"status": "wip",
+			},
+			filter: `status != 'done' && !(status == "draft")`,
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"priority": 3,
+			},
+			filter: "priority > 2 && priority <= 3.5",
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"priority": -1,
+			},
+			filter: "priority >= 0",
+			want:   false,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"date": "2024-03-01",
+			},
+			filter: "date >= 2024-01-01",
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"date": "2023-12-31T23:00:00+09:00",
+			},
+			filter: "date >= 2024-01-01",
+			want:   false,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"tags": []interface{}{"blog", "go"},
+			},
+			filter: `"blog" in tags`,
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"tags": []interface{}{"diary"},
+			},
+			filter: `"blog" in tags`,
+			want:   false,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"title": "Release notes for v1.2",
+			},
+			filter: `"notes" in title && title =~ "v[0-9]+\\.[0-9]+" && title !~ "^Draft"`,
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"series": "tutorial",
+			},
+			filter: "has(series) && !has(deprecated)",
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"draft": false,
+			},
+			filter: "draft == false && missing != 1 && !(missing > 1)",
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"key1": true,
+			},
+			filter: " key1 &&\t( key1 || key2 ) ",
+			want:   true,
+		},
+		{
+			tags:   map[string]struct{}{"public": {}},
+			filter: "tag:public",
+			want:   true,
+		},
+		{
+			tags:   map[string]struct{}{"public/blog": {}},
+			filter: "tag:#public && !tag:blog",
+			want:   true,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"tags": []interface{}{"public"},
+			},
+			filter: "tag:public",
+			want:   true,
+		},
+		{
+			tags:   map[string]struct{}{"publication": {}},
+			filter: "tag:public",
+			want:   false,
+		},
+		{
+			fm: map[interface{}]interface{}{
+				"draft": true,
+			},
+			rpath:  "projects/app/design.md",
+			filter: "path:projects/** && !draft",
+			want:   false,
+		},
+		{
+			rpath:  "projects/app/design.md",
+			filter: "path:projects/**&&path:**/*.md",
+			want:   true,
+		},
+		{
+			rpath:  "projects/design.md",
+			filter: "path:projects/*/*.md",
+			want:   false,
+		},
+		{
+			rpath:  "my notes/a.md",
+			filter: `path:"my notes/**" || path:archive/**`,
+			want:   true,
+		},
+		{
+			rpath:  "projects/app/design.md",
+			filter: "path:projects/",
+			want:   true,
+		},
+		{
+			rpath:  "projects.md",
+			filter: "path:projects/",
+			want:   false,
+		},
+		{
+			rpath:  "archive/projects/a.md",
+			filter: "path:projects/",
+			want:   false,
+		},
 	}
 
 	for _, tt := range cases {
-		got, err := checkFilter(tt.fm, tt.filter)
+		got, err := checkFilter(tt.fm, tt.rpath, tt.tags, tt.filter)
 		if err != nil {
 			t.Fatalf("[FATAL] unexpected error occurred\n%v\nfm: %v\nfilter: %s", err, tt.fm, tt.filter)
 		}
@@ -45,5 +45,5 @@ func (passer *argPasserImpl) PassArg(frombody process.BodyConvAuxOut) (toyaml pr
 		return strings.Compare(newtags[i], newtags[j]) <= 0
 	})
 
-	return newYamlConvAuxInImpl(title, alias, newtags), nil
+	return newYamlConvAuxInImpl(title, alias, newtags, args.backlinks, args.body), nil
 }
@@ -45,12 +45,58 @@ func (p *processorImplWithErrHandling) Process(relativePath, orgpath, newpath st
 	}
 }
 
-func newDefaultProcessor(config *configuration) (processor *processorImplWithErrHandling, err error) {
+// vaultdb は config.src を vault とする PathDB
+// manifest が nil でなければ, 変更のないファイルの処理を省く
+func newDefaultProcessor(config *configuration, vaultdb convert.PathDB, manifest *process.Manifest, writer process.FileWriter) (processor *processorImplWithErrHandling, err error) {
 	skipper, err := process.NewSkipper(filepath.Join(config.src, DEFAULT_IGNORE_FILE_NAME))
 	if err != nil {
 		return nil, err
 	}
-	db := process.WrapForSkipping(convert.NewPathDB(config.src), skipper)
+	basedb := process.WrapForSkipping(vaultdb, skipper)
+	// permalink や添付ファイルの移動先を反映した PathDB
+	// backlinks と参照されている添付ファイルの判定には, 元のパスを返す basedb を使う
+	linkdb := basedb
+	examinator, err := newNoteExaminatorImpl(config.src, config.filter, config.publishable, config.pubRule)
+	if err != nil {
+		return nil, err
+	}
+	var permalinks *process.PermalinkIndex
+	if usesPermalinks(config) {
+		patterns, err := parsePermalinks(config.permalink)
+		if err != nil {
+			return nil, err
+		}
+		permalinks, err = process.BuildPermalinkIndex(config.src, skipper, examinator, patterns)
+		if err != nil {
+			return nil, err
+		}
+		linkdb = process.WrapForUsingPermalinks(linkdb, permalinks)
+	}
+	// 参照されている添付ファイルの判定には basedb を使うので, 移動先より先に決められる
+	var attachments *process.AttachmentIndex
+	if config.attachments == ATTACHMENTS_REFERENCED {
+		attachments, err = process.BuildAttachmentIndex(config.src, config.tgt, skipper, examinator, basedb, config.transclude)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var relocator *process.AttachmentRelocator
+	if relocatesAttachments(config) {
+		relocator, err = process.BuildAttachmentRelocator(config.src, config.tgt, skipper, config.attachmentDir, config.attachmentName, attachments)
+		if err != nil {
+			return nil, err
+		}
+		linkdb = process.WrapForRelocatingAttachments(linkdb, relocator)
+	}
+	var slugs *process.SlugIndex
+	if config.slug {
+		slugs, err = process.BuildSlugIndex(config.src, config.tgt, skipper)
+		if err != nil {
+			return nil, err
+		}
+		linkdb = process.WrapForSluggingPaths(linkdb, slugs)
+	}
+	db := linkdb
 
 	if config.strictref {
 		db = convert.WrapForReturningNotFoundPathError(db)
@@ -60,15 +106,76 @@ func newDefaultProcessor(config *configuration) (processor *processorImplWithErr
 	if err != nil {
 		return nil, err
 	}
-	bc := newBodyConverterImpl(db, config.cptag || config.synctag, config.rmtag, config.cmmt, config.title || config.alias || config.synctlal, config.link, config.rmH1, config.formatLink, config.formatAnchor, pathPrefixRemap)
+	var backlinks *process.BacklinkIndex
+	if config.backlinks != "" {
+		backlinks, err = process.BuildBacklinkIndex(config.src, config.tgt, skipper, examinator, basedb)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var transclusion *transclusionConfig
+	if config.transclude {
+		transclusion = newTransclusionConfig(config.src, config.tgt, config.transcludeDepth, basedb, examinator)
+	}
+	var linkRenderer convert.LinkRenderer
+	if config.linkTmpl != "" {
+		linkRenderer, err = convert.NewTemplateLinkRenderer(config.linkTmpl)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var highlightTmpl string
+	if config.highlight {
+		highlightTmpl = config.highlightTmpl
+	}
+	bc := newBodyConverterImpl(db, config.cptag || config.synctag, config.rmtag, config.cmmt, config.title || config.alias || config.synctlal, config.link, config.rmH1, config.formatLink, config.formatAnchor, config.linkStyle, linkRenderer, config.imageSize, config.embedFallback, pathPrefixRemap, config.baseUrl, backlinks, config.backlinks, transclusion, config.blockAnchor, config.callout, highlightTmpl)
 	metaKeyRemap, err := parseRemap(config.remapkey)
 	if err != nil {
 		return nil, err
 	}
-	yc := newYamlConverterImpl(config.synctag, config.synctlal, config.publishable, metaKeyRemap)
+	var pubMarker *publishMarker
+	if config.publishable {
+		pubMarker, err = parsePubMarker(config.pubMarker)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var rules yamlRules
+	if config.yamlRules != "" {
+		rules, err = loadYamlRules(config.yamlRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+	yc := newYamlConverterImpl(config.synctag, config.synctlal, pubMarker, metaKeyRemap, rules)
 	passer := newArgPasserImpl(config.title || config.synctlal, config.alias || config.synctlal)
-	examinator := newYamlExaminatorImpl(config.filter, config.publishable)
-	return newProcessorImplWithErrHandling(config.debug, process.NewProcessor(bc, yc, passer, examinator)), nil
+	sub := process.NewProcessorWithFileWriter(bc, yc, passer, examinator, writer)
+	if manifest != nil {
+		sub = process.WrapForIncremental(sub, linkdb, manifest, examinator)
+	}
+	// 出力先の先頭を置き換える場合は, slug にしたパスを置き換える
+	if config.remapOutput {
+		sub = process.WrapForRemappingOutputs(sub, config.src, config.tgt, config.dst, pathPrefixRemap, relocator, slugs)
+	} else {
+		if slugs != nil {
+			sub = process.WrapForSluggingOutputs(sub, slugs, config.dst)
+		}
+		if relocator != nil {
+			sub = process.WrapForMovingAttachments(sub, relocator, config.dst)
+		}
+	}
+	// 参照されていないファイルは -incr の manifest にも記録させず, 前回の出力を削除させる
+	if attachments != nil {
+		sub = process.WrapForReferencedAttachments(sub, attachments)
+	}
+	processor = newProcessorImplWithErrHandling(config.debug, sub)
+	// permalink を決められなかったノートは, 元のパスでリンクしたうえでエラー出力だけする
+	if permalinks != nil {
+		for _, err := range permalinks.Problems() {
+			processor.errbuf = append(processor.errbuf, fmt.Errorf("[ERROR] %v", err))
+		}
+	}
+	return processor, nil
 }
 
 func handleErr(path string, err error) (public error, debug error, buffered error) {
@@ -0,0 +1,193 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+)
+
+// tgt 内の添付ファイル (.md 以外のファイル) のうち, ノートから参照されているもの
+type AttachmentIndex struct {
+	vault       string
+	tgt         string
+	referenced  map[string]struct{} // vault からの相対パス
+	attachments []string            // tgt 内の添付ファイルの vault からの相対パス
+}
+
+// tgt 内のノートのリンクと埋め込みを db で解決して, 参照されている添付ファイルを集める
+// examinator で処理対象外と判定されたノートからの参照は含めない
+// transclude が true なら, 埋め込まれたノートから参照されている添付ファイルも含める
+// 埋め込まれたノートも examinator で処理対象外と判定されれば展開されないので含めない
+func BuildAttachmentIndex(vault, tgt string, skipper Skipper, examinator NoteExaminator, db convert.PathDB, transclude bool) (*AttachmentIndex, error) {
+	index := &AttachmentIndex{
+		vault:      vault,
+		tgt:        tgt,
+		referenced: make(map[string]struct{}),
+	}
+	visited := make(map[string]struct{})
+	err := filepath.Walk(tgt, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rpath, err := filepath.Rel(tgt, path)
+		if err != nil {
+			return err
+		}
+		if skipper.Skip(rpath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		vpath, err := filepath.Rel(vault, path)
+		if err != nil {
+			return err
+		}
+		vpath = filepath.ToSlash(vpath)
+		if filepath.Ext(path) != ".md" {
+			index.attachments = append(index.attachments, vpath)
+			return nil
+		}
+		if err := index.add(vpath, path, examinator, db, transclude, visited); err != nil {
+			return errors.Wrapf(err, "failed to collect attachments referenced in %s", vpath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(index.attachments)
+	return index, nil
+}
+
+// examinator が nil なら処理対象かどうかを確認しない
+func (index *AttachmentIndex) add(vpath, path string, examinator NoteExaminator, db convert.PathDB, transclude bool, visited map[string]struct{}) error {
+	if _, ok := visited[vpath]; ok {
+		return nil
+	}
+	visited[vpath] = struct{}{}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Errorf("failed to open %s", path)
+	}
+	yml, body := splitMarkdown([]rune(string(content)))
+	if examinator != nil {
+		note, err := NewNoteInfo(path, body)
+		if err != nil {
+			return err
+		}
+		if ok, err := examinator.ExamineNote(yml, note); err != nil {
+			return errors.Wrap(err, "failed to examine note")
+		} else if !ok {
+			return nil
+		}
+	}
+
+	var links []convert.Link
+	if _, err := convert.NewLinkFinder(&links).Convert(body); err != nil {
+		return errors.Wrap(err, "LinkFinder failed")
+	}
+	for _, link := range links {
+		if link.FileId == "" {
+			continue
+		}
+		target, err := db.Get(link.FileId)
+		if err != nil {
+			return errors.Wrap(err, "PathDB.Get failed")
+		}
+		if target == "" {
+			continue
+		}
+		if filepath.Ext(target) != ".md" {
+			index.referenced[target] = struct{}{}
+			continue
+		}
+		// 埋め込まれたノートは処理対象のときだけ本文が展開される
+		if transclude && link.Kind == convert.LINK_KIND_EMBEDS {
+			if err := index.add(target, filepath.Join(index.vault, filepath.FromSlash(target)), examinator, db, transclude, visited); err != nil {
+				return errors.Wrapf(err, "failed to collect attachments referenced in %s", target)
+			}
+		}
+	}
+	return nil
+}
+
+// relativePath は Processor に渡されるのと同じ tgt からの相対パス
+func (index *AttachmentIndex) Referenced(relativePath string) (bool, error) {
+	rpath, err := filepath.Rel(index.vault, filepath.Join(index.tgt, relativePath))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get the path of %s relative to %s", relativePath, index.vault)
+	}
+	_, ok := index.referenced[filepath.ToSlash(rpath)]
+	return ok, nil
+}
+
+// どのノートからも参照されていない tgt 内の添付ファイルの vault からの相対パス
+func (index *AttachmentIndex) Orphans() []string {
+	orphans := make([]string, 0)
+	for _, path := range index.attachments {
+		if _, ok := index.referenced[path]; !ok {
+			orphans = append(orphans, path)
+		}
+	}
+	return orphans
+}
+
+type processorImplForReferencedAttachments struct {
+	sub   Processor
+	index *AttachmentIndex
+}
+
+// 参照されていない添付ファイルをコピーしない Processor を返す
+func WrapForReferencedAttachments(sub Processor, index *AttachmentIndex) Processor {
+	return &processorImplForReferencedAttachments{
+		sub:   sub,
+		index: index,
+	}
+}
+
+func (p *processorImplForReferencedAttachments) Process(relativePath, orgpath, newpath string) error {
+	if filepath.Ext(orgpath) == ".md" || filepath.ToSlash(relativePath) == MANIFEST_FILE_NAME {
+		return p.sub.Process(relativePath, orgpath, newpath)
+	}
+	ok, err := p.index.Referenced(relativePath)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return p.sub.Process(relativePath, orgpath, newpath)
+}
+
+func WriteOrphanReport(out io.Writer, orphans []string, format string) error {
+	switch format {
+	case REPORT_FORMAT_JSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(orphans); err != nil {
+			return errors.Wrap(err, "failed to encode orphan report")
+		}
+		return nil
+	case REPORT_FORMAT_TEXT:
+		for _, path := range orphans {
+			if _, err := fmt.Fprintln(out, path); err != nil {
+				return errors.Wrap(err, "failed to write orphan report")
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown report format: %s", format)
+	}
+}
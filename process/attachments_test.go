@@ -0,0 +1,93 @@
+package process
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qawatake/obsdconv/convert"
+)
+
+// front matter に private を含むノートを処理対象外にする
+type privateExaminator struct{}
+
+func (privateExaminator) ExamineNote(yml []byte, note *NoteInfo) (bool, error) {
+	return !strings.Contains(string(yml), "private"), nil
+}
+
+func TestBuildAttachmentIndex(t *testing.T) {
+	vault := t.TempDir()
+	for _, dir := range []string{"img", "notes"} {
+		if err := os.Mkdir(filepath.Join(vault, dir), 0o777); err != nil {
+			t.Fatalf("[FATAL] failed to create directory: %v", err)
+		}
+	}
+	files := map[string]string{
+		"notes/note.md":       "![[used.png]] [doc](doc.pdf) ![[embedded]]\n`![[in code.png]]`\n",
+		"notes/embedded.md":   "---\nprivate: true\n---\n![[img/transcluded.png]]\n",
+		"notes/private.md":    "---\nprivate: true\n---\n![[secret.png]]\n",
+		"notes/used.png":      "",
+		"notes/doc.pdf":       "",
+		"notes/in code.png":   "",
+		"notes/secret.png":    "",
+		"img/transcluded.png": "",
+		"img/orphan.png":      "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(vault, name), []byte(content), 0o666); err != nil {
+			t.Fatalf("[FATAL] failed to write: %v", err)
+		}
+	}
+	skipper, err := NewSkipper(filepath.Join(vault, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+
+	cases := []struct {
+		name           string
+		transclude     bool
+		wantReferenced []string
+		wantOrphans    []string
+	}{
+		{
+			name:           "without transclusion",
+			wantReferenced: []string{"notes/used.png", "notes/doc.pdf"},
+			wantOrphans:    []string{"img/orphan.png", "img/transcluded.png", "notes/in code.png", "notes/secret.png"},
+		},
+		{
+			name:           "with transclusion of a private note",
+			transclude:     true,
+			wantReferenced: []string{"notes/used.png", "notes/doc.pdf"},
+			wantOrphans:    []string{"img/orphan.png", "img/transcluded.png", "notes/in code.png", "notes/secret.png"},
+		},
+	}
+
+	for _, tt := range cases {
+		index, err := BuildAttachmentIndex(vault, vault, skipper, privateExaminator{}, convert.NewPathDB(vault), tt.transclude)
+		if err != nil {
+			t.Fatalf("[FATAL | %s] BuildAttachmentIndex failed: %v", tt.name, err)
+		}
+		for _, path := range tt.wantReferenced {
+			if ok, err := index.Referenced(path); err != nil {
+				t.Fatalf("[FATAL | %s] Referenced failed: %v", tt.name, err)
+			} else if !ok {
+				t.Errorf("[ERROR | %s] %s should be referenced", tt.name, path)
+			}
+		}
+		if got := index.Orphans(); !reflect.DeepEqual(got, tt.wantOrphans) {
+			t.Errorf("[ERROR | %s] got: %v, want: %v", tt.name, got, tt.wantOrphans)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteOrphanReport(buf, []string{"img/orphan.png", "notes/secret.png"}, REPORT_FORMAT_TEXT); err != nil {
+		t.Fatalf("[FATAL] WriteOrphanReport failed: %v", err)
+	}
+	wantText := "img/orphan.png\nnotes/secret.png\n"
+	if buf.String() != wantText {
+		t.Errorf("[ERROR] got: %q, want: %q", buf.String(), wantText)
+	}
+}
@@ -0,0 +1,155 @@
+package process
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+	"gopkg.in/yaml.v2"
+)
+
+// ノートへのリンク元
+type Backlink struct {
+	Path  string // vault からの相対パス
+	Title string
+}
+
+// vault 内のリンクの逆引き
+type BacklinkIndex struct {
+	vault     string
+	tgt       string
+	backlinks map[string][]Backlink // key: リンク先の vault からの相対パス
+}
+
+// vault 内のすべてのノートのリンクを集めて逆引きを作る
+// examinator で処理対象外と判定されたノートはリンク元に含めない
+func BuildBacklinkIndex(vault, tgt string, skipper Skipper, examinator NoteExaminator, db convert.PathDB) (*BacklinkIndex, error) {
+	index := &BacklinkIndex{
+		vault:     vault,
+		tgt:       tgt,
+		backlinks: make(map[string][]Backlink),
+	}
+	err := filepath.Walk(vault, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rpath, err := filepath.Rel(vault, path)
+		if err != nil {
+			return err
+		}
+		if skipper.Skip(rpath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+		if err := index.add(filepath.ToSlash(rpath), path, examinator, db); err != nil {
+			return errors.Wrapf(err, "failed to collect links in %s", rpath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for target, backlinks := range index.backlinks {
+		sort.Slice(backlinks, func(i, j int) bool {
+			return backlinks[i].Path < backlinks[j].Path
+		})
+		index.backlinks[target] = backlinks
+	}
+	return index, nil
+}
+
+func (index *BacklinkIndex) add(rpath, path string, examinator NoteExaminator, db convert.PathDB) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Errorf("failed to open %s", path)
+	}
+	yml, body := splitMarkdown([]rune(string(content)))
+	note, err := NewNoteInfo(path, body)
+	if err != nil {
+		return err
+	}
+	if ok, err := examinator.ExamineNote(yml, note); err != nil {
+		return errors.Wrap(err, "failed to examine note")
+	} else if !ok {
+		return nil
+	}
+
+	var links []convert.Link
+	if _, err := convert.NewLinkFinder(&links).Convert(body); err != nil {
+		return errors.Wrap(err, "LinkFinder failed")
+	}
+
+	var self *Backlink
+	linked := make(map[string]struct{})
+	for _, link := range links {
+		if link.FileId == "" {
+			continue
+		}
+		target, err := db.Get(link.FileId)
+		if err != nil {
+			return errors.Wrap(err, "PathDB.Get failed")
+		}
+		if target == "" || target == rpath {
+			continue
+		}
+		if _, ok := linked[target]; ok {
+			continue
+		}
+		linked[target] = struct{}{}
+		if self == nil {
+			title, err := findTitle(yml, body, rpath)
+			if err != nil {
+				return err
+			}
+			self = &Backlink{Path: rpath, Title: title}
+		}
+		index.backlinks[target] = append(index.backlinks[target], *self)
+	}
+	return nil
+}
+
+// relativePath は Processor に渡されるのと同じ tgt からの相対パス
+func (index *BacklinkIndex) Get(relativePath string) ([]Backlink, error) {
+	rpath, err := filepath.Rel(index.vault, filepath.Join(index.tgt, relativePath))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the path of %s relative to %s", relativePath, index.vault)
+	}
+	return index.backlinks[filepath.ToSlash(rpath)], nil
+}
+
+// front matter の title, H1, ファイル名の順に探す
+func findTitle(yml []byte, body []rune, rpath string) (title string, err error) {
+	fm := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(yml, fm); err != nil {
+		return "", errors.Wrap(err, "failed to unmarshal front matter")
+	}
+	if v, ok := fm["title"].(string); ok && v != "" {
+		return v, nil
+	}
+
+	titleFoundFrom, err := convert.NewTagRemover().Convert(body)
+	if err != nil {
+		return "", errors.Wrap(err, "preprocess TagRemover for finding titles failed")
+	}
+	titleFoundFrom, err = convert.NewLinkPlainConverter().Convert(titleFoundFrom)
+	if err != nil {
+		return "", errors.Wrap(err, "preprocess InternalLinkPlainConverter for finding titles failed")
+	}
+	if _, err := convert.NewTitleFinder(&title).Convert(titleFoundFrom); err != nil {
+		return "", errors.Wrap(err, "TitleFinder failed")
+	}
+	if title != "" {
+		return title, nil
+	}
+
+	return strings.TrimSuffix(filepath.Base(rpath), filepath.Ext(rpath)), nil
+}
@@ -16,6 +16,12 @@ type ArgPasser interface {
 	PassArg(frombody BodyConvAuxOut) (toyaml YamlConvAuxIn, err error)
 }
 
-type YamlExaminator interface {
-	ExamineYaml(yml []byte) (beProcessed bool, err error)
+// 処理対象かどうかの判定に使うノートの情報
+type NoteInfo struct {
+	Path string              // 変換前のノートのパス
+	Tags map[string]struct{} // 本文中のタグ. front matter のタグは含まない
+}
+
+type NoteExaminator interface {
+	ExamineNote(yml []byte, note *NoteInfo) (beProcessed bool, err error)
 }
@@ -0,0 +1,252 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DIFF_CONTEXT_LINES     = 3
+	DIFF_MAX_EDIT_DISTANCE = 2000 // これを超える場合は差分を探さず, 全体を置き換えたものとして扱う
+)
+
+type diffOp uint
+
+const (
+	DIFF_EQUAL diffOp = iota + 1
+	DIFF_DELETE
+	DIFF_INSERT
+)
+
+type diffLine struct {
+	op   diffOp
+	text string // 末尾の改行を含む
+}
+
+// 行単位の unified diff を返す
+// 差分がない場合は空文字列を返す
+func unifiedDiff(oldName, newName string, oldContent, newContent []byte) string {
+	lines := diffLines(splitLines(string(oldContent)), splitLines(string(newContent)))
+
+	changed := false
+	for _, l := range lines {
+		if l.op != DIFF_EQUAL {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", oldName, newName)
+
+	// 各行の旧ファイルと新ファイルでの行番号 (0 始まり)
+	oldLineNums := make([]int, len(lines)+1)
+	newLineNums := make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldLineNums[i+1] = oldLineNums[i]
+		newLineNums[i+1] = newLineNums[i]
+		if l.op != DIFF_INSERT {
+			oldLineNums[i+1]++
+		}
+		if l.op != DIFF_DELETE {
+			newLineNums[i+1]++
+		}
+	}
+
+	cur := 0
+	for cur < len(lines) {
+		// 次の変更箇所を探す
+		for cur < len(lines) && lines[cur].op == DIFF_EQUAL {
+			cur++
+		}
+		if cur >= len(lines) {
+			break
+		}
+		start := cur - DIFF_CONTEXT_LINES
+		if start < 0 {
+			start = 0
+		}
+		// 変更箇所の間の共通部分が短ければ同じ hunk にまとめる
+		end := cur
+		for end < len(lines) {
+			if lines[end].op != DIFF_EQUAL {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].op == DIFF_EQUAL {
+				next++
+			}
+			if next >= len(lines) || next-end > 2*DIFF_CONTEXT_LINES {
+				end += DIFF_CONTEXT_LINES
+				if end > len(lines) {
+					end = len(lines)
+				}
+				break
+			}
+			end = next
+		}
+
+		oldStart, oldCount := oldLineNums[start], oldLineNums[end]-oldLineNums[start]
+		newStart, newCount := newLineNums[start], newLineNums[end]-newLineNums[start]
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, l := range lines[start:end] {
+			switch l.op {
+			case DIFF_EQUAL:
+				b.WriteString(" ")
+			case DIFF_DELETE:
+				b.WriteString("-")
+			case DIFF_INSERT:
+				b.WriteString("+")
+			}
+			b.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		cur = end
+	}
+	return b.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// 改行を含めて行に分割する
+func splitLines(s string) []string {
+	lines := make([]string, 0, strings.Count(s, "\n")+1)
+	for s != "" {
+		pos := strings.IndexByte(s, '\n')
+		if pos < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:pos+1])
+		s = s[pos+1:]
+	}
+	return lines
+}
+
+func diffLines(a, b []string) []diffLine {
+	// 先頭と末尾の共通部分は差分の探索から除く
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		lines = append(lines, diffLine{op: DIFF_EQUAL, text: l})
+	}
+	lines = append(lines, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{op: DIFF_EQUAL, text: l})
+	}
+	return lines
+}
+
+// E. W. Myers, "An O(ND) Difference Algorithm and Its Variations"
+func myersDiff(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	replaceAll := func() []diffLine {
+		lines := make([]diffLine, 0, n+m)
+		for _, l := range a {
+			lines = append(lines, diffLine{op: DIFF_DELETE, text: l})
+		}
+		for _, l := range b {
+			lines = append(lines, diffLine{op: DIFF_INSERT, text: l})
+		}
+		return lines
+	}
+	if n == 0 || m == 0 {
+		return replaceAll()
+	}
+
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] は d 回目の探索を始める直前の v[offset-d-1 : offset+d+2]
+	trace := make([][]int, 0)
+	found := false
+	for d := 0; d <= max && d <= DIFF_MAX_EDIT_DISTANCE; d++ {
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return replaceAll()
+	}
+
+	// 後ろから辿る
+	reversed := make([]diffLine, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int {
+			return snapshot[k+d+1]
+		}
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffLine{op: DIFF_EQUAL, text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffLine{op: DIFF_INSERT, text: b[y-1]})
+			} else {
+				reversed = append(reversed, diffLine{op: DIFF_DELETE, text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	lines := make([]diffLine, len(reversed))
+	for i, l := range reversed {
+		lines[len(reversed)-1-i] = l
+	}
+	return lines
+}
@@ -0,0 +1,319 @@
+package process
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	GRAPH_FORMAT_JSON    = "json"
+	GRAPH_FORMAT_GRAPHML = "graphml"
+	GRAPH_FORMAT_DOT     = "dot"
+)
+
+var GRAPH_FORMATS = []string{GRAPH_FORMAT_JSON, GRAPH_FORMAT_GRAPHML, GRAPH_FORMAT_DOT}
+
+const (
+	GRAPH_NODE_NOTE       = "note"
+	GRAPH_NODE_ATTACHMENT = "attachment"
+)
+
+type GraphNode struct {
+	Id    string   `json:"id"` // vault からの相対パス
+	Kind  string   `json:"kind"`
+	Title string   `json:"title,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"` // internal, embed, uri
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// vault 内のノートとリンクからなるグラフ
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// vault 内のノートをノード, ノート間のリンクを辺とするグラフを作る
+// 参照先が見つからないリンクと自身へのリンクは含めない
+// ノートから参照される添付ファイルもノードに含める
+func BuildGraph(vault string, skipper Skipper, db convert.PathDB) (*Graph, error) {
+	graph := &Graph{
+		Nodes: make([]GraphNode, 0),
+		Edges: make([]GraphEdge, 0),
+	}
+	notes := make(map[string]struct{})
+	attachments := make(map[string]struct{})
+	edges := make(map[GraphEdge]struct{})
+
+	err := filepath.Walk(vault, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rpath, err := filepath.Rel(vault, path)
+		if err != nil {
+			return err
+		}
+		if skipper.Skip(rpath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+		rpath = filepath.ToSlash(rpath)
+
+		node, noteEdges, err := readGraphNode(rpath, path, db)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read links in %s", rpath)
+		}
+		notes[rpath] = struct{}{}
+		graph.Nodes = append(graph.Nodes, *node)
+		for _, e := range noteEdges {
+			if _, ok := edges[e]; ok {
+				continue
+			}
+			edges[e] = struct{}{}
+			graph.Edges = append(graph.Edges, e)
+			if filepath.Ext(e.Target) != ".md" {
+				attachments[e.Target] = struct{}{}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path := range attachments {
+		if _, ok := notes[path]; ok {
+			continue
+		}
+		graph.Nodes = append(graph.Nodes, GraphNode{Id: path, Kind: GRAPH_NODE_ATTACHMENT})
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].Id < graph.Nodes[j].Id
+	})
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Source != graph.Edges[j].Source {
+			return graph.Edges[i].Source < graph.Edges[j].Source
+		}
+		if graph.Edges[i].Target != graph.Edges[j].Target {
+			return graph.Edges[i].Target < graph.Edges[j].Target
+		}
+		return graph.Edges[i].Anchor < graph.Edges[j].Anchor
+	})
+	return graph, nil
+}
+
+func readGraphNode(rpath, path string, db convert.PathDB) (node *GraphNode, edges []GraphEdge, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Errorf("failed to open %s", path)
+	}
+	yml, body := splitMarkdown([]rune(string(content)))
+
+	title, err := findTitle(yml, body, rpath)
+	if err != nil {
+		return nil, nil, err
+	}
+	tags, err := findTags(yml, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	node = &GraphNode{Id: rpath, Kind: GRAPH_NODE_NOTE, Title: title, Tags: tags}
+
+	var links []convert.Link
+	if _, err := convert.NewLinkFinder(&links).Convert(body); err != nil {
+		return nil, nil, errors.Wrap(err, "LinkFinder failed")
+	}
+	for _, link := range links {
+		kind, ok := linkKindName(link.Kind)
+		if !ok {
+			continue
+		}
+		// 自身へのリンクは含めない
+		if link.FileId == "" {
+			continue
+		}
+		target, err := db.Get(link.FileId)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "PathDB.Get failed")
+		}
+		if target == "" || target == rpath {
+			continue
+		}
+		e := GraphEdge{Source: rpath, Target: target, Kind: kind}
+		if len(link.Fragments) > 0 {
+			e.Anchor = link.Fragments[len(link.Fragments)-1]
+		}
+		edges = append(edges, e)
+	}
+	return node, edges, nil
+}
+
+// front matter の tags と本文中のタグ
+func findTags(yml []byte, body []rune) ([]string, error) {
+	found := make(map[string]struct{})
+	fm := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(yml, fm); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal front matter")
+	}
+	if v, ok := fm["tags"].([]interface{}); ok {
+		for _, t := range v {
+			if tt, ok := t.(string); ok {
+				found[tt] = struct{}{}
+			}
+		}
+	}
+	if _, err := convert.NewTagFinder(found).Convert(body); err != nil {
+		return nil, errors.Wrap(err, "TagFinder failed")
+	}
+	tags := make([]string, 0, len(found))
+	for t := range found {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func WriteGraph(out io.Writer, graph *Graph, format string) error {
+	var err error
+	switch format {
+	case GRAPH_FORMAT_JSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(graph)
+	case GRAPH_FORMAT_GRAPHML:
+		err = writeGraphML(out, graph)
+	case GRAPH_FORMAT_DOT:
+		err = writeDot(out, graph)
+	default:
+		return errors.Errorf("unknown graph format: %s", format)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to write graph in %s", format)
+	}
+	return nil
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLKey struct {
+	Id       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLGraph struct {
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+func writeGraphML(out io.Writer, graph *Graph) error {
+	doc := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{Id: "kind", For: "node", AttrName: "kind", AttrType: "string"},
+			{Id: "title", For: "node", AttrName: "title", AttrType: "string"},
+			{Id: "tags", For: "node", AttrName: "tags", AttrType: "string"},
+			{Id: "edgekind", For: "edge", AttrName: "kind", AttrType: "string"},
+			{Id: "anchor", For: "edge", AttrName: "anchor", AttrType: "string"},
+		},
+		Graph: graphMLGraph{EdgeDefault: "directed"},
+	}
+	for _, n := range graph.Nodes {
+		node := graphMLNode{Id: n.Id, Data: []graphMLData{{Key: "kind", Value: n.Kind}}}
+		if n.Title != "" {
+			node.Data = append(node.Data, graphMLData{Key: "title", Value: n.Title})
+		}
+		if len(n.Tags) > 0 {
+			node.Data = append(node.Data, graphMLData{Key: "tags", Value: strings.Join(n.Tags, " ")})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range graph.Edges {
+		edge := graphMLEdge{Source: e.Source, Target: e.Target, Data: []graphMLData{{Key: "edgekind", Value: e.Kind}}}
+		if e.Anchor != "" {
+			edge.Data = append(edge.Data, graphMLData{Key: "anchor", Value: e.Anchor})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+func writeDot(out io.Writer, graph *Graph) error {
+	b := new(strings.Builder)
+	b.WriteString("digraph vault {\n")
+	for _, n := range graph.Nodes {
+		fmt.Fprintf(b, "  %s [kind=%s", strconv.Quote(n.Id), strconv.Quote(n.Kind))
+		if n.Title != "" {
+			fmt.Fprintf(b, ", label=%s", strconv.Quote(n.Title))
+		}
+		if len(n.Tags) > 0 {
+			fmt.Fprintf(b, ", tags=%s", strconv.Quote(strings.Join(n.Tags, " ")))
+		}
+		b.WriteString("];\n")
+	}
+	for _, e := range graph.Edges {
+		fmt.Fprintf(b, "  %s -> %s [kind=%s", strconv.Quote(e.Source), strconv.Quote(e.Target), strconv.Quote(e.Kind))
+		if e.Anchor != "" {
+			fmt.Fprintf(b, ", anchor=%s", strconv.Quote(e.Anchor))
+		}
+		b.WriteString("];\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(out, b.String())
+	return err
+}
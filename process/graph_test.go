@@ -0,0 +1,71 @@
+package process
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/qawatake/obsdconv/convert"
+)
+
+func TestBuildGraph(t *testing.T) {
+	vault := t.TempDir()
+	if err := os.Mkdir(filepath.Join(vault, "sub"), 0o777); err != nil {
+		t.Fatalf("[FATAL] failed to create directory: %v", err)
+	}
+	files := map[string]string{
+		"a.md":      "---\ntags: [fm]\n---\n# Title A\n[[b#section]] [[b#section]] ![[image.png]] #inline\n[[missing]] [[#self]]\n",
+		"sub/b.md":  "[[a]] [x](obsidian://open?vault=v&file=a)\n",
+		"image.png": "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(vault, name), []byte(content), 0o666); err != nil {
+			t.Fatalf("[FATAL] failed to write: %v", err)
+		}
+	}
+	skipper, err := NewSkipper(filepath.Join(vault, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+
+	got, err := BuildGraph(vault, skipper, convert.NewPathDB(vault))
+	if err != nil {
+		t.Fatalf("[FATAL] BuildGraph failed: %v", err)
+	}
+	want := &Graph{
+		Nodes: []GraphNode{
+			{Id: "a.md", Kind: GRAPH_NODE_NOTE, Title: "Title A", Tags: []string{"fm", "inline"}},
+			{Id: "image.png", Kind: GRAPH_NODE_ATTACHMENT},
+			{Id: "sub/b.md", Kind: GRAPH_NODE_NOTE, Title: "b", Tags: []string{}},
+		},
+		Edges: []GraphEdge{
+			{Source: "a.md", Target: "image.png", Kind: "embed"},
+			{Source: "a.md", Target: "sub/b.md", Kind: "internal", Anchor: "section"},
+			{Source: "sub/b.md", Target: "a.md", Kind: "internal"},
+			{Source: "sub/b.md", Target: "a.md", Kind: "uri"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("[ERROR]\n got: %+v\nwant: %+v", got, want)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteGraph(buf, want, GRAPH_FORMAT_DOT); err != nil {
+		t.Fatalf("[FATAL] WriteGraph failed: %v", err)
+	}
+	wantDot := `digraph vault {
+  "a.md" [kind="note", label="Title A", tags="fm inline"];
+  "image.png" [kind="attachment"];
+  "sub/b.md" [kind="note", label="b"];
+  "a.md" -> "image.png" [kind="embed"];
+  "a.md" -> "sub/b.md" [kind="internal", anchor="section"];
+  "sub/b.md" -> "a.md" [kind="internal"];
+  "sub/b.md" -> "a.md" [kind="uri"];
+}
+`
+	if buf.String() != wantDot {
+		t.Errorf("[ERROR]\n got: %s\nwant: %s", buf.String(), wantDot)
+	}
+}
@@ -0,0 +1,312 @@
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+)
+
+const (
+	MANIFEST_FILE_NAME = ".obsdconv-manifest.json"
+)
+
+type manifestEntry struct {
+	ContentHash string            `json:"contentHash"`
+	OptionsHash string            `json:"optionsHash"`
+	Output      string            `json:"output,omitempty"` // 出力先の dst からの相対パス
+	Refs        map[string]string `json:"refs,omitempty"`   // fileId -> 変換時に解決されたパス
+}
+
+// 出力先を記録していない古い manifest では, 元のファイルと同じ相対パスに出力されたとみなす
+func (entry manifestEntry) outputPath(rpath string) string {
+	if entry.Output == "" {
+		return rpath
+	}
+	return entry.Output
+}
+
+// 前回の変換結果を記録しておき, 変更のないファイルの再変換を省く
+type Manifest struct {
+	path        string
+	dst         string
+	optionsHash string
+	mu          sync.Mutex
+	previous    map[string]manifestEntry
+	current     map[string]manifestEntry
+	seen        map[string]bool
+}
+
+// dst に書き込まれた manifest を読み込む
+// manifest が存在しない場合は空の manifest を返す
+func LoadManifest(dst string, optionsHash string) (*Manifest, error) {
+	m := &Manifest{
+		path:        filepath.Join(dst, MANIFEST_FILE_NAME),
+		dst:         dst,
+		optionsHash: optionsHash,
+		previous:    make(map[string]manifestEntry),
+		current:     make(map[string]manifestEntry),
+		seen:        make(map[string]bool),
+	}
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read %s", m.path)
+	}
+	if err := json.Unmarshal(data, &m.previous); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", m.path)
+	}
+	return m, nil
+}
+
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, err := json.MarshalIndent(m.current, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal manifest")
+	}
+	if err := os.WriteFile(m.path, data, 0o666); err != nil {
+		return errors.Wrapf(err, "failed to write %s", m.path)
+	}
+	return nil
+}
+
+// 前回は存在したが今回の walk で見つからなかったファイルの出力を削除する
+// 今回ほかのファイルが同じ場所に出力した場合は削除しない
+func (m *Manifest) RemoveVanished(dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outputs := make(map[string]bool)
+	for rpath, entry := range m.current {
+		outputs[entry.outputPath(rpath)] = true
+	}
+	for rpath, entry := range m.previous {
+		if m.seen[rpath] {
+			continue
+		}
+		output := entry.outputPath(rpath)
+		if outputs[output] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dst, filepath.FromSlash(output))); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove output of vanished file %s", rpath)
+		}
+	}
+	return nil
+}
+
+// newpath の dst からの相対パス
+func (m *Manifest) outputPath(newpath string) (string, error) {
+	output, err := filepath.Rel(m.dst, newpath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the path of %s relative to %s", newpath, m.dst)
+	}
+	return filepath.ToSlash(output), nil
+}
+
+// 今回すでにほかのファイルが output に出力していれば true
+func (m *Manifest) outputTaken(output string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for rpath, entry := range m.current {
+		if entry.outputPath(rpath) == output {
+			return true
+		}
+	}
+	return false
+}
+
+// 前回の出力先. 前回のエントリがなければ ok = false
+func (m *Manifest) previousOutput(rpath string) (output string, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.previous[rpath]
+	if !ok {
+		return "", false
+	}
+	return entry.outputPath(rpath), true
+}
+
+func (m *Manifest) markSeen(rpath string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seen[rpath] = true
+}
+
+// 内容, オプション, 出力先, リンク先の解決結果がすべて前回と同じで, 出力が残っていれば up to date
+// up to date の場合は前回のエントリを今回の manifest に引き継ぐ
+func (m *Manifest) keepIfUpToDate(rpath string, contentHash string, output string, db convert.PathDB) bool {
+	m.mu.Lock()
+	entry, ok := m.previous[rpath]
+	m.mu.Unlock()
+	if !ok || entry.ContentHash != contentHash || entry.OptionsHash != m.optionsHash || entry.outputPath(rpath) != output {
+		return false
+	}
+	// -watch で削除された出力は作り直す
+	if _, err := os.Stat(filepath.Join(m.dst, filepath.FromSlash(output))); err != nil {
+		return false
+	}
+	for fileId, path := range entry.Refs {
+		if got, err := db.Get(fileId); err != nil || got != path {
+			return false
+		}
+	}
+	m.record(rpath, entry)
+	return true
+}
+
+func (m *Manifest) record(rpath string, entry manifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.current[rpath] = entry
+}
+
+type processorImplIncremental struct {
+	sub        Processor
+	db         convert.PathDB
+	manifest   *Manifest
+	examinator NoteExaminator
+}
+
+// 変更のないファイルの処理を省く Processor を返す
+// db はリンク先の変更を検出するために使う
+// examinator が nil でなければ, 処理対象外と判定されたノートは manifest に記録しない
+// filter や pubRule の結果は now によって内容が同じでも変わりうるので, 毎回判定し直すため
+func WrapForIncremental(sub Processor, db convert.PathDB, manifest *Manifest, examinator NoteExaminator) Processor {
+	return &processorImplIncremental{
+		sub:        sub,
+		db:         db,
+		manifest:   manifest,
+		examinator: examinator,
+	}
+}
+
+func (p *processorImplIncremental) Process(relativePath, orgpath, newpath string) error {
+	rpath := filepath.ToSlash(relativePath)
+	if rpath == MANIFEST_FILE_NAME {
+		return nil
+	}
+	p.manifest.markSeen(rpath)
+
+	content, err := os.ReadFile(orgpath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", orgpath)
+	}
+	contentHash := hashContent(content)
+	output, err := p.manifest.outputPath(newpath)
+	if err != nil {
+		return err
+	}
+	if p.examinator != nil && filepath.Ext(orgpath) == ".md" {
+		yml, body := splitMarkdown([]rune(string(content)))
+		note, err := NewNoteInfo(orgpath, body)
+		if err != nil {
+			return err
+		}
+		if ok, err := p.examinator.ExamineNote(yml, note); err != nil {
+			return errors.Wrap(err, "failed to examine note")
+		} else if !ok {
+			return p.removeOldOutputs(rpath, orgpath, newpath, output)
+		}
+	}
+	if p.manifest.keepIfUpToDate(rpath, contentHash, output, p.db) {
+		return nil
+	}
+
+	// 変換の結果, 出力されないこともあるので, 古い出力は先に削除しておく
+	if err := p.removeOldOutputs(rpath, orgpath, newpath, output); err != nil {
+		return err
+	}
+
+	if err := p.sub.Process(relativePath, orgpath, newpath); err != nil {
+		return err
+	}
+
+	// src = dst の場合は変換後の内容で記録しないと, 毎回変更ありと判定されてしまう
+	if samePath(orgpath, newpath) {
+		converted, err := os.ReadFile(newpath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", newpath)
+		}
+		contentHash = hashContent(converted)
+	}
+
+	entry := manifestEntry{
+		ContentHash: contentHash,
+		OptionsHash: p.manifest.optionsHash,
+		Output:      output,
+	}
+	if filepath.Ext(orgpath) == ".md" {
+		refs, err := resolveRefs(content, p.db)
+		if err != nil {
+			// リンク先を記録できない場合は, 次回も変換し直す
+			return nil
+		}
+		entry.Refs = refs
+	}
+	p.manifest.record(rpath, entry)
+	return nil
+}
+
+// slug などで出力先が変わった場合は前回の出力先も削除する
+// src = dst の場合は元のファイルを消さないように注意
+func (p *processorImplIncremental) removeOldOutputs(rpath, orgpath, newpath, output string) error {
+	oldpaths := []string{newpath}
+	if previous, ok := p.manifest.previousOutput(rpath); ok && previous != output && !p.manifest.outputTaken(previous) {
+		oldpaths = append(oldpaths, filepath.Join(p.manifest.dst, filepath.FromSlash(previous)))
+	}
+	for _, oldpath := range oldpaths {
+		if samePath(orgpath, oldpath) {
+			continue
+		}
+		if err := os.Remove(oldpath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %s", oldpath)
+		}
+	}
+	return nil
+}
+
+func resolveRefs(content []byte, db convert.PathDB) (refs map[string]string, err error) {
+	_, body := splitMarkdown([]rune(string(content)))
+	var links []convert.Link
+	if _, err := convert.NewLinkFinder(&links).Convert(body); err != nil {
+		return nil, errors.Wrap(err, "LinkFinder failed")
+	}
+	refs = make(map[string]string)
+	for _, link := range links {
+		if link.FileId == "" {
+			continue
+		}
+		if _, ok := refs[link.FileId]; ok {
+			continue
+		}
+		path, err := db.Get(link.FileId)
+		if err != nil {
+			return nil, errors.Wrap(err, "PathDB.Get failed")
+		}
+		refs[link.FileId] = path
+	}
+	return refs, nil
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func samePath(path1, path2 string) bool {
+	abs1, err1 := filepath.Abs(path1)
+	abs2, err2 := filepath.Abs(path2)
+	if err1 != nil || err2 != nil {
+		return filepath.Clean(path1) == filepath.Clean(path2)
+	}
+	return abs1 == abs2
+}
@@ -0,0 +1,281 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/qawatake/obsdconv/convert"
+)
+
+type fakeCopyingProcessor struct {
+	mu        sync.Mutex
+	processed []string
+}
+
+func (p *fakeCopyingProcessor) Process(relativePath, orgpath, newpath string) error {
+	p.mu.Lock()
+	p.processed = append(p.processed, filepath.ToSlash(relativePath))
+	p.mu.Unlock()
+	content, err := os.ReadFile(orgpath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(newpath), 0o777); err != nil {
+		return err
+	}
+	return os.WriteFile(newpath, content, 0o666)
+}
+
+func TestWrapForIncremental(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	files := map[string]string{
+		"a.md":      "[[b]]\n",
+		"b.md":      "b\n",
+		"c.md":      "c\n",
+		"image.png": "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o666); err != nil {
+			t.Fatalf("[FATAL] failed to write: %v", err)
+		}
+	}
+	skipper, err := NewSkipper(filepath.Join(src, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+
+	runIncremental := func(optionsHash string) []string {
+		manifest, err := LoadManifest(dst, optionsHash)
+		if err != nil {
+			t.Fatalf("[FATAL] LoadManifest failed: %v", err)
+		}
+		sub := new(fakeCopyingProcessor)
+		if err := Walk(src, dst, skipper, WrapForIncremental(sub, convert.NewPathDB(src), manifest, nil)); err != nil {
+			t.Fatalf("[FATAL] Walk failed: %v", err)
+		}
+		if err := manifest.RemoveVanished(dst); err != nil {
+			t.Fatalf("[FATAL] RemoveVanished failed: %v", err)
+		}
+		if err := manifest.Save(); err != nil {
+			t.Fatalf("[FATAL] Save failed: %v", err)
+		}
+		sort.Strings(sub.processed)
+		return sub.processed
+	}
+
+	cases := []struct {
+		name          string
+		prepare       func()
+		optionsHash   string
+		wantProcessed []string
+	}{
+		{
+			name:          "first run",
+			prepare:       func() {},
+			optionsHash:   "x",
+			wantProcessed: []string{"a.md", "b.md", "c.md", "image.png"},
+		},
+		{
+			name:          "nothing changed",
+			prepare:       func() {},
+			optionsHash:   "x",
+			wantProcessed: nil,
+		},
+		{
+			name: "content changed",
+			prepare: func() {
+				os.WriteFile(filepath.Join(src, "c.md"), []byte("changed\n"), 0o666)
+			},
+			optionsHash:   "x",
+			wantProcessed: []string{"c.md"},
+		},
+		{
+			name: "link target moved",
+			prepare: func() {
+				os.Mkdir(filepath.Join(src, "sub"), 0o777)
+				os.Rename(filepath.Join(src, "b.md"), filepath.Join(src, "sub", "b.md"))
+			},
+			optionsHash:   "x",
+			wantProcessed: []string{"a.md", "sub/b.md"},
+		},
+		{
+			name:          "options changed",
+			prepare:       func() {},
+			optionsHash:   "y",
+			wantProcessed: []string{"a.md", "c.md", "image.png", "sub/b.md"},
+		},
+	}
+
+	for _, tt := range cases {
+		tt.prepare()
+		got := runIncremental(tt.optionsHash)
+		if len(got) != len(tt.wantProcessed) {
+			t.Errorf("[ERROR | %s] got: %v, want: %v", tt.name, got, tt.wantProcessed)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.wantProcessed[i] {
+				t.Errorf("[ERROR | %s] got: %v, want: %v", tt.name, got, tt.wantProcessed)
+				break
+			}
+		}
+	}
+
+	// 移動前の b.md の出力は削除されている
+	if _, err := os.Stat(filepath.Join(dst, "b.md")); !os.IsNotExist(err) {
+		t.Errorf("[ERROR] output of vanished file was not removed")
+	}
+}
+
+func TestWrapForIncrementalWithMovedOutputs(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	files := map[string]string{
+		"notes/My Note.md": "[[Other]]\n",
+		"notes/Other.md":   "other\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatalf("[FATAL] failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+			t.Fatalf("[FATAL] failed to write: %v", err)
+		}
+	}
+	// 元のファイルと同じ相対パスにある無関係なファイル
+	unrelated := filepath.Join(dst, "notes", "My Note.md")
+	if err := os.MkdirAll(filepath.Dir(unrelated), 0o777); err != nil {
+		t.Fatalf("[FATAL] failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(unrelated, []byte("unrelated\n"), 0o666); err != nil {
+		t.Fatalf("[FATAL] failed to write: %v", err)
+	}
+	skipper, err := NewSkipper(filepath.Join(src, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+	remap := map[string]string{"notes/": "content/posts/"}
+
+	runIncremental := func() {
+		manifest, err := LoadManifest(dst, "x")
+		if err != nil {
+			t.Fatalf("[FATAL] LoadManifest failed: %v", err)
+		}
+		slugs, err := BuildSlugIndex(src, src, skipper)
+		if err != nil {
+			t.Fatalf("[FATAL] BuildSlugIndex failed: %v", err)
+		}
+		var processor Processor = new(fakeCopyingProcessor)
+		processor = WrapForIncremental(processor, convert.NewPathDB(src), manifest, nil)
+		processor = WrapForRemappingOutputs(processor, src, src, dst, remap, nil, slugs)
+		if err := WalkWithoutMkdir(src, dst, skipper, processor); err != nil {
+			t.Fatalf("[FATAL] Walk failed: %v", err)
+		}
+		if err := manifest.RemoveVanished(dst); err != nil {
+			t.Fatalf("[FATAL] RemoveVanished failed: %v", err)
+		}
+		if err := manifest.Save(); err != nil {
+			t.Fatalf("[FATAL] Save failed: %v", err)
+		}
+	}
+
+	runIncremental()
+	for _, name := range []string{"content/posts/my-note.md", "content/posts/other.md"} {
+		if _, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("[FATAL] %s was not written: %v", name, err)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(src, "notes", "My Note.md")); err != nil {
+		t.Fatalf("[FATAL] failed to remove: %v", err)
+	}
+	runIncremental()
+
+	cases := []struct {
+		name       string
+		path       string
+		wantExists bool
+	}{
+		{name: "output of the removed note", path: "content/posts/my-note.md", wantExists: false},
+		{name: "output of the remaining note", path: "content/posts/other.md", wantExists: true},
+		{name: "unrelated file at the source path", path: "notes/My Note.md", wantExists: true},
+	}
+	for _, tt := range cases {
+		_, err := os.Stat(filepath.Join(dst, filepath.FromSlash(tt.path)))
+		if exists := err == nil; exists != tt.wantExists {
+			t.Errorf("[ERROR | %s] %s exists: %v, want: %v", tt.name, tt.path, exists, tt.wantExists)
+		}
+	}
+}
+
+// publishDate <= now のように, 内容が同じでも結果が変わる examinator
+type switchingExaminator struct {
+	published bool
+}
+
+func (e *switchingExaminator) ExamineNote(yml []byte, note *NoteInfo) (bool, error) {
+	return e.published, nil
+}
+
+func TestWrapForIncrementalWithExaminator(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "scheduled.md"), []byte("---\npublishDate: 2030-01-01\n---\n"), 0o666); err != nil {
+		t.Fatalf("[FATAL] failed to write: %v", err)
+	}
+	skipper, err := NewSkipper(filepath.Join(src, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+	examinator := new(switchingExaminator)
+
+	cases := []struct {
+		name       string
+		published  bool
+		wantExists bool
+	}{
+		{name: "before publishDate", published: false, wantExists: false},
+		{name: "after publishDate", published: true, wantExists: true},
+		{name: "unpublished again", published: false, wantExists: false},
+	}
+	for _, tt := range cases {
+		examinator.published = tt.published
+		manifest, err := LoadManifest(dst, "x")
+		if err != nil {
+			t.Fatalf("[FATAL | %s] LoadManifest failed: %v", tt.name, err)
+		}
+		sub := new(fakeCopyingProcessor)
+		processor := WrapForIncremental(&examiningProcessor{sub: sub, examinator: examinator}, convert.NewPathDB(src), manifest, examinator)
+		if err := Walk(src, dst, skipper, processor); err != nil {
+			t.Fatalf("[FATAL | %s] Walk failed: %v", tt.name, err)
+		}
+		if err := manifest.RemoveVanished(dst); err != nil {
+			t.Fatalf("[FATAL | %s] RemoveVanished failed: %v", tt.name, err)
+		}
+		if err := manifest.Save(); err != nil {
+			t.Fatalf("[FATAL | %s] Save failed: %v", tt.name, err)
+		}
+		_, err = os.Stat(filepath.Join(dst, "scheduled.md"))
+		if exists := err == nil; exists != tt.wantExists {
+			t.Errorf("[ERROR | %s] output exists: %v, want: %v", tt.name, exists, tt.wantExists)
+		}
+	}
+}
+
+// examinator で処理対象外と判定されたノートを出力しない
+type examiningProcessor struct {
+	sub        Processor
+	examinator NoteExaminator
+}
+
+func (p *examiningProcessor) Process(relativePath, orgpath, newpath string) error {
+	if ok, _ := p.examinator.ExamineNote(nil, nil); !ok {
+		return nil
+	}
+	return p.sub.Process(relativePath, orgpath, newpath)
+}
@@ -0,0 +1,259 @@
+package process
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	PERMALINK_URL_KEY   = "url"   // パスを直接指定する front matter のキー
+	PERMALINK_DATE_KEY  = "date"  // :year, :month, :day に使う front matter のキー
+	PERMALINK_TITLE_KEY = "title" // :title に使う front matter のキー
+)
+
+// Hugo の permalinks と同じ記法
+var PERMALINK_TOKENS = []string{":year", ":month", ":day", ":slug", ":title", ":filename", ":section", ":sections"}
+
+var permalinkTokenPattern = regexp.MustCompile(`:[a-z]+`)
+
+var duplicateSlashes = regexp.MustCompile(`/{2,}`)
+
+// front matter の date として受け付ける形式
+var permalinkDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// pattern に使えないトークンが含まれていればエラー
+func VerifyPermalinkPattern(pattern string) error {
+	for _, token := range permalinkTokenPattern.FindAllString(pattern, -1) {
+		var valid bool
+		for _, t := range PERMALINK_TOKENS {
+			if token == t {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return errors.Errorf("unknown token %s in permalink pattern %q. must choose from %s", token, pattern, strings.Join(PERMALINK_TOKENS, ", "))
+		}
+	}
+	return nil
+}
+
+// ノートへのリンクに使うパス
+type PermalinkIndex struct {
+	permalinks map[string]string // key: vault からの相対パス
+	problems   []error
+}
+
+// vault 内のノートの permalink を決める
+// front matter に url があればそのまま使う
+// なければ, パスの先頭が patterns のキーに一致するノートに対応するパターンを展開する. 複数のキーに一致する場合は最も長いキーを使う
+// 一致しなければ, Hugo と同じく front matter の slug でパスの最後の要素を置き換えた / 始まりの URL にする
+// いずれにも当てはまらないノートは permalink を持たない
+// examinator で処理対象外と判定されたノートは permalink を持たない
+// date を必要とするパターンで date がない, または読めないノートも permalink を持たず, Problems で報告する
+func BuildPermalinkIndex(vault string, skipper Skipper, examinator NoteExaminator, patterns map[string]string) (*PermalinkIndex, error) {
+	index := &PermalinkIndex{
+		permalinks: make(map[string]string),
+	}
+	err := filepath.Walk(vault, func(fpath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rpath, err := filepath.Rel(vault, fpath)
+		if err != nil {
+			return err
+		}
+		if skipper.Skip(rpath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.Ext(fpath) != ".md" {
+			return nil
+		}
+		rpath = filepath.ToSlash(rpath)
+		yml, body, err := ReadNote(fpath)
+		if err != nil {
+			return err
+		}
+		note, err := NewNoteInfo(fpath, body)
+		if err != nil {
+			return err
+		}
+		if ok, err := examinator.ExamineNote(yml, note); err != nil {
+			return errors.Wrapf(err, "failed to examine %s", rpath)
+		} else if !ok {
+			return nil
+		}
+		permalink, err := findPermalink(rpath, yml, patterns)
+		if err != nil {
+			// 1 つのノートのために全体を止めない
+			index.problems = append(index.problems, errors.Wrapf(err, "failed to make the permalink of %s", rpath))
+			return nil
+		}
+		if permalink != "" {
+			index.permalinks[rpath] = permalink
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func findPermalink(rpath string, yml []byte, patterns map[string]string) (permalink string, err error) {
+	fm := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(yml, fm); err != nil {
+		return "", errors.Wrap(err, "failed to unmarshal front matter")
+	}
+	if v, ok := fm[PERMALINK_URL_KEY].(string); ok && v != "" {
+		return "/" + strings.TrimPrefix(v, "/"), nil
+	}
+	if pattern, ok := matchPermalinkPattern(rpath, patterns); ok {
+		return expandPermalinkPattern(pattern, rpath, fm)
+	}
+	if v, ok := fm[SLUG_KEY].(string); ok && v != "" {
+		if slug := slugify(v); slug != "" {
+			return path.Join("/", path.Dir(rpath), slug) + "/", nil
+		}
+	}
+	return "", nil
+}
+
+func matchPermalinkPattern(rpath string, patterns map[string]string) (pattern string, ok bool) {
+	var prefix string
+	for p := range patterns {
+		if strings.HasPrefix(rpath, p) && (!ok || len(p) > len(prefix)) {
+			ok = true
+			prefix = p
+		}
+	}
+	return patterns[prefix], ok
+}
+
+func expandPermalinkPattern(pattern, rpath string, fm map[interface{}]interface{}) (permalink string, err error) {
+	filename := strings.TrimSuffix(path.Base(rpath), ".md")
+	var date time.Time
+	var dateFound bool
+	var expandErr error
+	expanded := permalinkTokenPattern.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch token {
+		case ":year", ":month", ":day":
+			if !dateFound {
+				d, err := permalinkDate(fm)
+				if err != nil {
+					expandErr = err
+					return ""
+				}
+				date = d
+				dateFound = true
+			}
+			switch token {
+			case ":year":
+				return fmt.Sprintf("%04d", date.Year())
+			case ":month":
+				return fmt.Sprintf("%02d", int(date.Month()))
+			default:
+				return fmt.Sprintf("%02d", date.Day())
+			}
+		case ":slug":
+			if v, ok := fm[SLUG_KEY].(string); ok && v != "" {
+				return slugify(v)
+			}
+			return permalinkTitle(fm, filename)
+		case ":title":
+			return permalinkTitle(fm, filename)
+		case ":filename":
+			return filename
+		case ":section":
+			if !strings.Contains(rpath, "/") {
+				return ""
+			}
+			return strings.SplitN(rpath, "/", 2)[0]
+		case ":sections":
+			if dir := path.Dir(rpath); dir != "." {
+				return dir
+			}
+			return ""
+		default:
+			expandErr = errors.Errorf("unknown token %s", token)
+			return ""
+		}
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	// 空になったトークンの前後の / をまとめる
+	return duplicateSlashes.ReplaceAllString("/"+expanded, "/"), nil
+}
+
+func permalinkDate(fm map[interface{}]interface{}) (time.Time, error) {
+	switch v := fm[PERMALINK_DATE_KEY].(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range permalinkDateLayouts {
+			if date, err := time.Parse(layout, v); err == nil {
+				return date, nil
+			}
+		}
+		return time.Time{}, errors.Errorf("failed to parse %s in front matter: %q", PERMALINK_DATE_KEY, v)
+	default:
+		return time.Time{}, errors.Errorf("%s not found in front matter", PERMALINK_DATE_KEY)
+	}
+}
+
+// front matter の title, なければファイル名を slug にしたもの
+func permalinkTitle(fm map[interface{}]interface{}, filename string) string {
+	if v, ok := fm[PERMALINK_TITLE_KEY].(string); ok && v != "" {
+		return slugify(v)
+	}
+	return slugify(filename)
+}
+
+// permalink を決められなかったノートごとのエラー
+func (index *PermalinkIndex) Problems() []error {
+	return index.problems
+}
+
+// vault からの相対パスを受け取り, permalink を返す
+// permalink を持たない場合は ok = false
+func (index *PermalinkIndex) Get(vaultPath string) (permalink string, ok bool) {
+	permalink, ok = index.permalinks[vaultPath]
+	return permalink, ok
+}
+
+type pathDBWrapperImplUsingPermalinks struct {
+	original convert.PathDB
+	index    *PermalinkIndex
+}
+
+func (w *pathDBWrapperImplUsingPermalinks) Get(fileId string) (path string, err error) {
+	path, err = w.original.Get(fileId)
+	if err != nil {
+		return "", err
+	}
+	if permalink, ok := w.index.Get(path); ok {
+		return permalink, nil
+	}
+	return path, nil
+}
+
+// permalink を持つノートのパスを permalink に置き換える PathDB を返す
+func WrapForUsingPermalinks(original convert.PathDB, index *PermalinkIndex) convert.PathDB {
+	return &pathDBWrapperImplUsingPermalinks{
+		original: original,
+		index:    index,
+	}
+}
@@ -0,0 +1,146 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qawatake/obsdconv/convert"
+)
+
+func TestVerifyPermalinkPattern(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern string
+		wantErr bool
+	}{
+		{name: "all tokens", pattern: "/:sections/:section/:year/:month/:day/:slug/:title/:filename/"},
+		{name: "no tokens", pattern: "/posts/"},
+		{name: "unknown token", pattern: "/posts/:author/", wantErr: true},
+	}
+
+	for _, tt := range cases {
+		err := VerifyPermalinkPattern(tt.pattern)
+		if tt.wantErr && err == nil {
+			t.Errorf("[ERROR | %s] error expected but not returned", tt.name)
+		} else if !tt.wantErr && err != nil {
+			t.Errorf("[ERROR | %s] unexpected error: %v", tt.name, err)
+		}
+	}
+}
+
+func TestBuildPermalinkIndex(t *testing.T) {
+	cases := []struct {
+		name         string
+		files        map[string]string
+		patterns     map[string]string
+		wantPaths    map[string]string // key: fileId
+		wantProblems int
+	}{
+		{
+			name: "front matter and patterns",
+			files: map[string]string{
+				"posts/first.md":        "---\ndate: 2021-03-04\nslug: First Post\n---\n",
+				"posts/2022/second.md":  "---\ndate: 2022-11-30T09:00:00+09:00\ntitle: Second Note\n---\n",
+				"posts/drafts/third.md": "---\nurl: /drafts/third/\n---\n",
+				"docs/guide/Install.md": "",
+				"notes/custom.md":       "---\nslug: Custom Name\n---\n",
+				"notes/plain.md":        "",
+				"posts/secret.md":       "---\ndate: 2021-03-04\nprivate: true\n---\n",
+				"image.png":             "",
+			},
+			patterns: map[string]string{
+				"posts/":      "/posts/:year/:month/:day/:slug/",
+				"posts/2022/": "/:section/:title/",
+				"docs/":       "/:sections/:filename",
+			},
+			wantPaths: map[string]string{
+				"first":     "/posts/2021/03/04/first-post/",
+				"second":    "/posts/second-note/",
+				"third":     "/drafts/third/",
+				"Install":   "/docs/guide/Install",
+				"custom":    "/notes/custom-name/",
+				"plain":     "notes/plain.md",
+				"secret":    "posts/secret.md",
+				"image.png": "image.png",
+			},
+		},
+		{
+			name: "pattern for all notes",
+			files: map[string]string{
+				"top.md":       "",
+				"dir/child.md": "",
+			},
+			patterns: map[string]string{
+				"": "/:section/:filename/",
+			},
+			wantPaths: map[string]string{
+				"top":   "/top/",
+				"child": "/dir/child/",
+			},
+		},
+		{
+			name: "date not found",
+			files: map[string]string{
+				"posts/first.md":  "",
+				"posts/second.md": "---\ndate: 2021-03-04\n---\n",
+			},
+			patterns: map[string]string{
+				"posts/": "/:year/:filename/",
+			},
+			wantPaths: map[string]string{
+				"first":  "posts/first.md",
+				"second": "/2021/second/",
+			},
+			wantProblems: 1,
+		},
+		{
+			name: "invalid date",
+			files: map[string]string{
+				"posts/first.md": "---\ndate: yesterday\n---\n",
+			},
+			patterns: map[string]string{
+				"posts/": "/:year/:filename/",
+			},
+			wantPaths: map[string]string{
+				"first": "posts/first.md",
+			},
+			wantProblems: 1,
+		},
+	}
+
+	for _, tt := range cases {
+		vault := t.TempDir()
+		for name, content := range tt.files {
+			path := filepath.Join(vault, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+				t.Fatalf("[FATAL | %s] failed to create directory: %v", tt.name, err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+				t.Fatalf("[FATAL | %s] failed to write: %v", tt.name, err)
+			}
+		}
+		skipper, err := NewSkipper(filepath.Join(vault, ".obsdconvignore"))
+		if err != nil {
+			t.Fatalf("[FATAL | %s] NewSkipper failed: %v", tt.name, err)
+		}
+
+		index, err := BuildPermalinkIndex(vault, skipper, privateExaminator{}, tt.patterns)
+		if err != nil {
+			t.Fatalf("[FATAL | %s] BuildPermalinkIndex failed: %v", tt.name, err)
+		}
+		if got := len(index.Problems()); got != tt.wantProblems {
+			t.Errorf("[ERROR | %s] number of problems got: %d, want: %d", tt.name, got, tt.wantProblems)
+		}
+		db := WrapForUsingPermalinks(convert.NewPathDB(vault), index)
+		for fileId, want := range tt.wantPaths {
+			got, err := db.Get(fileId)
+			if err != nil {
+				t.Fatalf("[FATAL | %s] PathDB.Get failed: %v", tt.name, err)
+			}
+			if got != want {
+				t.Errorf("[ERROR | %s] fileId: %s, got: %q, want: %q", tt.name, fileId, got, want)
+			}
+		}
+	}
+}
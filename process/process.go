@@ -2,6 +2,7 @@ package process
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
 )
 
 type Processor interface {
@@ -19,33 +21,28 @@ type ProcessorImpl struct {
 	BodyConverter
 	YamlConverter
 	ArgPasser
-	YamlExaminator
+	NoteExaminator
+	FileWriter
 }
 
-func NewProcessor(bc BodyConverter, yc YamlConverter, passer ArgPasser, examinator YamlExaminator) Processor {
+func NewProcessor(bc BodyConverter, yc YamlConverter, passer ArgPasser, examinator NoteExaminator) Processor {
+	return NewProcessorWithFileWriter(bc, yc, passer, examinator, NewFileWriter())
+}
+
+func NewProcessorWithFileWriter(bc BodyConverter, yc YamlConverter, passer ArgPasser, examinator NoteExaminator, writer FileWriter) Processor {
 	return &ProcessorImpl{
 		BodyConverter:  bc,
 		YamlConverter:  yc,
 		ArgPasser:      passer,
-		YamlExaminator: examinator,
+		NoteExaminator: examinator,
+		FileWriter:     writer,
 	}
 }
 
 func (p *ProcessorImpl) Process(relativePath, orgpath, newpath string) error {
 
 	if filepath.Ext(orgpath) != ".md" {
-		file, err := os.Open(orgpath)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		newfile, err := os.Create(newpath)
-		if err != nil {
-			return err
-		}
-		defer newfile.Close()
-		io.Copy(newfile, file)
-		return nil
+		return p.CopyFile(orgpath, newpath)
 	}
 
 	readFrom, err := os.Open(orgpath)
@@ -60,8 +57,12 @@ func (p *ProcessorImpl) Process(relativePath, orgpath, newpath string) error {
 
 	yml, body := splitMarkdown([]rune(string(content)))
 
-	if ok, err := p.ExamineYaml(yml); err != nil {
-		return errors.Wrap(err, "failed to examine yaml front mattter")
+	note, err := NewNoteInfo(orgpath, body)
+	if err != nil {
+		return err
+	}
+	if ok, err := p.ExamineNote(yml, note); err != nil {
+		return errors.Wrap(err, "failed to examine note")
 	} else if !ok {
 		return nil
 	}
@@ -81,22 +82,37 @@ func (p *ProcessorImpl) Process(relativePath, orgpath, newpath string) error {
 		return errors.Wrap(err, "failed to convert yaml")
 	}
 
-	// os.Create によってファイルの内容は削除されるので,
-	// 変換がすべて正常に行われた後で, 書き込み先のファイルを開く
-	writeTo, err := os.Create(newpath)
-	if err != nil {
-		return errors.Wrapf(err, "failed to create %s", newpath)
-	}
-	defer writeTo.Close()
+	// 書き込みによってファイルの内容は削除されるので,
+	// 変換がすべて正常に行われた後で, 書き込む
+	buf := new(bytes.Buffer)
 
 	// front matter
 	if yml != nil {
-		fmt.Fprintf(writeTo, "---\n%s---\n", string(yml))
+		fmt.Fprintf(buf, "---\n%s---\n", string(yml))
 	}
 
 	// body
-	io.WriteString(writeTo, string(output))
-	return nil
+	buf.WriteString(string(output))
+	return p.WriteFile(newpath, buf.Bytes())
+}
+
+// 本文中のタグを集めて NoteInfo を作る
+func NewNoteInfo(path string, body []rune) (*NoteInfo, error) {
+	tags := make(map[string]struct{})
+	if _, err := convert.NewTagFinder(tags).Convert(body); err != nil {
+		return nil, errors.Wrap(err, "TagFinder failed")
+	}
+	return &NoteInfo{Path: path, Tags: tags}, nil
+}
+
+// ノートを読み込んで yaml front matter と本文を切り離す
+func ReadNote(path string) (yml []byte, body []rune, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Errorf("failed to open %s", path)
+	}
+	yml, body = splitMarkdown([]rune(string(content)))
+	return yml, body, nil
 }
 
 // yaml front matter と本文を切り離す
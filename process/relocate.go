@@ -0,0 +1,191 @@
+package process
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+)
+
+const (
+	ATTACHMENT_NAME_ORIGINAL = "original" // ファイル名を変えない
+	ATTACHMENT_NAME_HASH     = "hash"     // 内容のハッシュ値をファイル名にする
+)
+
+var ATTACHMENT_NAMES = []string{ATTACHMENT_NAME_ORIGINAL, ATTACHMENT_NAME_HASH}
+
+const ATTACHMENT_HASH_LENGTH = 16 // ファイル名にするハッシュ値の桁数
+
+// tgt 内の添付ファイル (.md 以外のファイル) の移動先
+type AttachmentRelocator struct {
+	vault     string
+	tgt       string
+	outputs   map[string]string // key: vault からの相対パス, value: dst からの相対パス
+	links     map[string]string // key: vault からの相対パス, value: リンクに使うパス
+	canonical map[string]string // key: dst からの相対パス, value: 実際にコピーする添付ファイルの vault からの相対パス
+}
+
+// tgt 内の添付ファイルの移動先を決める
+// dir が空文字列でなければ, すべての添付ファイルを dst 直下の dir に移す. 空文字列なら元のディレクトリのままにする
+// name が ATTACHMENT_NAME_HASH なら, 拡張子はそのままでファイル名を内容のハッシュ値にする
+// 内容が同じファイルは一つにまとめる. 内容の異なるファイルの移動先が重なる場合はエラー
+// attachments が nil でなければ, 参照されていてコピーされる添付ファイルだけをまとめる対象にする
+func BuildAttachmentRelocator(vault, tgt string, skipper Skipper, dir string, name string, attachments *AttachmentIndex) (*AttachmentRelocator, error) {
+	r := &AttachmentRelocator{
+		vault:     vault,
+		tgt:       tgt,
+		outputs:   make(map[string]string),
+		links:     make(map[string]string),
+		canonical: make(map[string]string),
+	}
+	dir = path.Clean(filepath.ToSlash(dir))
+	hashes := make(map[string]string) // key: dst からの相対パス
+	err := filepath.Walk(tgt, func(fpath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rpath, err := filepath.Rel(tgt, fpath)
+		if err != nil {
+			return err
+		}
+		if skipper.Skip(rpath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.Ext(fpath) == ".md" || filepath.ToSlash(rpath) == MANIFEST_FILE_NAME {
+			return nil
+		}
+		vpath, err := filepath.Rel(vault, fpath)
+		if err != nil {
+			return err
+		}
+		vpath = filepath.ToSlash(vpath)
+		rpath = filepath.ToSlash(rpath)
+
+		content, err := os.ReadFile(fpath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", fpath)
+		}
+		hash := hashContent(content)
+		base := path.Base(rpath)
+		if name == ATTACHMENT_NAME_HASH {
+			base = hash[:ATTACHMENT_HASH_LENGTH] + path.Ext(rpath)
+		}
+		var output, link string
+		if dir != "." {
+			output = path.Join(dir, base)
+			link = output
+		} else {
+			output = path.Join(path.Dir(rpath), base)
+			link = path.Join(path.Dir(vpath), base)
+		}
+
+		r.outputs[vpath] = output
+		r.links[vpath] = link
+		// コピーされないファイルは, 実際にコピーするファイルの候補にしない
+		if attachments != nil {
+			referenced, err := attachments.Referenced(rpath)
+			if err != nil {
+				return err
+			}
+			if !referenced {
+				return nil
+			}
+		}
+		if org, ok := r.canonical[output]; ok {
+			if hashes[output] != hash {
+				return errors.Errorf("%s and %s are relocated to the same path %s", org, vpath, output)
+			}
+		} else {
+			r.canonical[output] = vpath
+			hashes[output] = hash
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// vault からの相対パスを受け取り, リンクに使うパスを返す
+// tgt 外のファイルやノートの場合は ok = false
+func (r *AttachmentRelocator) Link(vaultPath string) (link string, ok bool) {
+	link, ok = r.links[vaultPath]
+	return link, ok
+}
+
+// 内容が同じファイルのうち, 実際にコピーするものかどうか
+// tgt 外のファイルやノートの場合は true
+func (r *AttachmentRelocator) copied(vaultPath string) bool {
+	output, ok := r.outputs[vaultPath]
+	if !ok {
+		return true
+	}
+	return r.canonical[output] == vaultPath
+}
+
+type pathDBWrapperImplRelocating struct {
+	original  convert.PathDB
+	relocator *AttachmentRelocator
+}
+
+func (w *pathDBWrapperImplRelocating) Get(fileId string) (path string, err error) {
+	path, err = w.original.Get(fileId)
+	if err != nil {
+		return "", err
+	}
+	if link, ok := w.relocator.Link(path); ok {
+		return link, nil
+	}
+	return path, nil
+}
+
+// 添付ファイルのパスを移動先のパスに置き換える PathDB を返す
+func WrapForRelocatingAttachments(original convert.PathDB, relocator *AttachmentRelocator) convert.PathDB {
+	return &pathDBWrapperImplRelocating{
+		original:  original,
+		relocator: relocator,
+	}
+}
+
+type processorImplRelocating struct {
+	sub       Processor
+	relocator *AttachmentRelocator
+	dst       string
+}
+
+// 添付ファイルを移動先にコピーする Processor を返す
+// 内容が同じファイルは一度だけコピーする
+// sub には dst からの相対パスとして移動先のパスが渡される
+func WrapForMovingAttachments(sub Processor, relocator *AttachmentRelocator, dst string) Processor {
+	return &processorImplRelocating{
+		sub:       sub,
+		relocator: relocator,
+		dst:       dst,
+	}
+}
+
+func (p *processorImplRelocating) Process(relativePath, orgpath, newpath string) error {
+	if filepath.Ext(orgpath) == ".md" {
+		return p.sub.Process(relativePath, orgpath, newpath)
+	}
+	vpath, err := filepath.Rel(p.relocator.vault, filepath.Join(p.relocator.tgt, relativePath))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the path of %s relative to %s", relativePath, p.relocator.vault)
+	}
+	vpath = filepath.ToSlash(vpath)
+	output, ok := p.relocator.outputs[vpath]
+	if !ok {
+		return p.sub.Process(relativePath, orgpath, newpath)
+	}
+	if !p.relocator.copied(vpath) {
+		return nil
+	}
+	return p.sub.Process(filepath.FromSlash(output), orgpath, filepath.Join(p.dst, filepath.FromSlash(output)))
+}
@@ -0,0 +1,114 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qawatake/obsdconv/convert"
+)
+
+func TestBuildAttachmentRelocator(t *testing.T) {
+	hashA := hashContent([]byte("a"))[:ATTACHMENT_HASH_LENGTH]
+	hashB := hashContent([]byte("b"))[:ATTACHMENT_HASH_LENGTH]
+	cases := []struct {
+		name      string
+		files     map[string]string
+		dir       string
+		attName   string
+		wantLinks map[string]string // key: fileId
+		wantErr   bool
+	}{
+		{
+			name: "move into a directory",
+			files: map[string]string{
+				"note.md":     "",
+				"img/a.png":   "a",
+				"docs/b.pdf":  "b",
+				"docs/c.md":   "",
+				"docs/c.json": "c",
+			},
+			dir:     "static/attachments",
+			attName: ATTACHMENT_NAME_ORIGINAL,
+			wantLinks: map[string]string{
+				"a.png":  "static/attachments/a.png",
+				"b.pdf":  "static/attachments/b.pdf",
+				"c":      "docs/c.md",
+				"c.json": "static/attachments/c.json",
+			},
+		},
+		{
+			name: "hash names in place",
+			files: map[string]string{
+				"img/a.png":  "a",
+				"docs/b.pdf": "b",
+			},
+			attName: ATTACHMENT_NAME_HASH,
+			wantLinks: map[string]string{
+				"a.png": "img/" + hashA + ".png",
+				"b.pdf": "docs/" + hashB + ".pdf",
+			},
+		},
+		{
+			name: "same content is merged",
+			files: map[string]string{
+				"x/a.png": "a",
+				"y/b.png": "a",
+			},
+			dir:     "static",
+			attName: ATTACHMENT_NAME_HASH,
+			wantLinks: map[string]string{
+				"a.png": "static/" + hashA + ".png",
+				"b.png": "static/" + hashA + ".png",
+			},
+		},
+		{
+			name: "different files with the same name",
+			files: map[string]string{
+				"x/a.png": "a",
+				"y/a.png": "b",
+			},
+			dir:     "static",
+			attName: ATTACHMENT_NAME_ORIGINAL,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		vault := t.TempDir()
+		for name, content := range tt.files {
+			path := filepath.Join(vault, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+				t.Fatalf("[FATAL | %s] failed to create directory: %v", tt.name, err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+				t.Fatalf("[FATAL | %s] failed to write: %v", tt.name, err)
+			}
+		}
+		skipper, err := NewSkipper(filepath.Join(vault, ".obsdconvignore"))
+		if err != nil {
+			t.Fatalf("[FATAL | %s] NewSkipper failed: %v", tt.name, err)
+		}
+
+		relocator, err := BuildAttachmentRelocator(vault, vault, skipper, tt.dir, tt.attName, nil)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("[ERROR | %s] error expected but not returned", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[FATAL | %s] BuildAttachmentRelocator failed: %v", tt.name, err)
+		}
+		db := WrapForRelocatingAttachments(convert.NewPathDB(vault), relocator)
+		for fileId, want := range tt.wantLinks {
+			got, err := db.Get(fileId)
+			if err != nil {
+				t.Fatalf("[FATAL | %s] PathDB.Get failed: %v", tt.name, err)
+			}
+			if got != want {
+				t.Errorf("[ERROR | %s] fileId: %s, got: %q, want: %q", tt.name, fileId, got, want)
+			}
+		}
+	}
+}
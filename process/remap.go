@@ -0,0 +1,60 @@
+package process
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+)
+
+type processorImplRemappingOutputs struct {
+	sub       Processor
+	vault     string
+	tgt       string
+	dst       string
+	remap     map[string]string
+	relocator *AttachmentRelocator
+	slugs     *SlugIndex
+}
+
+// リンクと同じように出力先のパスの先頭を remap で置き換える Processor を返す
+// 出力先は dst を基準とした, 置き換え後の vault からの相対パスになる
+// relocator が nil でなければ, 添付ファイルは移動先のパスを置き換える
+// slugs が nil でなければ, ノートは slug にしたパスを置き換える
+// sub に渡す relativePath は変えない. ノートの変換では元のパスを使うため
+func WrapForRemappingOutputs(sub Processor, vault, tgt, dst string, remap map[string]string, relocator *AttachmentRelocator, slugs *SlugIndex) Processor {
+	return &processorImplRemappingOutputs{
+		sub:       sub,
+		vault:     vault,
+		tgt:       tgt,
+		dst:       dst,
+		remap:     remap,
+		relocator: relocator,
+		slugs:     slugs,
+	}
+}
+
+func (p *processorImplRemappingOutputs) Process(relativePath, orgpath, newpath string) error {
+	vpath, err := filepath.Rel(p.vault, filepath.Join(p.tgt, relativePath))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the path of %s relative to %s", relativePath, p.vault)
+	}
+	path := filepath.ToSlash(vpath)
+	if p.relocator != nil {
+		if !p.relocator.copied(path) {
+			return nil
+		}
+		if link, ok := p.relocator.Link(path); ok {
+			path = link
+		}
+	}
+	if p.slugs != nil {
+		if slugged, ok := p.slugs.Get(path); ok {
+			path = slugged
+		}
+	}
+	// 置き換え後のパスが / で始まる場合は dst を基準にする
+	output := filepath.FromSlash(strings.TrimPrefix(convert.RemapPathPrefix(p.remap, path), "/"))
+	return p.sub.Process(relativePath, orgpath, filepath.Join(p.dst, output))
+}
@@ -0,0 +1,102 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// 受け取った newpath を記録するだけの Processor
+type fakeRecordingProcessor struct {
+	mu       sync.Mutex
+	dst      string
+	newpaths []string
+}
+
+func (p *fakeRecordingProcessor) Process(relativePath, orgpath, newpath string) error {
+	rpath, err := filepath.Rel(p.dst, newpath)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.newpaths = append(p.newpaths, filepath.ToSlash(rpath))
+	p.mu.Unlock()
+	return nil
+}
+
+func TestWrapForRemappingOutputs(t *testing.T) {
+	vault := t.TempDir()
+	for _, dir := range []string{"notes", "static", "other"} {
+		if err := os.Mkdir(filepath.Join(vault, dir), 0o777); err != nil {
+			t.Fatalf("[FATAL] failed to create directory: %v", err)
+		}
+	}
+	files := map[string]string{
+		"notes/a.md":       "",
+		"static/image.png": "image",
+		"static/same.png":  "image",
+		"other/b.md":       "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(vault, filepath.FromSlash(name)), []byte(content), 0o666); err != nil {
+			t.Fatalf("[FATAL] failed to write: %v", err)
+		}
+	}
+	skipper, err := NewSkipper(filepath.Join(vault, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+	remap := map[string]string{
+		"notes/":  "content/posts/",
+		"static/": "/",
+		"assets/": "images/",
+	}
+	dst := "dst"
+	hash := hashContent([]byte("image"))[:ATTACHMENT_HASH_LENGTH]
+
+	cases := []struct {
+		name     string
+		tgt      string
+		relocate bool
+		want     []string
+	}{
+		{
+			name: "vault",
+			tgt:  vault,
+			want: []string{"content/posts/a.md", "image.png", "other/b.md", "same.png"},
+		},
+		{
+			name: "tgt",
+			tgt:  filepath.Join(vault, "notes"),
+			want: []string{"content/posts/a.md"},
+		},
+		{
+			name:     "relocated attachments",
+			tgt:      vault,
+			relocate: true,
+			want:     []string{"content/posts/a.md", "images/" + hash + ".png", "other/b.md"},
+		},
+	}
+
+	for _, tt := range cases {
+		var relocator *AttachmentRelocator
+		if tt.relocate {
+			relocator, err = BuildAttachmentRelocator(vault, tt.tgt, skipper, "assets", ATTACHMENT_NAME_HASH, nil)
+			if err != nil {
+				t.Fatalf("[FATAL | %s] BuildAttachmentRelocator failed: %v", tt.name, err)
+			}
+		}
+		sub := &fakeRecordingProcessor{dst: dst}
+		processor := WrapForRemappingOutputs(sub, vault, tt.tgt, dst, remap, relocator, nil)
+		if err := WalkDryRun(tt.tgt, dst, skipper, processor); err != nil {
+			t.Fatalf("[FATAL | %s] WalkDryRun failed: %v", tt.name, err)
+		}
+		sort.Strings(sub.newpaths)
+		if !reflect.DeepEqual(sub.newpaths, tt.want) {
+			t.Errorf("[ERROR | %s] got: %v, want: %v", tt.name, sub.newpaths, tt.want)
+		}
+	}
+}
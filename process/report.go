@@ -0,0 +1,176 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+)
+
+const (
+	LINK_PROBLEM_BROKEN    = "broken"    // 参照先が見つからない
+	LINK_PROBLEM_AMBIGUOUS = "ambiguous" // 参照先の候補が複数ある
+)
+
+const (
+	REPORT_FORMAT_TEXT = "text"
+	REPORT_FORMAT_JSON = "json"
+)
+
+var REPORT_FORMATS = []string{REPORT_FORMAT_TEXT, REPORT_FORMAT_JSON}
+
+// 解決できないリンク
+type LinkProblem struct {
+	Path       string   `json:"path"` // vault からの相対パス
+	Line       int      `json:"line"`
+	Problem    string   `json:"problem"`
+	Kind       string   `json:"kind"` // internal, embed, uri
+	FileId     string   `json:"fileId"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// tgt 内のノートのリンクのうち, db で解決できないものと参照先が一つに決まらないものを返す
+// 対象は内部リンク, 埋め込み, obsidian URI
+// 結果はパスと行番号の順に並ぶ
+func FindLinkProblems(vault, tgt string, skipper Skipper, db convert.SearchablePathDB) ([]LinkProblem, error) {
+	problems := make([]LinkProblem, 0)
+	err := filepath.Walk(tgt, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rpath, err := filepath.Rel(tgt, path)
+		if err != nil {
+			return err
+		}
+		if skipper.Skip(rpath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+
+		vpath, err := filepath.Rel(vault, path)
+		if err != nil {
+			return err
+		}
+		found, err := findLinkProblemsInFile(path, db)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check links in %s", vpath)
+		}
+		for _, p := range found {
+			p.Path = filepath.ToSlash(vpath)
+			problems = append(problems, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Path != problems[j].Path {
+			return problems[i].Path < problems[j].Path
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+func findLinkProblemsInFile(path string, db convert.SearchablePathDB) ([]LinkProblem, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("failed to open %s", path)
+	}
+	yml, body := splitMarkdown([]rune(string(content)))
+	// front matter と区切り線 --- の分だけ行番号をずらす
+	offset := 0
+	if yml != nil {
+		offset = strings.Count(string(yml), "\n") + 2
+	}
+
+	var links []convert.Link
+	if _, err := convert.NewLinkFinder(&links).Convert(body); err != nil {
+		return nil, errors.Wrap(err, "LinkFinder failed")
+	}
+
+	var problems []LinkProblem
+	for _, link := range links {
+		kind, ok := linkKindName(link.Kind)
+		if !ok {
+			continue
+		}
+		// [[#section]] のように自身を参照するリンク
+		if link.FileId == "" {
+			continue
+		}
+		candidates, err := db.Candidates(link.FileId)
+		if err != nil {
+			return nil, errors.Wrap(err, "PathDB.Candidates failed")
+		}
+		p := LinkProblem{
+			Line:   link.Line + offset,
+			Kind:   kind,
+			FileId: link.FileId,
+		}
+		if len(candidates) == 0 {
+			p.Problem = LINK_PROBLEM_BROKEN
+		} else if len(candidates) > 1 {
+			p.Problem = LINK_PROBLEM_AMBIGUOUS
+			p.Candidates = candidates
+		} else {
+			continue
+		}
+		problems = append(problems, p)
+	}
+	return problems, nil
+}
+
+// 内部リンク, 埋め込み, obsidian URI 以外は ok = false
+func linkKindName(kind convert.LinkKind) (name string, ok bool) {
+	switch kind {
+	case convert.LINK_KIND_INTERNAL:
+		return "internal", true
+	case convert.LINK_KIND_EMBEDS:
+		return "embed", true
+	case convert.LINK_KIND_OBSIDIAN_URI:
+		return "uri", true
+	default:
+		return "", false
+	}
+}
+
+func WriteLinkReport(out io.Writer, problems []LinkProblem, format string) error {
+	switch format {
+	case REPORT_FORMAT_JSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(problems); err != nil {
+			return errors.Wrap(err, "failed to encode link report")
+		}
+		return nil
+	case REPORT_FORMAT_TEXT:
+		for _, p := range problems {
+			var err error
+			if p.Problem == LINK_PROBLEM_AMBIGUOUS {
+				_, err = fmt.Fprintf(out, "%s:%d: %s %s link %q: %s\n", p.Path, p.Line, p.Problem, p.Kind, p.FileId, strings.Join(p.Candidates, ", "))
+			} else {
+				_, err = fmt.Fprintf(out, "%s:%d: %s %s link %q\n", p.Path, p.Line, p.Problem, p.Kind, p.FileId)
+			}
+			if err != nil {
+				return errors.Wrap(err, "failed to write link report")
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown report format: %s", format)
+	}
+}
@@ -0,0 +1,94 @@
+package process
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/qawatake/obsdconv/convert"
+)
+
+func TestFindLinkProblems(t *testing.T) {
+	vault := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(vault, dir), 0o777); err != nil {
+			t.Fatalf("[FATAL] failed to create directory: %v", err)
+		}
+	}
+	files := map[string]string{
+		"note.md":   "---\ntitle: note\n---\n[[a/dup]] [[missing]]\n![[none.png]] ![[image.png]]\n[x](obsidian://open?vault=v&file=gone)\n[[#section]] [[dup]]\n`[[in code]]`\n",
+		"image.png": "",
+		"a/dup.md":  "",
+		"b/dup.md":  "[[note]]\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(vault, name), []byte(content), 0o666); err != nil {
+			t.Fatalf("[FATAL] failed to write: %v", err)
+		}
+	}
+	skipper, err := NewSkipper(filepath.Join(vault, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+
+	got, err := FindLinkProblems(vault, vault, skipper, convert.NewSearchablePathDB(vault))
+	if err != nil {
+		t.Fatalf("[FATAL] FindLinkProblems failed: %v", err)
+	}
+	want := []LinkProblem{
+		{Path: "note.md", Line: 4, Problem: LINK_PROBLEM_BROKEN, Kind: "internal", FileId: "missing"},
+		{Path: "note.md", Line: 5, Problem: LINK_PROBLEM_BROKEN, Kind: "embed", FileId: "none.png"},
+		{Path: "note.md", Line: 6, Problem: LINK_PROBLEM_BROKEN, Kind: "uri", FileId: "gone"},
+		{Path: "note.md", Line: 7, Problem: LINK_PROBLEM_AMBIGUOUS, Kind: "internal", FileId: "dup", Candidates: []string{"a/dup.md", "b/dup.md"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("[ERROR] got: %+v, want: %+v", got, want)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteLinkReport(buf, want[2:], REPORT_FORMAT_TEXT); err != nil {
+		t.Fatalf("[FATAL] WriteLinkReport failed: %v", err)
+	}
+	wantText := "note.md:6: broken uri link \"gone\"\nnote.md:7: ambiguous internal link \"dup\": a/dup.md, b/dup.md\n"
+	if buf.String() != wantText {
+		t.Errorf("[ERROR] got: %q, want: %q", buf.String(), wantText)
+	}
+}
+
+func TestFindLinkProblemsWithSkipper(t *testing.T) {
+	vault := t.TempDir()
+	for _, dir := range []string{"private", "public"} {
+		if err := os.Mkdir(filepath.Join(vault, dir), 0o777); err != nil {
+			t.Fatalf("[FATAL] failed to create directory: %v", err)
+		}
+	}
+	files := map[string]string{
+		"note.md":           "[[secret]] [[dup]]\n",
+		"private/secret.md": "",
+		"private/dup.md":    "",
+		"public/dup.md":     "",
+		".obsdconvignore":   "private\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(vault, name), []byte(content), 0o666); err != nil {
+			t.Fatalf("[FATAL] failed to write: %v", err)
+		}
+	}
+	skipper, err := NewSkipper(filepath.Join(vault, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+
+	got, err := FindLinkProblems(vault, vault, skipper, WrapSearchableForSkipping(convert.NewSearchablePathDB(vault), skipper))
+	if err != nil {
+		t.Fatalf("[FATAL] FindLinkProblems failed: %v", err)
+	}
+	want := []LinkProblem{
+		{Path: "note.md", Line: 1, Problem: LINK_PROBLEM_BROKEN, Kind: "internal", FileId: "secret"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("[ERROR] got: %+v, want: %+v", got, want)
+	}
+}
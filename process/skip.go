@@ -89,3 +89,33 @@ func WrapForSkipping(original convert.PathDB, skipper Skipper) convert.PathDB {
 		skipper:  skipper,
 	}
 }
+
+type searchablePathDBWrapperImplSkipping struct {
+	*pathDBWrapperImplSkipping
+	searchable convert.SearchablePathDB
+}
+
+// skipper で除かれるファイルは候補に含めない
+func (w *searchablePathDBWrapperImplSkipping) Candidates(fileId string) (paths []string, err error) {
+	candidates, err := w.searchable.Candidates(fileId)
+	if err != nil {
+		return nil, err
+	}
+	paths = make([]string, 0, len(candidates))
+	for _, path := range candidates {
+		if !w.skipper.Skip(path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+func WrapSearchableForSkipping(original convert.SearchablePathDB, skipper Skipper) convert.SearchablePathDB {
+	return &searchablePathDBWrapperImplSkipping{
+		pathDBWrapperImplSkipping: &pathDBWrapperImplSkipping{
+			original: original,
+			skipper:  skipper,
+		},
+		searchable: original,
+	}
+}
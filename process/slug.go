@@ -0,0 +1,206 @@
+package process
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+	"golang.org/x/text/unicode/norm"
+	"gopkg.in/yaml.v2"
+)
+
+const SLUG_KEY = "slug" // ファイル名の代わりに使う front matter のキー
+
+// ノートのディレクトリとファイル名を slug にしたパス
+type SlugIndex struct {
+	vault string
+	tgt   string
+	paths map[string]string // key: vault からの相対パス, value: ディレクトリとファイル名を slug にしたパス
+}
+
+// vault 内のすべてのノートの slug を決める
+// front matter に slug があればファイル名の代わりに使う
+// ディレクトリも slug にする. slug が空になるディレクトリ名はそのまま使う
+// slug にしたパスが重なる場合やファイル名の slug が空になる場合はエラー
+func BuildSlugIndex(vault, tgt string, skipper Skipper) (*SlugIndex, error) {
+	index := &SlugIndex{
+		vault: vault,
+		tgt:   tgt,
+		paths: make(map[string]string),
+	}
+	found := make(map[string]string) // key: slug にしたパス, value: 元のパス
+	err := filepath.Walk(vault, func(fpath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rpath, err := filepath.Rel(vault, fpath)
+		if err != nil {
+			return err
+		}
+		if skipper.Skip(rpath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.Ext(fpath) != ".md" {
+			return nil
+		}
+		rpath = filepath.ToSlash(rpath)
+
+		name, err := slugSource(fpath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the slug of %s", rpath)
+		}
+		slug := slugify(name)
+		if slug == "" {
+			return errors.Errorf("failed to make a slug from the name of %s. set %s in the front matter", rpath, SLUG_KEY)
+		}
+		slugged := path.Join(slugDir(path.Dir(rpath)), slug+".md")
+		if org, ok := found[slugged]; ok {
+			return errors.Errorf("%s and %s have the same slug %s", org, rpath, slug)
+		}
+		found[slugged] = rpath
+		index.paths[rpath] = slugged
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// front matter の slug, なければ拡張子を除いたファイル名
+func slugSource(fpath string) (name string, err error) {
+	content, err := os.ReadFile(fpath)
+	if err != nil {
+		return "", errors.Errorf("failed to open %s", fpath)
+	}
+	yml, _ := splitMarkdown([]rune(string(content)))
+	fm := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(yml, fm); err != nil {
+		return "", errors.Wrap(err, "failed to unmarshal front matter")
+	}
+	if v, ok := fm[SLUG_KEY].(string); ok && v != "" {
+		return v, nil
+	}
+	base := filepath.Base(fpath)
+	return strings.TrimSuffix(base, filepath.Ext(base)), nil
+}
+
+// ディレクトリの各要素を slug にする. slug が空になる要素はそのまま残す
+func slugDir(dir string) string {
+	if dir == "." {
+		return dir
+	}
+	segments := strings.Split(dir, "/")
+	for i, segment := range segments {
+		if slug := slugify(segment); slug != "" {
+			segments[i] = slug
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// ファイル名の slug と同じ規則で name を slug にする
+func Slugify(name string) string {
+	return slugify(name)
+}
+
+// アクセント記号を外して小文字にし, 文字と数字以外の連続をハイフンにする
+// ASCII にできない文字 (日本語など) は文字であればそのまま残す
+func slugify(name string) string {
+	b := new(strings.Builder)
+	hyphen := false
+	var base rune // 直前の結合文字でない文字
+	for _, r := range norm.NFKD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// ひらがなの濁点などは残す
+			if base > unicode.MaxASCII {
+				b.WriteRune(r)
+			}
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if hyphen && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			hyphen = false
+			b.WriteRune(unicode.ToLower(r))
+			base = r
+		default:
+			hyphen = true
+			base = r
+		}
+	}
+	return norm.NFC.String(b.String())
+}
+
+// vault からの相対パスを受け取り, ディレクトリとファイル名を slug にしたパスを返す
+// ノート以外の場合は ok = false
+func (index *SlugIndex) Get(vaultPath string) (slugged string, ok bool) {
+	slugged, ok = index.paths[vaultPath]
+	return slugged, ok
+}
+
+type pathDBWrapperImplSlugging struct {
+	original convert.PathDB
+	index    *SlugIndex
+}
+
+func (w *pathDBWrapperImplSlugging) Get(fileId string) (path string, err error) {
+	path, err = w.original.Get(fileId)
+	if err != nil {
+		return "", err
+	}
+	if slugged, ok := w.index.Get(path); ok {
+		return slugged, nil
+	}
+	return path, nil
+}
+
+// ノートのパスのディレクトリとファイル名を slug に置き換える PathDB を返す
+func WrapForSluggingPaths(original convert.PathDB, index *SlugIndex) convert.PathDB {
+	return &pathDBWrapperImplSlugging{
+		original: original,
+		index:    index,
+	}
+}
+
+type processorImplSlugging struct {
+	sub   Processor
+	index *SlugIndex
+	dst   string
+}
+
+// ノートの出力先のディレクトリとファイル名を slug に置き換える Processor を返す
+// 出力先は dst を基準とした, slug にした tgt からの相対パスになる
+// 出力先の先頭を置き換える場合は, この Processor ではなく WrapForRemappingOutputs に index を渡す
+func WrapForSluggingOutputs(sub Processor, index *SlugIndex, dst string) Processor {
+	return &processorImplSlugging{
+		sub:   sub,
+		index: index,
+		dst:   dst,
+	}
+}
+
+func (p *processorImplSlugging) Process(relativePath, orgpath, newpath string) error {
+	if filepath.Ext(orgpath) != ".md" {
+		return p.sub.Process(relativePath, orgpath, newpath)
+	}
+	vpath, err := filepath.Rel(p.index.vault, filepath.Join(p.index.tgt, relativePath))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the path of %s relative to %s", relativePath, p.index.vault)
+	}
+	slugged, ok := p.index.Get(filepath.ToSlash(vpath))
+	if !ok {
+		return p.sub.Process(relativePath, orgpath, newpath)
+	}
+	// slug は要素ごとに決まるので, tgt より上のディレクトリは関係ない
+	output := path.Join(slugDir(path.Dir(filepath.ToSlash(relativePath))), path.Base(slugged))
+	return p.sub.Process(relativePath, orgpath, filepath.Join(p.dst, filepath.FromSlash(output)))
+}
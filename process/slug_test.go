@@ -0,0 +1,132 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qawatake/obsdconv/convert"
+)
+
+func TestSlugify(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "spaces", in: "My First Note", want: "my-first-note"},
+		{name: "accents", in: "Café Crème", want: "cafe-creme"},
+		{name: "punctuation", in: "  What's new?! (2021) ", want: "what-s-new-2021"},
+		{name: "japanese", in: "日本語 ノート", want: "日本語-ノート"},
+		{name: "dakuten", in: "ガイド", want: "ガイド"},
+		{name: "emoji", in: "🎉 party 🎉", want: "party"},
+		{name: "slug", in: "already-a-slug", want: "already-a-slug"},
+		{name: "only emoji", in: "🎉", want: ""},
+	}
+
+	for _, tt := range cases {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("[ERROR | %s] got: %q, want: %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSlugIndex(t *testing.T) {
+	cases := []struct {
+		name      string
+		files     map[string]string
+		wantPaths map[string]string // key: fileId
+		wantErr   bool
+	}{
+		{
+			name: "file names and front matter",
+			files: map[string]string{
+				"My Note.md":         "",
+				"dir/Café.md":        "",
+				"custom.md":          "---\nslug: Hello World\n---\n",
+				"dir/image file.png": "",
+			},
+			wantPaths: map[string]string{
+				"My Note":        "my-note.md",
+				"Café":           "dir/cafe.md",
+				"custom":         "hello-world.md",
+				"image file.png": "dir/image file.png",
+			},
+		},
+		{
+			name: "directories",
+			files: map[string]string{
+				"Sub Dir/Crème/Note.md":  "",
+				"🎉/Party Time.md":        "",
+				"Sub Dir/image file.png": "",
+			},
+			wantPaths: map[string]string{
+				"Note":           "sub-dir/creme/note.md",
+				"Party Time":     "🎉/party-time.md",
+				"image file.png": "Sub Dir/image file.png",
+			},
+		},
+		{
+			name: "collision in slugged directory",
+			files: map[string]string{
+				"Sub Dir/note.md": "",
+				"sub-dir/Note.md": "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "collision",
+			files: map[string]string{
+				"Note.md":  "",
+				"note!.md": "",
+				"other.md": "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty slug",
+			files: map[string]string{
+				"🎉.md": "",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		vault := t.TempDir()
+		for name, content := range tt.files {
+			path := filepath.Join(vault, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+				t.Fatalf("[FATAL | %s] failed to create directory: %v", tt.name, err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+				t.Fatalf("[FATAL | %s] failed to write: %v", tt.name, err)
+			}
+		}
+		skipper, err := NewSkipper(filepath.Join(vault, ".obsdconvignore"))
+		if err != nil {
+			t.Fatalf("[FATAL | %s] NewSkipper failed: %v", tt.name, err)
+		}
+
+		index, err := BuildSlugIndex(vault, vault, skipper)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("[ERROR | %s] error expected but not returned", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[FATAL | %s] BuildSlugIndex failed: %v", tt.name, err)
+		}
+		db := WrapForSluggingPaths(convert.NewPathDB(vault), index)
+		for fileId, want := range tt.wantPaths {
+			got, err := db.Get(fileId)
+			if err != nil {
+				t.Fatalf("[FATAL | %s] PathDB.Get failed: %v", tt.name, err)
+			}
+			if got != want {
+				t.Errorf("[ERROR | %s] fileId: %s, got: %q, want: %q", tt.name, fileId, got, want)
+			}
+		}
+	}
+}
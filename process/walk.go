@@ -12,6 +12,22 @@ const (
 )
 
 func Walk(src, dst string, skipper Skipper, processor Processor) error {
+	return walk(src, dst, skipper, processor, true)
+}
+
+// dst にディレクトリを作成しない Walk
+// processor が何も書き込まない場合 (dry run) に使う
+func WalkDryRun(src, dst string, skipper Skipper, processor Processor) error {
+	return walk(src, dst, skipper, processor, false)
+}
+
+// dst に src と同じ構造のディレクトリを作成しない Walk
+// processor が出力先を変える場合に使う. 出力先のディレクトリは FileWriter が作成する
+func WalkWithoutMkdir(src, dst string, skipper Skipper, processor Processor) error {
+	return walk(src, dst, skipper, processor, false)
+}
+
+func walk(src, dst string, skipper Skipper, processor Processor, mkdir bool) error {
 	errs := make(chan error, NUM_CONCURRENT)
 	lock := make(chan struct{}, NUM_CONCURRENT)
 	passedAll := make(chan struct{})
@@ -37,6 +53,9 @@ func Walk(src, dst string, skipper Skipper, processor Processor) error {
 
 		newpath := filepath.Join(dst, rpath)
 		if info.IsDir() {
+			if !mkdir {
+				return nil
+			}
 			if _, err := os.Stat(newpath); !os.IsNotExist(err) {
 				return nil
 			}
@@ -0,0 +1,206 @@
+package process
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+)
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// polling で vault 内の変更を検出し, 変更のあったファイルを processor で処理する
+// ファイルの追加, 削除, 移動は db に反映される
+type Watcher struct {
+	vault     string
+	tgt       string
+	dst       string
+	skipper   Skipper
+	processor Processor
+	db        convert.MutablePathDB
+	stamps    map[string]fileStamp         // key: vault からの相対パス
+	refs      map[string]map[string]string // key: vault からの相対パス, value: fileId -> 解決されたパス
+}
+
+// 現時点の vault の状態を記録した Watcher を返す
+// 初回の変換は Walk で済ませておくこと
+func NewWatcher(vault, tgt, dst string, skipper Skipper, processor Processor, db convert.MutablePathDB) (*Watcher, error) {
+	w := &Watcher{
+		vault:     vault,
+		tgt:       tgt,
+		dst:       dst,
+		skipper:   skipper,
+		processor: processor,
+		db:        db,
+		refs:      make(map[string]map[string]string),
+	}
+	stamps, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.stamps = stamps
+	for rpath := range stamps {
+		if _, ok := w.target(rpath); !ok || filepath.Ext(rpath) != ".md" {
+			continue
+		}
+		if err := w.updateRefs(rpath); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// 前回の Poll 以降の変更を処理する
+// processed は処理したファイルの tgt からの相対パス
+func (w *Watcher) Poll() (processed []string, err error) {
+	stamps, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed, added, removed []string
+	for rpath, stamp := range stamps {
+		old, ok := w.stamps[rpath]
+		if !ok {
+			added = append(added, rpath)
+		} else if !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = append(changed, rpath)
+		}
+	}
+	for rpath := range w.stamps {
+		if _, ok := stamps[rpath]; !ok {
+			removed = append(removed, rpath)
+		}
+	}
+	w.stamps = stamps
+
+	for _, rpath := range removed {
+		w.db.Remove(rpath)
+		delete(w.refs, rpath)
+		if trpath, ok := w.target(rpath); ok {
+			if err := os.Remove(filepath.Join(w.dst, trpath)); err != nil && !os.IsNotExist(err) {
+				return nil, errors.Wrapf(err, "failed to remove output of %s", rpath)
+			}
+		}
+	}
+	for _, rpath := range added {
+		w.db.Add(rpath)
+	}
+
+	tobeProcessed := make(map[string]struct{})
+	for _, rpath := range append(changed, added...) {
+		tobeProcessed[rpath] = struct{}{}
+	}
+	// ファイルの追加や削除によってリンク先が変わったノートも処理し直す
+	if len(added) > 0 || len(removed) > 0 {
+		for rpath, refs := range w.refs {
+			for fileId, path := range refs {
+				if got, err := w.db.Get(fileId); err != nil || got != path {
+					tobeProcessed[rpath] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+
+	rpaths := make([]string, 0, len(tobeProcessed))
+	for rpath := range tobeProcessed {
+		rpaths = append(rpaths, rpath)
+	}
+	sort.Strings(rpaths)
+
+	for _, rpath := range rpaths {
+		trpath, ok := w.target(rpath)
+		if !ok {
+			continue
+		}
+		orgpath := filepath.Join(w.vault, rpath)
+		newpath := filepath.Join(w.dst, trpath)
+		// -filter や -pub で処理対象外になったノートの出力が残らないように, 古い出力は先に削除しておく
+		// src = dst の場合は元のファイルを消さないように注意
+		if filepath.Ext(rpath) == ".md" && !samePath(orgpath, newpath) {
+			if err := os.Remove(newpath); err != nil && !os.IsNotExist(err) {
+				return processed, errors.Wrapf(err, "failed to remove output of %s", rpath)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(newpath), 0o777); err != nil {
+			return processed, errors.Wrapf(err, "failed to create directory for %s", newpath)
+		}
+		if err := w.processor.Process(trpath, orgpath, newpath); err != nil {
+			return processed, err
+		}
+		processed = append(processed, trpath)
+		if filepath.Ext(rpath) == ".md" {
+			if err := w.updateRefs(rpath); err != nil {
+				return processed, err
+			}
+		}
+	}
+	return processed, nil
+}
+
+// vault 内のファイルの更新日時とサイズを記録する
+func (w *Watcher) scan() (stamps map[string]fileStamp, err error) {
+	stamps = make(map[string]fileStamp)
+	err = filepath.Walk(w.vault, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			// 走査中に削除されたファイルは無視する
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rpath, err := filepath.Rel(w.vault, path)
+		if err != nil {
+			return err
+		}
+		if w.skipper.Skip(rpath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || filepath.ToSlash(rpath) == MANIFEST_FILE_NAME {
+			return nil
+		}
+		stamps[rpath] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to scan %s", w.vault)
+	}
+	return stamps, nil
+}
+
+// vault からの相対パスを tgt からの相対パスに変換する
+// tgt 外のファイルの場合は ok = false
+func (w *Watcher) target(rpath string) (trpath string, ok bool) {
+	trpath, err := filepath.Rel(w.tgt, filepath.Join(w.vault, rpath))
+	if err != nil || trpath == ".." || strings.HasPrefix(trpath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return trpath, true
+}
+
+func (w *Watcher) updateRefs(rpath string) error {
+	content, err := os.ReadFile(filepath.Join(w.vault, rpath))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", rpath)
+	}
+	refs, err := resolveRefs(content, w.db)
+	if err != nil {
+		// リンクを解析できないノートは, 内容が変わるまで処理し直さない
+		delete(w.refs, rpath)
+		return nil
+	}
+	w.refs[rpath] = refs
+	return nil
+}
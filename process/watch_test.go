@@ -0,0 +1,173 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qawatake/obsdconv/convert"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "sub"), 0o777); err != nil {
+		t.Fatalf("[FATAL] failed to create directory: %v", err)
+	}
+	files := map[string]string{
+		"a.md":     "[[b]]\n",
+		"sub/b.md": "b\n",
+		"c.md":     "c\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o666); err != nil {
+			t.Fatalf("[FATAL] failed to write: %v", err)
+		}
+	}
+	skipper, err := NewSkipper(filepath.Join(src, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+	db := convert.NewMutablePathDB(src)
+	processor := new(fakeCopyingProcessor)
+	if err := Walk(src, dst, skipper, processor); err != nil {
+		t.Fatalf("[FATAL] Walk failed: %v", err)
+	}
+	watcher, err := NewWatcher(src, src, dst, skipper, processor, db)
+	if err != nil {
+		t.Fatalf("[FATAL] NewWatcher failed: %v", err)
+	}
+
+	cases := []struct {
+		name          string
+		update        func()
+		wantProcessed []string
+		wantRef       string // b の解決先
+	}{
+		{
+			name:          "nothing changed",
+			update:        func() {},
+			wantProcessed: nil,
+			wantRef:       "sub/b.md",
+		},
+		{
+			name: "changed",
+			update: func() {
+				os.WriteFile(filepath.Join(src, "c.md"), []byte("changed\n"), 0o666)
+			},
+			wantProcessed: []string{"c.md"},
+			wantRef:       "sub/b.md",
+		},
+		{
+			name: "added",
+			update: func() {
+				os.WriteFile(filepath.Join(src, "sub", "d.md"), []byte("d\n"), 0o666)
+			},
+			wantProcessed: []string{filepath.Join("sub", "d.md")},
+			wantRef:       "sub/b.md",
+		},
+		{
+			name: "renamed",
+			update: func() {
+				os.Rename(filepath.Join(src, "sub", "b.md"), filepath.Join(src, "b.md"))
+			},
+			wantProcessed: []string{"a.md", "b.md"},
+			wantRef:       "b.md",
+		},
+		{
+			name: "removed",
+			update: func() {
+				os.Remove(filepath.Join(src, "b.md"))
+			},
+			wantProcessed: []string{"a.md"},
+			wantRef:       "",
+		},
+	}
+
+	for _, tt := range cases {
+		tt.update()
+		got, err := watcher.Poll()
+		if err != nil {
+			t.Fatalf("[FATAL | %s] Poll failed: %v", tt.name, err)
+		}
+		if len(got) != len(tt.wantProcessed) {
+			t.Errorf("[ERROR | %s] got: %v, want: %v", tt.name, got, tt.wantProcessed)
+		} else {
+			for i := range got {
+				if got[i] != tt.wantProcessed[i] {
+					t.Errorf("[ERROR | %s] got: %v, want: %v", tt.name, got, tt.wantProcessed)
+					break
+				}
+			}
+		}
+		if gotRef, _ := db.Get("b"); gotRef != tt.wantRef {
+			t.Errorf("[ERROR | ref - %s] got: %q, want: %q", tt.name, gotRef, tt.wantRef)
+		}
+	}
+
+	// 削除されたファイルの出力も削除されている
+	for _, name := range []string{"b.md", filepath.Join("sub", "b.md")} {
+		if _, err := os.Stat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+			t.Errorf("[ERROR] output of removed file %s was not removed", name)
+		}
+	}
+}
+
+// draft: true のノートを出力しない
+type fakeDraftSkippingProcessor struct {
+	fakeCopyingProcessor
+}
+
+func (p *fakeDraftSkippingProcessor) Process(relativePath, orgpath, newpath string) error {
+	content, err := os.ReadFile(orgpath)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(content), "draft: true") {
+		return nil
+	}
+	return p.fakeCopyingProcessor.Process(relativePath, orgpath, newpath)
+}
+
+func TestWatcherPollWithDraft(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	note := filepath.Join(src, "note.md")
+	if err := os.WriteFile(note, []byte("---\ndraft: false\n---\n"), 0o666); err != nil {
+		t.Fatalf("[FATAL] failed to write: %v", err)
+	}
+	skipper, err := NewSkipper(filepath.Join(src, ".obsdconvignore"))
+	if err != nil {
+		t.Fatalf("[FATAL] NewSkipper failed: %v", err)
+	}
+	processor := new(fakeDraftSkippingProcessor)
+	if err := Walk(src, dst, skipper, processor); err != nil {
+		t.Fatalf("[FATAL] Walk failed: %v", err)
+	}
+	watcher, err := NewWatcher(src, src, dst, skipper, processor, convert.NewMutablePathDB(src))
+	if err != nil {
+		t.Fatalf("[FATAL] NewWatcher failed: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		content    string
+		wantExists bool
+	}{
+		{name: "turned into draft", content: "---\ndraft: true\n---\n", wantExists: false},
+		{name: "published again", content: "---\ndraft: false\n---\npublished\n", wantExists: true},
+	}
+	for _, tt := range cases {
+		if err := os.WriteFile(note, []byte(tt.content), 0o666); err != nil {
+			t.Fatalf("[FATAL | %s] failed to write: %v", tt.name, err)
+		}
+		if _, err := watcher.Poll(); err != nil {
+			t.Fatalf("[FATAL | %s] Poll failed: %v", tt.name, err)
+		}
+		_, err := os.Stat(filepath.Join(dst, "note.md"))
+		if exists := err == nil; exists != tt.wantExists {
+			t.Errorf("[ERROR | %s] output exists: %v, want: %v", tt.name, exists, tt.wantExists)
+		}
+	}
+}
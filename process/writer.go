@@ -0,0 +1,129 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// 変換結果の書き込み先
+type FileWriter interface {
+	WriteFile(path string, content []byte) error
+	CopyFile(orgpath, newpath string) error
+}
+
+type fileWriterImpl struct{}
+
+func NewFileWriter() FileWriter {
+	return new(fileWriterImpl)
+}
+
+// 書き込み先のディレクトリがなければ作成する
+func (w *fileWriterImpl) WriteFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %s", path)
+	}
+	if err := os.WriteFile(path, content, 0o666); err != nil {
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	return nil
+}
+
+func (w *fileWriterImpl) CopyFile(orgpath, newpath string) error {
+	// src = dst の場合, os.Create でコピー元の内容が消えてしまう
+	if samePath(orgpath, newpath) {
+		return nil
+	}
+	file, err := os.Open(orgpath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := os.MkdirAll(filepath.Dir(newpath), 0o777); err != nil {
+		return err
+	}
+	newfile, err := os.Create(newpath)
+	if err != nil {
+		return err
+	}
+	defer newfile.Close()
+	io.Copy(newfile, file)
+	return nil
+}
+
+// ファイルを書き込む代わりに, 既存のファイルとの差分を記録する
+type DiffWriter struct {
+	mu    sync.Mutex
+	diffs map[string]string
+}
+
+func NewDiffWriter() *DiffWriter {
+	return &DiffWriter{
+		diffs: make(map[string]string),
+	}
+}
+
+func (w *DiffWriter) WriteFile(path string, content []byte) error {
+	oldName := path
+	old, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		oldName = os.DevNull
+	}
+	w.record(path, unifiedDiff(oldName, path, old, content))
+	return nil
+}
+
+func (w *DiffWriter) CopyFile(orgpath, newpath string) error {
+	org, err := os.ReadFile(orgpath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", orgpath)
+	}
+	old, err := os.ReadFile(newpath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to read %s", newpath)
+		}
+		w.record(newpath, fmt.Sprintf("Only in %s: %s\n", orgpath, newpath))
+		return nil
+	}
+	if !bytes.Equal(org, old) {
+		w.record(newpath, fmt.Sprintf("Files %s and %s differ\n", orgpath, newpath))
+	}
+	return nil
+}
+
+func (w *DiffWriter) record(path string, diff string) {
+	if diff == "" {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.diffs[path] = diff
+}
+
+// 記録した差分をパス順に出力する
+func (w *DiffWriter) Flush(out io.Writer) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	paths := make([]string, 0, len(w.diffs))
+	for path := range w.diffs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if _, err := io.WriteString(out, w.diffs[path]); err != nil {
+			return errors.Wrap(err, "failed to write diff")
+		}
+	}
+	w.diffs = make(map[string]string)
+	return nil
+}
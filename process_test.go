@@ -9,12 +9,14 @@ import (
 	"testing"
 
 	"github.com/qawatake/obsdconv/convert"
+	"github.com/qawatake/obsdconv/process"
 )
 
-func TestExamineYaml(t *testing.T) {
+func TestExamineNote(t *testing.T) {
 	cases := []struct {
 		name        string
 		publishable bool
+		pubRule     string // 空文字列なら DEFAULT_PUB_RULE
 		yml         []byte
 		want        bool
 	}{
@@ -49,12 +51,65 @@ draft: true`),
 			yml:         []byte(`draft: true`),
 			want:        false,
 		},
+		{
+			name:        "publishable && draft: false && publish: false",
+			publishable: true,
+			yml: []byte(`draft: false
+publish: false`),
+			want: true,
+		},
+		{
+			name:        "custom rule",
+			publishable: true,
+			pubRule:     `status == "published" && visibility == "public"`,
+			yml: []byte(`status: published
+visibility: public
+draft: true`),
+			want: true,
+		},
+		{
+			name:        "custom rule not satisfied",
+			publishable: true,
+			pubRule:     `status == "published" && visibility == "public"`,
+			yml: []byte(`status: published
+visibility: private`),
+			want: false,
+		},
+		{
+			name:        "publishDate in the past",
+			publishable: true,
+			pubRule:     "publishDate <= now",
+			yml:         []byte(`publishDate: 2021-10-27`),
+			want:        true,
+		},
+		{
+			name:        "publishDate in the future",
+			publishable: true,
+			pubRule:     "publishDate <= now",
+			yml:         []byte(`publishDate: 9999-12-31T00:00:00Z`),
+			want:        false,
+		},
+		{
+			name:        "no publishDate",
+			publishable: true,
+			pubRule:     "publishDate <= now",
+			yml:         []byte(`publish: true`),
+			want:        false,
+		},
 	}
 
 	for _, tt := range cases {
-		got, err := newYamlExaminatorImpl("", tt.publishable).ExamineYaml(tt.yml)
+		pubRule := tt.pubRule
+		if pubRule == "" {
+			pubRule = DEFAULT_PUB_RULE
+		}
+		examinator, err := newNoteExaminatorImpl("", "", tt.publishable, pubRule)
 		if err != nil {
-			t.Fatalf("[FATAL | %s] ExamineYaml failed: %v", tt.name, err)
+			t.Fatalf("[FATAL | %s] newNoteExaminatorImpl failed: %v", tt.name, err)
+		}
+		got, err := examinator.ExamineNote(tt.yml, &process.NoteInfo{})
+		if err != nil {
+			t.Fatalf("[FATAL | %s] ExamineNote failed: %v", tt.name, err)
 		}
 		if got != tt.want {
 			t.Errorf("[ERROR | %s] got: %v, want: %v", tt.name, got, tt.want)
@@ -171,7 +226,7 @@ func TestConvertBody(t *testing.T) {
 	for _, tt := range cases {
 		vault := filepath.Join(test_CONVERT_BODY_DIR, tt.rootDir, tt.srcDir)
 		db := convert.NewPathDB(vault)
-		c := newBodyConverterImpl(db, tt.cptag, tt.rmtag, tt.cmmt, tt.title, tt.link, tt.rmH1, tt.formatLink, tt.formatAnchor, nil)
+		c := newBodyConverterImpl(db, tt.cptag, tt.rmtag, tt.cmmt, tt.title, tt.link, tt.rmH1, tt.formatLink, tt.formatAnchor, convert.LINK_STYLE_PATH, nil, convert.IMAGE_SIZE_STYLE_HTML, convert.EMBED_FALLBACK_IMAGE, nil, "", nil, "", nil, DEFAULT_BLOCK_ANCHOR, "", "")
 
 		srcFileName := filepath.Join(vault, tt.rawFileName)
 		srcFile, err := os.Open(srcFileName)
@@ -260,6 +315,7 @@ func TestConvertYAML(t *testing.T) {
 		synctag     bool
 		synctlal    bool
 		publishable bool
+		pubMarker   string // 空文字列なら PUB_MARKER_DRAFT
 		remap       map[string]string
 		raw         []byte
 		title       string
@@ -274,10 +330,10 @@ publish: true`),
 			title: "211027",
 			alias: "today",
 			tags:  []string{"todo", "math"},
-			want: `aliases:
-- today
-cssclass: index-page
+			want: `cssclass: index-page
 publish: true
+aliases:
+- today
 tags:
 - todo
 - math
@@ -293,14 +349,14 @@ title: 211026`),
 			title: "211027",
 			alias: "today",
 			tags:  []string{"todo", "math"},
-			want: `aliases:
-- today
-cssclass: index-page
+			want: `cssclass: index-page
 publish: true
+title: "211027"
+aliases:
+- today
 tags:
 - todo
 - math
-title: "211027"
 `,
 		},
 		//////////
@@ -314,11 +370,11 @@ aliases:
 			title: "211027",
 			alias: "birthday",
 			tags:  []string{"todo", "math"},
-			want: `aliases:
+			want: `cssclass: index-page
+publish: true
+aliases:
 - today
 - birthday
-cssclass: index-page
-publish: true
 tags:
 - todo
 - math
@@ -336,10 +392,10 @@ aliases:
 			title: "211027",
 			alias: "today",
 			tags:  []string{"todo", "math"},
-			want: `aliases:
-- today
-cssclass: index-page
+			want: `cssclass: index-page
 publish: true
+aliases:
+- today
 tags:
 - todo
 - math
@@ -357,14 +413,14 @@ tags:
 			title: "211027",
 			alias: "today",
 			tags:  []string{"todo", "math"},
-			want: `aliases:
-- today
-cssclass: index-page
+			want: `cssclass: index-page
 publish: true
 tags:
 - book
 - todo
 - math
+aliases:
+- today
 title: "211027"
 `,
 		},
@@ -380,14 +436,14 @@ tags:
 			title: "211027",
 			alias: "today",
 			tags:  []string{"todo", "math"},
-			want: `aliases:
-- today
-cssclass: index-page
+			want: `cssclass: index-page
 publish: true
 tags:
 - book
 - math
 - todo
+aliases:
+- today
 title: "211027"
 `,
 		},
@@ -401,11 +457,11 @@ publish: true
 			alias:       "today",
 			tags:        []string{"todo", "math"},
 			publishable: true,
-			want: `aliases:
+			want: `cssclass: index-page
+publish: true
+aliases:
 - today
-cssclass: index-page
 draft: false
-publish: true
 tags:
 - todo
 - math
@@ -414,18 +470,18 @@ title: "211027"
 		},
 		//////////
 		{
-			name: "not publishable",
+			name: "published by custom pubRule",
 			raw: []byte(`cssclass: index-page
-publish: false`),
+status: published`),
 			title:       "211027",
 			alias:       "today",
 			tags:        []string{"todo", "math"},
 			publishable: true,
-			want: `aliases:
+			want: `cssclass: index-page
+status: published
+aliases:
 - today
-cssclass: index-page
-draft: true
-publish: false
+draft: false
 tags:
 - todo
 - math
@@ -441,10 +497,10 @@ title: "211027"
 			alias:       "today",
 			tags:        []string{"todo", "math"},
 			publishable: true,
-			want: `aliases:
+			want: `cssclass: index-page
+aliases:
 - today
-cssclass: index-page
-draft: true
+draft: false
 tags:
 - todo
 - math
@@ -461,15 +517,50 @@ draft: true`),
 			alias:       "today",
 			tags:        []string{"todo", "math"},
 			publishable: true,
-			want: `aliases:
-- today
-cssclass: index-page
-draft: true
+			want: `cssclass: index-page
 publish: true
+draft: true
+aliases:
+- today
 tags:
 - todo
 - math
 title: "211027"
+`,
+		},
+		//////////
+		{
+			name: "pubMarker none",
+			raw: []byte(`cssclass: index-page
+publish: true
+`),
+			publishable: true,
+			pubMarker:   PUB_MARKER_NONE,
+			want: `cssclass: index-page
+publish: true
+`,
+		},
+		//////////
+		{
+			name: "pubMarker key=value",
+			raw: []byte(`cssclass: index-page
+status: ready
+`),
+			publishable: true,
+			pubMarker:   "status=published",
+			want: `cssclass: index-page
+status: published
+`,
+		},
+		//////////
+		{
+			name: "pubMarker key=bool",
+			raw: []byte(`cssclass: index-page
+`),
+			publishable: true,
+			pubMarker:   "hidden=false",
+			want: `cssclass: index-page
+hidden: false
 `,
 		},
 		//////////
@@ -482,10 +573,10 @@ publish: true
 			alias: "today",
 			tags:  []string{"todo", "math"},
 			// flags: &flagBundle{},
-			want: `aliases:
-- today
-cssclass: index-page
+			want: `cssclass: index-page
 publish: true
+aliases:
+- today
 tags:
 - todo
 - math
@@ -503,13 +594,13 @@ tags:
 			title: "211027",
 			alias: "today",
 			tags:  []string{"todo", "math"},
-			want: `aliases:
-- today
-cssclass: index-page
+			want: `cssclass: index-page
 publish: true
 tags:
 - todo
 - math
+aliases:
+- today
 title: "211027"
 `,
 		},
@@ -577,20 +668,100 @@ title: existing-title
 `),
 			title: "211208",
 			alias: "today",
-			want: `publish: true
+			want: `xaliases:
+- existing-alias
+- today
+publish: true
 tags:
 - book
 title: "211208"
+`,
+		},
+		{
+			name: "keep order, comments and formatting",
+			raw: []byte(`# comment at the top
+title: 'old title' # inline comment
+date: 2021-10-27
+tags: [book, math] # flow style
+
+# about the description
+description: >
+  long
+  description
+cssclass: "index-page"
+`),
+			title: "new title",
+			tags:  []string{"todo"},
+			want: `# comment at the top
+title: new title
+date: 2021-10-27
+tags:
+- book
+- math
+- todo
+
+# about the description
+description: >
+  long
+  description
+cssclass: "index-page"
+`,
+		},
+		{
+			name: "nothing changed",
+			raw: []byte(`title:   "211027"   # same value
+aliases: [ "today" ]
+nested:
+    b: 2
+    a: 1
+`),
+			title: "211027",
+			alias: "today",
+			want: `title:   "211027"   # same value
+aliases: [ "today" ]
+nested:
+    b: 2
+    a: 1
+`,
+		},
+		{
+			name: "keep comments around removed and renamed keys",
+			remap: map[string]string{
+				"cssclass": "",
+				"aliases":  "xaliases",
+			},
+			raw: []byte(`# head
+cssclass: index-page
+# before aliases
+aliases:
+  - today # the day
+# before publish
+publish: true`),
+			want: `# head
+# before aliases
 xaliases:
-- existing-alias
 - today
+# before publish
+publish: true
 `,
 		},
 	}
 
 	for _, tt := range cases {
-		yc := newYamlConverterImpl(tt.synctag, tt.synctlal, tt.publishable, tt.remap)
-		auxinput := newYamlConvAuxInImpl(tt.title, tt.alias, tt.tags)
+		var pubMarker *publishMarker
+		if tt.publishable {
+			input := tt.pubMarker
+			if input == "" {
+				input = PUB_MARKER_DRAFT
+			}
+			var err error
+			pubMarker, err = parsePubMarker(input)
+			if err != nil {
+				t.Fatalf("[FATAL | %s] parsePubMarker failed: %v", tt.name, err)
+			}
+		}
+		yc := newYamlConverterImpl(tt.synctag, tt.synctlal, pubMarker, tt.remap, nil)
+		auxinput := newYamlConvAuxInImpl(tt.title, tt.alias, tt.tags, nil, nil)
 		got, err := yc.ConvertYAML(tt.raw, auxinput)
 		if err != nil {
 			t.Fatalf("[FATAL | %s] unexpected error occurred: %v", tt.name, err)
@@ -601,6 +772,177 @@ xaliases:
 	}
 }
 
+func TestYamlRules(t *testing.T) {
+	cases := []struct {
+		name    string
+		rules   string
+		raw     string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			rules: `- key: layout
+  default: post
+`,
+			raw: "title: sample\n",
+			want: `title: sample
+layout: post
+`,
+		},
+		{
+			name: "default does not overwrite",
+			rules: `- key: layout
+  default: post
+`,
+			raw: "layout: page\n",
+			want: `layout: page
+`,
+		},
+		{
+			name: "types",
+			rules: `- key: date
+  type: timestamp
+- key: categories
+  type: list
+- key: weight
+  type: number
+- key: toc
+  type: bool
+- key: version
+  type: string
+`,
+			raw: `date: "2021-10-27"
+categories: "go, obsidian,"
+weight: "3"
+toc: "true"
+version: 1.5
+`,
+			want: `date: 2021-10-27T00:00:00Z
+categories:
+- go
+- obsidian
+weight: 3
+toc: true
+version: "1.5"
+`,
+		},
+		{
+			name: "transform tags",
+			rules: `- key: tags
+  transform: [lowercase, slugify]
+`,
+			raw: `tags:
+- Go
+- go
+- Web Development
+`,
+			want: `tags:
+- go
+- web-development
+`,
+		},
+		{
+			name: "description from the first paragraph",
+			rules: `- key: description
+  template: "{{ truncate 30 .FirstParagraph }}"
+- key: summary
+  template: "{{ .FrontMatter.title }}: {{ .FirstParagraph }}"
+`,
+			raw:  "title: sample\n",
+			body: "# Heading\n\n```\ncode\n```\n\nSee [[note|the note]] and\n[docs](https://example.com) for details. %%comment%%\n\nSecond paragraph.\n",
+			want: `title: sample
+description: See the note and docs for deta…
+summary: 'sample: See the note and docs for details.'
+`,
+		},
+		{
+			name: "template does not overwrite",
+			rules: `- key: description
+  template: "{{ .FirstParagraph }}"
+`,
+			raw:  "description: existing\n",
+			body: "First.\n",
+			want: `description: existing
+`,
+		},
+		{
+			name: "template overwrites",
+			rules: `- key: slug
+  template: "{{ slugify .FrontMatter.title }}"
+  overwrite: true
+`,
+			raw: "slug: old\ntitle: Hello World\n",
+			want: `slug: hello-world
+title: Hello World
+`,
+		},
+		{
+			name: "invalid timestamp",
+			rules: `- key: date
+  type: timestamp
+`,
+			raw:     "date: yesterday\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range cases {
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(path, []byte(tt.rules), 0o666); err != nil {
+			t.Fatalf("[FATAL | %s] failed to write: %v", tt.name, err)
+		}
+		rules, err := loadYamlRules(path)
+		if err != nil {
+			t.Fatalf("[FATAL | %s] loadYamlRules failed: %v", tt.name, err)
+		}
+		yc := newYamlConverterImpl(false, false, nil, nil, rules)
+		got, err := yc.ConvertYAML([]byte(tt.raw), newYamlConvAuxInImpl("", "", nil, nil, []rune(tt.body)))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("[ERROR | %s] expected error did not occur", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[FATAL | %s] unexpected error occurred: %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("[ERROR | %s]\ngot:\n%s\nwant:\n%s", tt.name, string(got), tt.want)
+		}
+	}
+}
+
+func TestLoadYamlRules(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules string
+	}{
+		{name: "no key", rules: "- default: post\n"},
+		{name: "no action", rules: "- key: layout\n"},
+		{name: "unknown type", rules: "- key: date\n  type: datetime\n"},
+		{name: "unknown transform", rules: "- key: tags\n  transform: [capitalize]\n"},
+		{name: "unknown field", rules: "- key: layout\n  defualt: post\n"},
+		{name: "invalid template", rules: "- key: description\n  template: \"{{ .FirstParagraph\"\n"},
+	}
+
+	for _, tt := range cases {
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(path, []byte(tt.rules), 0o666); err != nil {
+			t.Fatalf("[FATAL | %s] failed to write: %v", tt.name, err)
+		}
+		_, err := loadYamlRules(path)
+		if err == nil {
+			t.Errorf("[ERROR | %s] expected error did not occur", tt.name)
+			continue
+		}
+		if e, ok := err.(mainErr); !ok || e.Kind() != MAIN_ERR_KIND_INVALID_YAML_RULES {
+			t.Errorf("[ERROR | %s] unexpected error occurred: %v", tt.name, err)
+		}
+	}
+}
+
 func TestPassArg(t *testing.T) {
 	cases := []struct {
 		name       string
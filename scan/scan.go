@@ -559,3 +559,162 @@ func ScanExternalLinkVarDefGroup(raw []rune, ptr int) (advance int, ptrs []int)
 		}
 	}
 }
+
+// 段落やリストの項目の末尾に付けられたブロック ID (^id) を読む
+// ^ の直前は空白か行頭, id の直後は行末 (末尾の空白は無視) でなければならない
+// さらにブロックの末尾であること, つまり次の行が空行かリストの項目であるか, 入力の終わりであることが必要
+// advance は ^id の長さで, 末尾の空白と改行は含まない
+func ScanBlockId(raw []rune, ptr int) (advance int, id string) {
+	if !unescaped(raw, ptr, "^") {
+		return 0, ""
+	}
+	if !(precededBy(raw, ptr, []string{" ", "\t", "\n"}) || ptr == 0) {
+		return 0, ""
+	}
+
+	cur := ptr + 1
+	for cur < len(raw) && isLetterForBlockId(raw[cur]) {
+		cur++
+	}
+	if cur == ptr+1 {
+		return 0, ""
+	}
+	end := cur
+	for end < len(raw) && (raw[end] == ' ' || raw[end] == '\t') {
+		end++
+	}
+	if end < len(raw) && raw[end] != '\n' && raw[end] != '\r' {
+		return 0, ""
+	}
+	if !endsBlock(raw, end) {
+		return 0, ""
+	}
+	return cur - ptr, string(raw[ptr+1 : cur])
+}
+
+// 行末 ptr の次の行が空行かリストの項目, もしくは入力の終わりなら true
+func endsBlock(raw []rune, ptr int) bool {
+	cur, _ := nextLine(raw, ptr)
+	if cur >= len(raw) {
+		return true
+	}
+	_, line := nextLine(raw, cur)
+	next := strings.TrimLeft(string(line), " \t")
+	if strings.TrimSpace(next) == "" {
+		return true
+	}
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(next, marker) {
+			return true
+		}
+	}
+	digits := 0
+	for digits < len(next) && next[digits] >= '0' && next[digits] <= '9' {
+		digits++
+	}
+	return digits > 0 && (strings.HasPrefix(next[digits:], ". ") || strings.HasPrefix(next[digits:], ") "))
+}
+
+func isLetterForBlockId(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-'
+}
+
+// > [!type] title で始まる callout を読む
+// fold は折りたたみの指定 (+ か -) で, 指定がなければ空文字列
+// content は 2 行目以降から行頭の > を一つずつ取り除いたもの
+// advance は最後の行の改行を含む
+func ScanCallout(raw []rune, ptr int) (advance int, calloutType string, fold string, title string, content []rune) {
+	if !unescaped(raw, ptr, ">") {
+		return 0, "", "", "", nil
+	}
+	// 前の改行まではスペースしか入っちゃいけない
+	for back := ptr; back > 0; {
+		back--
+		if raw[back] == '\n' {
+			break
+		} else if raw[back] == ' ' {
+			continue
+		} else {
+			return 0, "", "", "", nil
+		}
+	}
+
+	cur, line := nextLine(raw, ptr)
+	head := strings.TrimLeft(string(line[1:]), " \t")
+	if !strings.HasPrefix(head, "[!") {
+		return 0, "", "", "", nil
+	}
+	closing := strings.Index(head, "]")
+	if closing < 0 {
+		return 0, "", "", "", nil
+	}
+	calloutType = head[2:closing]
+	if calloutType == "" {
+		return 0, "", "", "", nil
+	}
+	for _, r := range calloutType {
+		if !isLetterForCalloutType(r) {
+			return 0, "", "", "", nil
+		}
+	}
+	head = head[closing+1:]
+	if strings.HasPrefix(head, "+") || strings.HasPrefix(head, "-") {
+		fold = head[:1]
+		head = head[1:]
+	}
+	title = strings.Trim(head, " \t\r\n")
+
+	var b strings.Builder
+	for cur < len(raw) {
+		next, line := nextLine(raw, cur)
+		quoted := strings.TrimLeft(string(line), " ")
+		if !strings.HasPrefix(quoted, ">") {
+			break
+		}
+		quoted = strings.TrimPrefix(quoted[1:], " ")
+		b.WriteString(quoted)
+		cur = next
+	}
+	return cur - ptr, calloutType, fold, title, []rune(b.String())
+}
+
+// ptr から次の改行までの行 (改行を含む) と次の行の先頭を返す
+func nextLine(raw []rune, ptr int) (next int, line []rune) {
+	adv := indexInRunes(raw[ptr:], "\n")
+	if adv < 0 {
+		return len(raw), raw[ptr:]
+	}
+	return ptr + adv + 1, raw[ptr : ptr+adv+1]
+}
+
+func isLetterForCalloutType(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_'
+}
+
+// ==text== を読む
+// text は空白で始まったり終わったりしてはいけない. 段落をまたぐことはできない
+func ScanHighlight(raw []rune, ptr int) (advance int, text string) {
+	if !unescaped(raw, ptr, "==") {
+		return 0, ""
+	}
+	if ptr > 0 && raw[ptr-1] == '=' {
+		return 0, ""
+	}
+	if len(raw[ptr:]) < 3 || strings.ContainsRune(" \t\r\n=", raw[ptr+2]) {
+		return 0, ""
+	}
+
+	for cur := ptr + 3; cur < len(raw); cur++ {
+		if raw[cur] == '\\' {
+			cur++
+			continue
+		}
+		if precededBy(raw, cur+1, []string{"\n\n", "\r\n\r\n"}) {
+			return 0, ""
+		}
+		if unescaped(raw, cur, "==") && !strings.ContainsRune(" \t\r\n", raw[cur-1]) {
+			return cur + 2 - ptr, string(raw[ptr+2 : cur])
+		}
+	}
+	return 0, ""
+}
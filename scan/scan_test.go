@@ -1097,3 +1097,118 @@ func TestScanExternalLinkVarDefGroup(t *testing.T) {
 		}
 	}
 }
+
+func TestScanBlockId(t *testing.T) {
+	cases := []struct {
+		name        string
+		raw         []rune
+		ptr         int
+		wantAdvance int
+		wantId      string
+	}{
+		{name: "end of paragraph", raw: []rune("text ^abc-123\n\nnext"), ptr: 5, wantAdvance: 8, wantId: "abc-123"},
+		{name: "end of list item", raw: []rune("- item ^abc\n- next"), ptr: 7, wantAdvance: 4, wantId: "abc"},
+		{name: "end of ordered list item", raw: []rune("1. item ^abc\r\n2. next"), ptr: 8, wantAdvance: 4, wantId: "abc"},
+		{name: "soft-wrapped line", raw: []rune("text ^abc\nnext"), ptr: 5, wantAdvance: 0, wantId: ""},
+		{name: "end of text", raw: []rune("text ^abc"), ptr: 5, wantAdvance: 4, wantId: "abc"},
+		{name: "trailing spaces", raw: []rune("text ^abc  \r\n"), ptr: 5, wantAdvance: 4, wantId: "abc"},
+		{name: "own line", raw: []rune("^abc\n"), ptr: 0, wantAdvance: 4, wantId: "abc"},
+		{name: "not end of line", raw: []rune("text ^abc text"), ptr: 5, wantAdvance: 0, wantId: ""},
+		{name: "not preceded by space", raw: []rune("x^2"), ptr: 1, wantAdvance: 0, wantId: ""},
+		{name: "footnote", raw: []rune("[^1]"), ptr: 1, wantAdvance: 0, wantId: ""},
+		{name: "escaped", raw: []rune("text \\^abc"), ptr: 6, wantAdvance: 0, wantId: ""},
+		{name: "empty id", raw: []rune("text ^\n"), ptr: 5, wantAdvance: 0, wantId: ""},
+		{name: "invalid letter", raw: []rune("text ^ab_c\n"), ptr: 5, wantAdvance: 0, wantId: ""},
+	}
+
+	for _, tt := range cases {
+		gotAdvance, gotId := ScanBlockId(tt.raw, tt.ptr)
+		if gotAdvance != tt.wantAdvance {
+			t.Errorf("[ERROR | advance - %s] got: %d, want: %d", tt.name, gotAdvance, tt.wantAdvance)
+			continue
+		}
+		if gotId != tt.wantId {
+			t.Errorf("[ERROR | id - %s] got: %q, want: %q", tt.name, gotId, tt.wantId)
+		}
+	}
+}
+
+func TestScanCallout(t *testing.T) {
+	cases := []struct {
+		name        string
+		raw         []rune
+		ptr         int
+		wantAdvance int
+		wantType    string
+		wantFold    string
+		wantTitle   string
+		wantContent string
+	}{
+		{name: "simple", raw: []rune("> [!note] Title\n> text\n\nnext"), ptr: 0, wantAdvance: 23, wantType: "note", wantFold: "", wantTitle: "Title", wantContent: "text\n"},
+		{name: "no title", raw: []rune("> [!tip]\n> text"), ptr: 0, wantAdvance: 15, wantType: "tip", wantFold: "", wantTitle: "", wantContent: "text"},
+		{name: "collapsed", raw: []rune("> [!warning]- Collapsed\n> text\n"), ptr: 0, wantAdvance: 31, wantType: "warning", wantFold: "-", wantTitle: "Collapsed", wantContent: "text\n"},
+		{name: "expanded", raw: []rune(">[!info]+ Open\n>text\n"), ptr: 0, wantAdvance: 21, wantType: "info", wantFold: "+", wantTitle: "Open", wantContent: "text\n"},
+		{name: "nested", raw: []rune("> [!note]\n> outer\n> > [!tip]\n> > inner\nnext"), ptr: 0, wantAdvance: 39, wantType: "note", wantFold: "", wantTitle: "", wantContent: "outer\n> [!tip]\n> inner\n"},
+		{name: "\\r\\n", raw: []rune("> [!note] Title\r\n> text\r\n"), ptr: 0, wantAdvance: 25, wantType: "note", wantFold: "", wantTitle: "Title", wantContent: "text\r\n"},
+		{name: "preceded by \\n and spaces", raw: []rune("x\n  > [!note]\n"), ptr: 4, wantAdvance: 10, wantType: "note", wantFold: "", wantTitle: "", wantContent: ""},
+		{name: "preceded by a letter", raw: []rune("x > [!note]\n"), ptr: 2, wantAdvance: 0},
+		{name: "blockquote", raw: []rune("> quote\n"), ptr: 0, wantAdvance: 0},
+		{name: "empty type", raw: []rune("> [!] x\n"), ptr: 0, wantAdvance: 0},
+		{name: "invalid type", raw: []rune("> [!a b] x\n"), ptr: 0, wantAdvance: 0},
+		{name: "escaped", raw: []rune("\\> [!note]\n"), ptr: 1, wantAdvance: 0},
+	}
+
+	for _, tt := range cases {
+		gotAdvance, gotType, gotFold, gotTitle, gotContent := ScanCallout(tt.raw, tt.ptr)
+		if gotAdvance != tt.wantAdvance {
+			t.Errorf("[ERROR | advance - %s] got: %d, want: %d", tt.name, gotAdvance, tt.wantAdvance)
+			continue
+		}
+		if gotType != tt.wantType {
+			t.Errorf("[ERROR | type - %s] got: %q, want: %q", tt.name, gotType, tt.wantType)
+		}
+		if gotFold != tt.wantFold {
+			t.Errorf("[ERROR | fold - %s] got: %q, want: %q", tt.name, gotFold, tt.wantFold)
+		}
+		if gotTitle != tt.wantTitle {
+			t.Errorf("[ERROR | title - %s] got: %q, want: %q", tt.name, gotTitle, tt.wantTitle)
+		}
+		if string(gotContent) != tt.wantContent {
+			t.Errorf("[ERROR | content - %s] got: %q, want: %q", tt.name, string(gotContent), tt.wantContent)
+		}
+	}
+}
+
+func TestScanHighlight(t *testing.T) {
+	cases := []struct {
+		name        string
+		raw         []rune
+		ptr         int
+		wantAdvance int
+		wantText    string
+	}{
+		{name: "simple", raw: []rune("==text== after"), ptr: 0, wantAdvance: 8, wantText: "text"},
+		{name: "one letter", raw: []rune("==x=="), ptr: 0, wantAdvance: 5, wantText: "x"},
+		{name: "with spaces inside", raw: []rune("a ==two words== b"), ptr: 2, wantAdvance: 13, wantText: "two words"},
+		{name: "across lines", raw: []rune("==line 1\nline 2=="), ptr: 0, wantAdvance: 17, wantText: "line 1\nline 2"},
+		{name: "across paragraphs", raw: []rune("==para 1\n\npara 2=="), ptr: 0, wantAdvance: 0, wantText: ""},
+		{name: "begins with a space", raw: []rune("a == b == c"), ptr: 2, wantAdvance: 0, wantText: ""},
+		{name: "ends with a space", raw: []rune("==a =="), ptr: 0, wantAdvance: 0, wantText: ""},
+		{name: "no closing", raw: []rune("==text"), ptr: 0, wantAdvance: 0, wantText: ""},
+		{name: "escaped opening", raw: []rune("\\==text=="), ptr: 1, wantAdvance: 0, wantText: ""},
+		{name: "escaped closing", raw: []rune("==a\\==b=="), ptr: 0, wantAdvance: 9, wantText: "a\\==b"},
+		{name: "repeated =", raw: []rune("==="), ptr: 0, wantAdvance: 0, wantText: ""},
+		{name: "empty", raw: []rune("===="), ptr: 0, wantAdvance: 0, wantText: ""},
+	}
+
+	for _, tt := range cases {
+		gotAdvance, gotText := ScanHighlight(tt.raw, tt.ptr)
+		if gotAdvance != tt.wantAdvance {
+			t.Errorf("[ERROR | advance - %s] got: %d, want: %d", tt.name, gotAdvance, tt.wantAdvance)
+			continue
+		}
+		if gotText != tt.wantText {
+			t.Errorf("[ERROR | text - %s] got: %q, want: %q", tt.name, gotText, tt.wantText)
+		}
+	}
+}
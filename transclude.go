@@ -0,0 +1,122 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+	"github.com/qawatake/obsdconv/process"
+)
+
+const DEFAULT_TRANSCLUDE_DEPTH = 3
+
+// 埋め込まれたノートを展開するための設定
+type transclusionConfig struct {
+	vault      string
+	tgt        string
+	maxDepth   int                    // 展開を入れ子にできる深さ
+	db         convert.PathDB         // 埋め込まれたノートの vault からの相対パスを返す. リンクと違い slug などに置き換えない
+	examinator process.NoteExaminator // 処理対象外のノートは展開しない. nil なら確認しない
+}
+
+func newTransclusionConfig(vault, tgt string, maxDepth int, db convert.PathDB, examinator process.NoteExaminator) *transclusionConfig {
+	return &transclusionConfig{
+		vault:      vault,
+		tgt:        tgt,
+		maxDepth:   maxDepth,
+		db:         db,
+		examinator: examinator,
+	}
+}
+
+// tgt からの相対パスを vault からの相対パスにする
+func (t *transclusionConfig) vaultPath(selfRelativePath string) (string, error) {
+	path, err := filepath.Rel(t.vault, filepath.Join(t.tgt, selfRelativePath))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the path of %s relative to %s", selfRelativePath, t.vault)
+	}
+	return filepath.ToSlash(path), nil
+}
+
+// ![[note]], ![[note#heading]], ![[note#^block]] を note の変換後の本文で置き換える EmbedsTransformer
+// ノート以外の埋め込み, 処理対象外のノート, 見つからない見出しやブロック, 循環する埋め込み, 深さの上限を超える埋め込みは通常どおりリンクにする
+type transclusionTransformerImpl struct {
+	converter    *bodyConverterImpl
+	fallback     convert.EmbedsTransformer
+	transcluding []string // 展開中のノートの vault からの相対パス. 先頭は変換中のノート
+}
+
+func newTransclusionTransformerImpl(converter *bodyConverterImpl, db convert.PathDB, transcluding []string) *transclusionTransformerImpl {
+	return &transclusionTransformerImpl{
+		converter:    converter,
+		fallback:     convert.NewEmbedsTransformer(db, converter.imageSizeStyle, converter.embedFallback, converter.linkRenderer),
+		transcluding: transcluding,
+	}
+}
+
+func (t *transclusionTransformerImpl) TransformEmbeds(content string) (embeddedLink string, err error) {
+	link, err := convert.ParseLinkContent(convert.LINK_KIND_EMBEDS, content)
+	if err != nil {
+		return "", errors.Wrap(err, "ParseLinkContent failed")
+	}
+	if link == nil || len(t.transcluding) > t.converter.transclusion.maxDepth {
+		return t.fallback.TransformEmbeds(content)
+	}
+
+	var path string
+	if link.FileId == "" {
+		path = t.transcluding[len(t.transcluding)-1]
+	} else {
+		path, err = t.converter.transclusion.db.Get(link.FileId)
+		if err != nil {
+			return "", errors.Wrap(err, "PathDB.Get failed")
+		}
+	}
+	if path == "" || filepath.Ext(path) != ".md" {
+		return t.fallback.TransformEmbeds(content)
+	}
+	for _, p := range t.transcluding {
+		if p == path && (link.FileId != "" || len(link.Fragments) == 0) {
+			return t.fallback.TransformEmbeds(content)
+		}
+	}
+
+	orgpath := filepath.Join(t.converter.transclusion.vault, filepath.FromSlash(path))
+	yml, body, err := process.ReadNote(orgpath)
+	if err != nil {
+		return "", err
+	}
+	if examinator := t.converter.transclusion.examinator; examinator != nil {
+		note, err := process.NewNoteInfo(orgpath, body)
+		if err != nil {
+			return "", err
+		}
+		if ok, err := examinator.ExamineNote(yml, note); err != nil {
+			return "", errors.Wrapf(err, "failed to examine %s embedded", path)
+		} else if !ok {
+			return t.fallback.TransformEmbeds(content)
+		}
+	}
+	if len(link.Fragments) > 0 {
+		fragment := link.Fragments[len(link.Fragments)-1]
+		var found bool
+		if strings.HasPrefix(fragment, "^") {
+			body, found = convert.FindBlock(body, strings.TrimPrefix(fragment, "^"))
+		} else {
+			body, found = convert.FindSection(body, fragment)
+		}
+		if !found {
+			return t.fallback.TransformEmbeds(content)
+		}
+	}
+
+	transcluding := make([]string, len(t.transcluding), len(t.transcluding)+1)
+	copy(transcluding, t.transcluding)
+	transcluding = append(transcluding, path)
+	output, err := t.converter.convertOutput(body, path, transcluding)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to convert %s embedded", path)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
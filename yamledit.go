@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// 変換前の front matter (raw) の書式を保ったまま m を書き出す
+// 値が変わったキーだけを書き換え, 消えたキーは取り除き, 新しいキーは末尾に追加する
+// 変わらなかったキーは順序, コメント, 書式を含めてそのまま残す
+// renamed は 変換前のキー -> 変換後のキー. 変換後のキーは変換前のキーの位置に書き込む
+// raw の形式に対応できないときは m 全体を書き出す
+func marshalFrontMatter(raw []byte, m map[interface{}]interface{}, renamed map[interface{}]interface{}) ([]byte, error) {
+	var keys yaml.MapSlice
+	if err := yaml.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal front matter: %w", err)
+	}
+	original := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(raw, original); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal front matter: %w", err)
+	}
+	prefix, blocks, ok := splitFrontMatter(raw, len(keys))
+	if !ok {
+		return yaml.Marshal(m)
+	}
+
+	buf := new(bytes.Buffer)
+	writeLines(buf, prefix)
+	written := make(map[interface{}]bool)
+	for i, item := range keys {
+		content, trailing := splitTrailingComments(blocks[i])
+		if v, ok := m[item.Key]; ok {
+			written[item.Key] = true
+			if same, err := sameYamlValue(original[item.Key], v); err != nil {
+				return nil, err
+			} else if same {
+				writeLines(buf, content)
+			} else if err := writeYamlEntry(buf, item.Key, v); err != nil {
+				return nil, err
+			}
+		} else if newKey, ok := renamed[item.Key]; ok && !written[newKey] {
+			if v, ok := m[newKey]; ok {
+				if _, exists := original[newKey]; !exists {
+					written[newKey] = true
+					if err := writeYamlEntry(buf, newKey, v); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		// 次のキーの前のコメントや空行は残す
+		writeLines(buf, trailing)
+	}
+
+	rest := make(map[interface{}]interface{})
+	for k, v := range m {
+		if !written[k] {
+			rest[k] = v
+		}
+	}
+	if len(rest) > 0 {
+		out, err := yaml.Marshal(rest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal output yaml: %w", err)
+		}
+		buf.Write(out)
+	}
+	return buf.Bytes(), nil
+}
+
+// raw をトップレベルのキーごとの行に分ける
+// prefix は最初のキーより前の行
+// ブロック形式のマッピングで, キーが 1 列目から始まる場合のみ対応する
+func splitFrontMatter(raw []byte, numKeys int) (prefix []string, blocks [][]string, ok bool) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, false
+	}
+	if doc.Kind != yamlv3.DocumentNode || len(doc.Content) != 1 {
+		return nil, nil, false
+	}
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode || root.Style&yamlv3.FlowStyle != 0 || len(root.Content) != 2*numKeys {
+		return nil, nil, false
+	}
+
+	lines := strings.SplitAfter(string(raw), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	starts := make([]int, 0, numKeys)
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if key.Column != 1 || key.Value == "<<" {
+			return nil, nil, false
+		}
+		if len(starts) > 0 && key.Line-1 <= starts[len(starts)-1] {
+			return nil, nil, false
+		}
+		starts = append(starts, key.Line-1)
+	}
+	if numKeys == 0 {
+		return nil, nil, false
+	}
+
+	prefix = lines[:starts[0]]
+	blocks = make([][]string, 0, numKeys)
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		blocks = append(blocks, lines[start:end])
+	}
+	return prefix, blocks, true
+}
+
+// ブロック末尾の空行と 1 列目から始まるコメントを切り離す
+func splitTrailingComments(block []string) (content []string, trailing []string) {
+	end := len(block)
+	for end > 1 {
+		line := block[end-1]
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		end--
+	}
+	return block[:end], block[end:]
+}
+
+func writeLines(buf *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		buf.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			buf.WriteByte('\n')
+		}
+	}
+}
+
+func writeYamlEntry(buf *bytes.Buffer, key interface{}, value interface{}) error {
+	out, err := yaml.Marshal(yaml.MapSlice{{Key: key, Value: value}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal output yaml: %w", err)
+	}
+	buf.Write(out)
+	return nil
+}
+
+// []string と []interface{} のように型が違っても, yaml として同じなら同じ値とみなす
+func sameYamlValue(x interface{}, y interface{}) (bool, error) {
+	xx, err := yaml.Marshal(x)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal yaml value: %w", err)
+	}
+	yy, err := yaml.Marshal(y)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal yaml value: %w", err)
+	}
+	return bytes.Equal(xx, yy), nil
+}
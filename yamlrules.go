@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/qawatake/obsdconv/convert"
+	"github.com/qawatake/obsdconv/process"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	YAML_RULE_TYPE_TIMESTAMP = "timestamp" // "2021-10-27" -> 2021-10-27T00:00:00Z
+	YAML_RULE_TYPE_LIST      = "list"      // "a, b" -> [a, b]
+	YAML_RULE_TYPE_STRING    = "string"
+	YAML_RULE_TYPE_NUMBER    = "number"
+	YAML_RULE_TYPE_BOOL      = "bool"
+)
+
+var YAML_RULE_TYPES = []string{YAML_RULE_TYPE_TIMESTAMP, YAML_RULE_TYPE_LIST, YAML_RULE_TYPE_STRING, YAML_RULE_TYPE_NUMBER, YAML_RULE_TYPE_BOOL}
+
+const (
+	YAML_RULE_TRANSFORM_LOWERCASE = "lowercase"
+	YAML_RULE_TRANSFORM_UPPERCASE = "uppercase"
+	YAML_RULE_TRANSFORM_SLUGIFY   = "slugify"
+	YAML_RULE_TRANSFORM_TRIM      = "trim"
+)
+
+var YAML_RULE_TRANSFORMS = []string{YAML_RULE_TRANSFORM_LOWERCASE, YAML_RULE_TRANSFORM_UPPERCASE, YAML_RULE_TRANSFORM_SLUGIFY, YAML_RULE_TRANSFORM_TRIM}
+
+// timestamp に変換するときに受け付ける形式
+var yamlRuleDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// front matter の一つのキーに対する規則
+// template, default, type, transform の順に適用する
+type yamlRule struct {
+	Key       string      `yaml:"key"`
+	Default   interface{} `yaml:"default"`   // キーがなければ設定する
+	Type      string      `yaml:"type"`      // 値の型をそろえる
+	Transform []string    `yaml:"transform"` // 文字列またはリストの各文字列に適用する
+	Template  string      `yaml:"template"`  // text/template で値を作る
+	Overwrite bool        `yaml:"overwrite"` // template の結果で既存の値を上書きする
+
+	tmpl *template.Template
+}
+
+type yamlRules []*yamlRule
+
+// 規則を書いた yaml ファイルを読み込む
+func loadYamlRules(path string) (rules yamlRules, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newMainErrf(MAIN_ERR_KIND_INVALID_YAML_RULES, "failed to read %s: %v", path, err)
+	}
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, newMainErrf(MAIN_ERR_KIND_INVALID_YAML_RULES, "failed to parse %s: %v", path, err)
+	}
+	for i, rule := range rules {
+		if err := rule.init(); err != nil {
+			return nil, newMainErrf(MAIN_ERR_KIND_INVALID_YAML_RULES, "invalid rule #%d in %s: %v", i+1, path, err)
+		}
+	}
+	return rules, nil
+}
+
+func (rule *yamlRule) init() error {
+	if rule.Key == "" {
+		return errors.New("key not set")
+	}
+	if rule.Default == nil && rule.Type == "" && len(rule.Transform) == 0 && rule.Template == "" {
+		return errors.Errorf("no action for %s. set default, type, transform or template", rule.Key)
+	}
+	if rule.Type != "" && !containsString(YAML_RULE_TYPES, rule.Type) {
+		return errors.Errorf("unknown type %q. must choose from %s", rule.Type, strings.Join(YAML_RULE_TYPES, ", "))
+	}
+	for _, t := range rule.Transform {
+		if !containsString(YAML_RULE_TRANSFORMS, t) {
+			return errors.Errorf("unknown transform %q. must choose from %s", t, strings.Join(YAML_RULE_TRANSFORMS, ", "))
+		}
+	}
+	if rule.Template != "" {
+		tmpl, err := template.New(rule.Key).Funcs(yamlRuleFuncs).Parse(rule.Template)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse template")
+		}
+		rule.tmpl = tmpl
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+var yamlRuleFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"slugify": process.Slugify,
+	"truncate": func(length int, s string) string {
+		runes := []rune(s)
+		if len(runes) <= length {
+			return s
+		}
+		return string(runes[:length]) + "…"
+	},
+}
+
+// template に渡す値
+type yamlRuleData struct {
+	FrontMatter map[string]interface{}
+	body        []rune
+}
+
+// 見出し, コードブロック, 表などを除いた最初の段落. リンクは表示名にする
+func (d *yamlRuleData) FirstParagraph() (string, error) {
+	body, err := convert.NewCommentEraser().Convert(d.body)
+	if err != nil {
+		return "", errors.Wrap(err, "CommentEraser failed")
+	}
+	body, err = convert.NewLinkPlainConverter().Convert(body)
+	if err != nil {
+		return "", errors.Wrap(err, "LinkPlainConverter failed")
+	}
+	var paragraph []string
+	inFence := false
+	for _, line := range strings.Split(string(body), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") || strings.HasPrefix(trimmed, "$$") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if trimmed == "" {
+			if len(paragraph) > 0 {
+				break
+			}
+			continue
+		}
+		if isParagraphLine(trimmed) {
+			paragraph = append(paragraph, trimmed)
+		} else if len(paragraph) > 0 {
+			break
+		}
+	}
+	return strings.Join(paragraph, " "), nil
+}
+
+func isParagraphLine(line string) bool {
+	for _, prefix := range []string{"#", ">", "|", "<", "![", "---", "- ", "* ", "+ "} {
+		if strings.HasPrefix(line, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// m を規則に従って書き換える
+// body は変換前の本文
+func (rules yamlRules) apply(m map[interface{}]interface{}, body []rune) error {
+	for _, rule := range rules {
+		if err := rule.apply(m, body); err != nil {
+			return errors.Wrapf(err, "failed to apply the rule for %s", rule.Key)
+		}
+	}
+	return nil
+}
+
+func (rule *yamlRule) apply(m map[interface{}]interface{}, body []rune) error {
+	_, exists := m[rule.Key]
+	if rule.tmpl != nil && (!exists || rule.Overwrite) {
+		data := &yamlRuleData{
+			FrontMatter: make(map[string]interface{}),
+			body:        body,
+		}
+		for k, v := range m {
+			data.FrontMatter[fmt.Sprint(k)] = v
+		}
+		b := new(strings.Builder)
+		if err := rule.tmpl.Execute(b, data); err != nil {
+			return errors.Wrap(err, "failed to execute template")
+		}
+		// 空文字列なら設定しない
+		if v := strings.TrimSpace(b.String()); v != "" {
+			m[rule.Key] = v
+		}
+	}
+	if _, ok := m[rule.Key]; !ok && rule.Default != nil {
+		m[rule.Key] = rule.Default
+	}
+	v, ok := m[rule.Key]
+	if !ok || v == nil {
+		return nil
+	}
+	if rule.Type != "" {
+		converted, err := coerceYamlValue(v, rule.Type)
+		if err != nil {
+			return err
+		}
+		v = converted
+	}
+	for _, t := range rule.Transform {
+		v = transformYamlValue(v, t)
+	}
+	m[rule.Key] = v
+	return nil
+}
+
+func coerceYamlValue(v interface{}, kind string) (interface{}, error) {
+	switch kind {
+	case YAML_RULE_TYPE_TIMESTAMP:
+		switch vv := v.(type) {
+		case time.Time:
+			return vv, nil
+		case string:
+			for _, layout := range yamlRuleDateLayouts {
+				if date, err := time.Parse(layout, vv); err == nil {
+					return date, nil
+				}
+			}
+		}
+		return nil, errors.Errorf("cannot convert %v to a timestamp", v)
+	case YAML_RULE_TYPE_LIST:
+		switch vv := v.(type) {
+		case []interface{}:
+			return vv, nil
+		case []string:
+			return toInterfaceList(vv), nil
+		case string:
+			list := make([]interface{}, 0)
+			for _, item := range strings.Split(vv, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			return list, nil
+		default:
+			return []interface{}{vv}, nil
+		}
+	case YAML_RULE_TYPE_STRING:
+		switch vv := v.(type) {
+		case string:
+			return vv, nil
+		case time.Time:
+			return vv.Format(time.RFC3339), nil
+		case []interface{}, map[interface{}]interface{}:
+			return nil, errors.Errorf("cannot convert %v to a string", v)
+		default:
+			return fmt.Sprint(vv), nil
+		}
+	case YAML_RULE_TYPE_NUMBER:
+		switch vv := v.(type) {
+		case int, int64, uint64, float64:
+			return vv, nil
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(vv)); err == nil {
+				return n, nil
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(vv), 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, errors.Errorf("cannot convert %v to a number", v)
+	case YAML_RULE_TYPE_BOOL:
+		switch vv := v.(type) {
+		case bool:
+			return vv, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(vv)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, errors.Errorf("cannot convert %v to a boolean", v)
+	default:
+		return nil, errors.Errorf("unknown type %s", kind)
+	}
+}
+
+func toInterfaceList(list []string) []interface{} {
+	converted := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		converted = append(converted, item)
+	}
+	return converted
+}
+
+// 文字列以外の値はそのまま
+// リストは変換後に重複した文字列を除く
+func transformYamlValue(v interface{}, transform string) interface{} {
+	switch vv := v.(type) {
+	case string:
+		switch transform {
+		case YAML_RULE_TRANSFORM_LOWERCASE:
+			return strings.ToLower(vv)
+		case YAML_RULE_TRANSFORM_UPPERCASE:
+			return strings.ToUpper(vv)
+		case YAML_RULE_TRANSFORM_SLUGIFY:
+			return process.Slugify(vv)
+		case YAML_RULE_TRANSFORM_TRIM:
+			return strings.TrimSpace(vv)
+		}
+		return vv
+	case []string:
+		return transformYamlValue(toInterfaceList(vv), transform)
+	case []interface{}:
+		list := make([]interface{}, 0, len(vv))
+		found := make(map[string]struct{})
+		for _, item := range vv {
+			item = transformYamlValue(item, transform)
+			if s, ok := item.(string); ok {
+				if _, ok := found[s]; ok {
+					continue
+				}
+				found[s] = struct{}{}
+			}
+			list = append(list, item)
+		}
+		return list
+	default:
+		return v
+	}
+}